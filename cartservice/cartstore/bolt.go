@@ -0,0 +1,190 @@
+package cartstore
+
+import (
+	"context"
+	"encoding/binary"
+
+	bolt "go.etcd.io/bbolt"
+
+	pb "cartservice/proto"
+)
+
+// 保存购物车的根bucket，每个用户一个子bucket，key为商品id，value为数量
+var cartsBucket = []byte("carts")
+
+// 数据保存在本地bolt数据库中的结构体，重启后购物车不会丢失
+type boltCartStore struct {
+	db *bolt.DB
+}
+
+// 添加商品，在一个事务中读取、校验和写入
+func (s *boltCartStore) AddItem(ctx context.Context, userID, productID string, quantity int32, out *pb.Empty, check CartCheck) (r *pb.Empty, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		cart, err := tx.Bucket(cartsBucket).CreateBucketIfNotExists([]byte(userID))
+		if err != nil {
+			return err
+		}
+		items, err := readItems(cart)
+		if err != nil {
+			return err
+		}
+		if items[productID], err = addQuantity(items[productID], quantity); err != nil {
+			return err
+		}
+		if err := runCheck(check, userID, items); err != nil {
+			return err
+		}
+		return cart.Put([]byte(productID), encodeQuantity(items[productID]))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// 清空购物车
+func (s *boltCartStore) EmptyCart(ctx context.Context, userID string) (out *pb.Empty, err error) {
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		err := tx.Bucket(cartsBucket).DeleteBucket([]byte(userID))
+		if err == bolt.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return new(pb.Empty), nil
+}
+
+// 修改商品数量
+func (s *boltCartStore) UpdateItemQuantity(ctx context.Context, userID, productID string, quantity int32, check CartCheck) (*pb.Empty, error) {
+	if quantity == 0 {
+		return s.RemoveItem(ctx, userID, productID)
+	}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		cart, err := tx.Bucket(cartsBucket).CreateBucketIfNotExists([]byte(userID))
+		if err != nil {
+			return err
+		}
+		items, err := readItems(cart)
+		if err != nil {
+			return err
+		}
+		items[productID] = quantity
+		if err := runCheck(check, userID, items); err != nil {
+			return err
+		}
+		return cart.Put([]byte(productID), encodeQuantity(quantity))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return new(pb.Empty), nil
+}
+
+// 移除商品，购物车为空时删除用户的bucket
+func (s *boltCartStore) RemoveItem(ctx context.Context, userID, productID string) (*pb.Empty, error) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		carts := tx.Bucket(cartsBucket)
+		cart := carts.Bucket([]byte(userID))
+		if cart == nil {
+			return nil
+		}
+		if err := cart.Delete([]byte(productID)); err != nil {
+			return err
+		}
+		if k, _ := cart.Cursor().First(); k == nil {
+			return carts.DeleteBucket([]byte(userID))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return new(pb.Empty), nil
+}
+
+// 合并购物车，在一个事务中完成，校验失败时事务回滚
+func (s *boltCartStore) MergeCarts(ctx context.Context, fromUserID, toUserID string, policy pb.MergePolicy, check CartCheck) (*pb.Cart, error) {
+	if fromUserID != toUserID {
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			carts := tx.Bucket(cartsBucket)
+			from := carts.Bucket([]byte(fromUserID))
+			if from == nil {
+				return nil
+			}
+			to, err := carts.CreateBucketIfNotExists([]byte(toUserID))
+			if err != nil {
+				return err
+			}
+			err = from.ForEach(func(k, v []byte) error {
+				current := to.Get(k)
+				merged, err := mergeQuantity(policy, decodeQuantity(current), decodeQuantity(v), current != nil)
+				if err != nil {
+					return err
+				}
+				return to.Put(k, encodeQuantity(merged))
+			})
+			if err != nil {
+				return err
+			}
+			items, err := readItems(to)
+			if err != nil {
+				return err
+			}
+			if err := runCheck(check, toUserID, items); err != nil {
+				return err
+			}
+			return carts.DeleteBucket([]byte(fromUserID))
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.GetCart(ctx, toUserID)
+}
+
+// 获得购物车
+func (s *boltCartStore) GetCart(ctx context.Context, userID string) (*pb.Cart, error) {
+	out := &pb.Cart{UserId: userID}
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cart := tx.Bucket(cartsBucket).Bucket([]byte(userID))
+		if cart == nil {
+			return nil
+		}
+		return cart.ForEach(func(k, v []byte) error {
+			out.Items = append(out.Items, &pb.CartItem{ProductId: string(k), Quantity: decodeQuantity(v)})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// 读取购物车中的所有商品，key为商品id
+func readItems(cart *bolt.Bucket) (map[string]int32, error) {
+	items := make(map[string]int32)
+	err := cart.ForEach(func(k, v []byte) error {
+		items[string(k)] = decodeQuantity(v)
+		return nil
+	})
+	return items, err
+}
+
+// 数量编码成4字节
+func encodeQuantity(q int32) []byte {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(q))
+	return b
+}
+
+// 解码数量，不存在时为0
+func decodeQuantity(b []byte) int32 {
+	if len(b) != 4 {
+		return 0
+	}
+	return int32(binary.BigEndian.Uint32(b))
+}
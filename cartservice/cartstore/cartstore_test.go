@@ -0,0 +1,323 @@
+package cartstore
+
+import (
+	"context"
+	"errors"
+	"math"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	pb "cartservice/proto"
+)
+
+// 被测试的存储，open每次返回一个空的存储
+type storeFactory struct {
+	name string
+	open func(t *testing.T) CartStore
+}
+
+// 所有存储都要通过同一组测试
+func storeFactories() []storeFactory {
+	return []storeFactory{
+		{"memory", func(t *testing.T) CartStore { return NewMemoryCartStore() }},
+		{"bolt", openBoltStore},
+		{"redis", openRedisStore},
+	}
+}
+
+// 在临时目录中创建bolt存储，测试结束时关闭
+func openBoltStore(t *testing.T) CartStore {
+	s, err := NewBoltCartStore(filepath.Join(t.TempDir(), "cart.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.(*boltCartStore).db.Close() })
+	return s
+}
+
+// 购物车中的商品，key为商品id
+func cartItems(t *testing.T, s CartStore, userID string) map[string]int32 {
+	t.Helper()
+	cart, err := s.GetCart(context.Background(), userID)
+	if err != nil {
+		t.Fatalf("GetCart(%s): %v", userID, err)
+	}
+	if cart.GetUserId() != userID {
+		t.Fatalf("GetCart(%s) 返回的用户为 %s", userID, cart.GetUserId())
+	}
+	items := make(map[string]int32, len(cart.GetItems()))
+	for _, item := range cart.GetItems() {
+		items[item.GetProductId()] = item.GetQuantity()
+	}
+	return items
+}
+
+// 检查购物车中的商品
+func wantItems(t *testing.T, s CartStore, userID string, want map[string]int32) {
+	t.Helper()
+	if got := cartItems(t, s, userID); !reflect.DeepEqual(got, want) {
+		t.Fatalf("%s 的购物车为 %v，期望 %v", userID, got, want)
+	}
+}
+
+// 添加商品，失败时结束测试
+func addItem(t *testing.T, s CartStore, userID, productID string, quantity int32) {
+	t.Helper()
+	if _, err := s.AddItem(context.Background(), userID, productID, quantity, new(pb.Empty), nil); err != nil {
+		t.Fatalf("AddItem(%s, %s, %d): %v", userID, productID, quantity, err)
+	}
+}
+
+var errTooMany = errors.New("购物车商品总数超出限制")
+
+// 商品总数不超过max的校验
+func maxTotal(max int32) CartCheck {
+	return func(cart *pb.Cart) error {
+		var total int32
+		for _, item := range cart.GetItems() {
+			total += item.GetQuantity()
+		}
+		if total > max {
+			return errTooMany
+		}
+		return nil
+	}
+}
+
+// 存储的行为测试
+var cartStoreTests = []struct {
+	name string
+	run  func(t *testing.T, s CartStore)
+}{
+	{"空购物车", func(t *testing.T, s CartStore) {
+		wantItems(t, s, "u1", map[string]int32{})
+	}},
+	{"添加商品累加数量", func(t *testing.T, s CartStore) {
+		addItem(t, s, "u1", "p1", 1)
+		addItem(t, s, "u1", "p1", 2)
+		addItem(t, s, "u1", "p2", 5)
+		wantItems(t, s, "u1", map[string]int32{"p1": 3, "p2": 5})
+		wantItems(t, s, "u2", map[string]int32{})
+	}},
+	{"清空购物车", func(t *testing.T, s CartStore) {
+		addItem(t, s, "u1", "p1", 1)
+		addItem(t, s, "u2", "p1", 1)
+		if _, err := s.EmptyCart(context.Background(), "u1"); err != nil {
+			t.Fatal(err)
+		}
+		wantItems(t, s, "u1", map[string]int32{})
+		wantItems(t, s, "u2", map[string]int32{"p1": 1})
+		// 清空不存在的购物车不报错
+		if _, err := s.EmptyCart(context.Background(), "u3"); err != nil {
+			t.Fatal(err)
+		}
+	}},
+	{"修改商品数量", func(t *testing.T, s CartStore) {
+		addItem(t, s, "u1", "p1", 1)
+		if _, err := s.UpdateItemQuantity(context.Background(), "u1", "p1", 7, nil); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.UpdateItemQuantity(context.Background(), "u1", "p2", 2, nil); err != nil {
+			t.Fatal(err)
+		}
+		wantItems(t, s, "u1", map[string]int32{"p1": 7, "p2": 2})
+	}},
+	{"数量为0时移除商品", func(t *testing.T, s CartStore) {
+		addItem(t, s, "u1", "p1", 1)
+		addItem(t, s, "u1", "p2", 1)
+		if _, err := s.UpdateItemQuantity(context.Background(), "u1", "p1", 0, nil); err != nil {
+			t.Fatal(err)
+		}
+		wantItems(t, s, "u1", map[string]int32{"p2": 1})
+		if _, err := s.UpdateItemQuantity(context.Background(), "u1", "p2", 0, nil); err != nil {
+			t.Fatal(err)
+		}
+		wantItems(t, s, "u1", map[string]int32{})
+	}},
+	{"移除商品", func(t *testing.T, s CartStore) {
+		addItem(t, s, "u1", "p1", 1)
+		addItem(t, s, "u1", "p2", 1)
+		if _, err := s.RemoveItem(context.Background(), "u1", "p1"); err != nil {
+			t.Fatal(err)
+		}
+		wantItems(t, s, "u1", map[string]int32{"p2": 1})
+		// 移除不存在的商品和购物车不报错
+		if _, err := s.RemoveItem(context.Background(), "u1", "p9"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.RemoveItem(context.Background(), "u9", "p1"); err != nil {
+			t.Fatal(err)
+		}
+		wantItems(t, s, "u1", map[string]int32{"p2": 1})
+	}},
+	{"合并到自己不修改购物车", func(t *testing.T, s CartStore) {
+		addItem(t, s, "u1", "p1", 2)
+		if _, err := s.MergeCarts(context.Background(), "u1", "u1", pb.MergePolicy_SUM, nil); err != nil {
+			t.Fatal(err)
+		}
+		wantItems(t, s, "u1", map[string]int32{"p1": 2})
+	}},
+	{"合并空购物车", func(t *testing.T, s CartStore) {
+		addItem(t, s, "u1", "p1", 2)
+		cart, err := s.MergeCarts(context.Background(), "anon", "u1", pb.MergePolicy_SUM, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cart.GetItems()) != 1 {
+			t.Fatalf("合并后的购物车为 %v", cart)
+		}
+		wantItems(t, s, "u1", map[string]int32{"p1": 2})
+	}},
+	{"校验失败时不写入", func(t *testing.T, s CartStore) {
+		ctx := context.Background()
+		addItem(t, s, "u1", "p1", 2)
+		if _, err := s.AddItem(ctx, "u1", "p1", 2, new(pb.Empty), maxTotal(3)); err != errTooMany {
+			t.Fatalf("AddItem返回 %v，期望 %v", err, errTooMany)
+		}
+		if _, err := s.UpdateItemQuantity(ctx, "u1", "p2", 2, maxTotal(3)); err != errTooMany {
+			t.Fatalf("UpdateItemQuantity返回 %v，期望 %v", err, errTooMany)
+		}
+		addItem(t, s, "anon", "p3", 2)
+		if _, err := s.MergeCarts(ctx, "anon", "u1", pb.MergePolicy_SUM, maxTotal(3)); err != errTooMany {
+			t.Fatalf("MergeCarts返回 %v，期望 %v", err, errTooMany)
+		}
+		wantItems(t, s, "u1", map[string]int32{"p1": 2})
+		wantItems(t, s, "anon", map[string]int32{"p3": 2})
+		// 满足校验时正常写入
+		if _, err := s.AddItem(ctx, "u1", "p1", 1, new(pb.Empty), maxTotal(3)); err != nil {
+			t.Fatal(err)
+		}
+		wantItems(t, s, "u1", map[string]int32{"p1": 3})
+	}},
+	{"数量超出int32", func(t *testing.T, s CartStore) {
+		addItem(t, s, "u1", "p1", math.MaxInt32)
+		if _, err := s.AddItem(context.Background(), "u1", "p1", 1, new(pb.Empty), nil); err != ErrQuantityOverflow {
+			t.Fatalf("AddItem返回 %v，期望 %v", err, ErrQuantityOverflow)
+		}
+		wantItems(t, s, "u1", map[string]int32{"p1": math.MaxInt32})
+	}},
+	{"合并后数量超出int32", func(t *testing.T, s CartStore) {
+		ctx := context.Background()
+		addItem(t, s, "u1", "p1", math.MaxInt32)
+		addItem(t, s, "anon", "p1", 1)
+		addItem(t, s, "anon", "p2", 1)
+		if _, err := s.MergeCarts(ctx, "anon", "u1", pb.MergePolicy_SUM, nil); err != ErrQuantityOverflow {
+			t.Fatalf("MergeCarts返回 %v，期望 %v", err, ErrQuantityOverflow)
+		}
+		wantItems(t, s, "u1", map[string]int32{"p1": math.MaxInt32})
+		wantItems(t, s, "anon", map[string]int32{"p1": 1, "p2": 1})
+		// 不相加的合并方式不会超出
+		if _, err := s.MergeCarts(ctx, "anon", "u1", pb.MergePolicy_MAX, nil); err != nil {
+			t.Fatal(err)
+		}
+		wantItems(t, s, "u1", map[string]int32{"p1": math.MaxInt32, "p2": 1})
+	}},
+}
+
+// 每种合并方式的结果，来源购物车{p1:5, p2:1}，目标购物车{p1:3, p3:4}
+var mergePolicyTests = []struct {
+	policy pb.MergePolicy
+	want   map[string]int32
+}{
+	{pb.MergePolicy_SUM, map[string]int32{"p1": 8, "p2": 1, "p3": 4}},
+	{pb.MergePolicy_MAX, map[string]int32{"p1": 5, "p2": 1, "p3": 4}},
+	{pb.MergePolicy_KEEP_TARGET, map[string]int32{"p1": 3, "p2": 1, "p3": 4}},
+}
+
+func TestCartStore(t *testing.T) {
+	for _, f := range storeFactories() {
+		f := f
+		t.Run(f.name, func(t *testing.T) {
+			for _, tt := range cartStoreTests {
+				tt := tt
+				t.Run(tt.name, func(t *testing.T) {
+					tt.run(t, f.open(t))
+				})
+			}
+		})
+	}
+}
+
+func TestCartStoreMergeCarts(t *testing.T) {
+	for _, f := range storeFactories() {
+		for _, tt := range mergePolicyTests {
+			f, tt := f, tt
+			t.Run(f.name+"/"+tt.policy.String(), func(t *testing.T) {
+				s := f.open(t)
+				addItem(t, s, "anon", "p1", 5)
+				addItem(t, s, "anon", "p2", 1)
+				addItem(t, s, "u1", "p1", 3)
+				addItem(t, s, "u1", "p3", 4)
+				cart, err := s.MergeCarts(context.Background(), "anon", "u1", tt.policy, nil)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(cart.GetItems()) != len(tt.want) {
+					t.Fatalf("合并后返回的购物车为 %v，期望 %v", cart.GetItems(), tt.want)
+				}
+				wantItems(t, s, "u1", tt.want)
+				// 来源购物车合并后清空
+				wantItems(t, s, "anon", map[string]int32{})
+			})
+		}
+	}
+}
+
+// 并发添加商品时，每次写入都在存储内校验，商品总数不会超出限制
+func TestCartStoreConcurrentLimit(t *testing.T) {
+	const (
+		limit   = 10
+		workers = 30
+	)
+	for _, f := range storeFactories() {
+		f := f
+		t.Run(f.name, func(t *testing.T) {
+			s := f.open(t)
+			var (
+				wg    sync.WaitGroup
+				added int32
+			)
+			for i := 0; i < workers; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := s.AddItem(context.Background(), "u1", "p1", 1, new(pb.Empty), maxTotal(limit))
+					if err == nil {
+						atomic.AddInt32(&added, 1)
+					}
+				}()
+			}
+			wg.Wait()
+			got := cartItems(t, s, "u1")["p1"]
+			if got > limit || got != added {
+				t.Fatalf("并发添加后的数量为 %d，成功添加%d次，限制为%d", got, added, limit)
+			}
+		})
+	}
+}
+
+func TestBoltCartStoreReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cart.db")
+	s, err := NewBoltCartStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addItem(t, s, "u1", "p1", 2)
+	addItem(t, s, "u1", "p2", 1)
+	if _, err := s.RemoveItem(context.Background(), "u1", "p2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.(*boltCartStore).db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = NewBoltCartStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.(*boltCartStore).db.Close()
+	wantItems(t, s, "u1", map[string]int32{"p1": 2})
+}
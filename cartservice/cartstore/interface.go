@@ -2,15 +2,56 @@ package cartstore
 
 import (
 	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	bolt "go.etcd.io/bbolt"
 
 	pb "cartservice/proto"
 )
 
+// 添加商品后数量超出int32
+var ErrQuantityOverflow = errors.New("商品数量超出范围")
+
+// 校验修改后的购物车，返回错误时不写入。
+// 存储在同一个锁、事务或乐观锁中读取购物车、校验并写入，并发的修改不会绕过校验，为nil时不校验
+type CartCheck func(cart *pb.Cart) error
+
 // 购物车接口
 type CartStore interface {
-	AddItem(ctx context.Context, userID, productID string, quantity int32, out *pb.Empty) (r *pb.Empty, err error)
+	// 添加商品，数量和已有的数量相加，超出int32时返回ErrQuantityOverflow
+	AddItem(ctx context.Context, userID, productID string, quantity int32, out *pb.Empty, check CartCheck) (r *pb.Empty, err error)
 	EmptyCart(ctx context.Context, userID string) (*pb.Empty, error)
 	GetCart(ctx context.Context, userID string) (*pb.Cart, error)
+	// 修改商品数量，数量为0时移除商品
+	UpdateItemQuantity(ctx context.Context, userID, productID string, quantity int32, check CartCheck) (*pb.Empty, error)
+	RemoveItem(ctx context.Context, userID, productID string) (*pb.Empty, error)
+	// 把fromUserID的购物车合并到toUserID，然后清空fromUserID的购物车，返回合并后的购物车。
+	// 相加超出int32时返回ErrQuantityOverflow，两个购物车都不修改
+	MergeCarts(ctx context.Context, fromUserID, toUserID string, policy pb.MergePolicy, check CartCheck) (*pb.Cart, error)
+}
+
+// 用修改后的商品校验购物车，key为商品id
+func runCheck(check CartCheck, userID string, items map[string]int32) error {
+	if check == nil {
+		return nil
+	}
+	cart := &pb.Cart{UserId: userID, Items: make([]*pb.CartItem, 0, len(items))}
+	for p, q := range items {
+		cart.Items = append(cart.Items, &pb.CartItem{ProductId: p, Quantity: q})
+	}
+	return check(cart)
+}
+
+// 添加后的数量，超出int32时返回ErrQuantityOverflow
+func addQuantity(current, quantity int32) (int32, error) {
+	sum := int64(current) + int64(quantity)
+	if sum > math.MaxInt32 || sum < math.MinInt32 {
+		return 0, ErrQuantityOverflow
+	}
+	return int32(sum), nil
 }
 
 // 实例化CartStore
@@ -18,4 +59,35 @@ func NewMemoryCartStore() CartStore {
 	return &memoryCartStore{
 		carts: make(map[string]map[string]int32),
 	}
-}
\ No newline at end of file
+}
+
+// 实例化基于bolt文件的CartStore，path为数据库文件路径
+func NewBoltCartStore(path string) (CartStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(cartsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltCartStore{db: db}, nil
+}
+
+// 实例化基于redis的CartStore，ttl为购物车闲置过期时间，0表示不过期
+func NewRedisCartStore(addr, password string, db int, ttl time.Duration) (CartStore, error) {
+	client := redis.NewClient(&redis.Options{
+		Addr:     addr,
+		Password: password,
+		DB:       db,
+	})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return &redisCartStore{client: client, ttl: ttl}, nil
+}
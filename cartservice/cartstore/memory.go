@@ -15,20 +15,18 @@ type memoryCartStore struct {
 }
 
 // 添加商品
-func (s *memoryCartStore) AddItem(ctx context.Context, userID, productID string, quantity int32, out *pb.Empty) (r *pb.Empty, err error) {
+func (s *memoryCartStore) AddItem(ctx context.Context, userID, productID string, quantity int32, out *pb.Empty, check CartCheck) (r *pb.Empty, err error) {
 	s.Lock()
 	defer s.Unlock()
 
-	if cart, ok := s.carts[userID]; ok {
-		if currentQuantity, ok := cart[productID]; ok {
-			cart[productID] = currentQuantity + quantity
-		} else {
-			cart[productID] = quantity
-		}
-		s.carts[userID] = cart
-	} else {
-		s.carts[userID] = map[string]int32{productID: quantity}
+	cart := copyItems(s.carts[userID])
+	if cart[productID], err = addQuantity(cart[productID], quantity); err != nil {
+		return nil, err
 	}
+	if err := runCheck(check, userID, cart); err != nil {
+		return nil, err
+	}
+	s.carts[userID] = cart
 	return out, nil
 }
 
@@ -41,6 +39,64 @@ func (s *memoryCartStore) EmptyCart(ctx context.Context, userID string) (out *pb
 	return out, nil
 }
 
+// 修改商品数量
+func (s *memoryCartStore) UpdateItemQuantity(ctx context.Context, userID, productID string, quantity int32, check CartCheck) (*pb.Empty, error) {
+	if quantity == 0 {
+		return s.RemoveItem(ctx, userID, productID)
+	}
+	s.Lock()
+	defer s.Unlock()
+
+	cart := copyItems(s.carts[userID])
+	cart[productID] = quantity
+	if err := runCheck(check, userID, cart); err != nil {
+		return nil, err
+	}
+	s.carts[userID] = cart
+	return new(pb.Empty), nil
+}
+
+// 移除商品
+func (s *memoryCartStore) RemoveItem(ctx context.Context, userID, productID string) (*pb.Empty, error) {
+	s.Lock()
+	defer s.Unlock()
+
+	if cart, ok := s.carts[userID]; ok {
+		delete(cart, productID)
+		if len(cart) == 0 {
+			delete(s.carts, userID)
+		}
+	}
+	return new(pb.Empty), nil
+}
+
+// 合并购物车
+func (s *memoryCartStore) MergeCarts(ctx context.Context, fromUserID, toUserID string, policy pb.MergePolicy, check CartCheck) (*pb.Cart, error) {
+	if fromUserID != toUserID {
+		s.Lock()
+		if from, ok := s.carts[fromUserID]; ok {
+			to := copyItems(s.carts[toUserID])
+			for p, q := range from {
+				current, inTarget := to[p]
+				merged, err := mergeQuantity(policy, current, q, inTarget)
+				if err != nil {
+					s.Unlock()
+					return nil, err
+				}
+				to[p] = merged
+			}
+			if err := runCheck(check, toUserID, to); err != nil {
+				s.Unlock()
+				return nil, err
+			}
+			s.carts[toUserID] = to
+			delete(s.carts, fromUserID)
+		}
+		s.Unlock()
+	}
+	return s.GetCart(ctx, toUserID)
+}
+
 // 获得购物车
 func (s *memoryCartStore) GetCart(ctx context.Context, userID string) (*pb.Cart, error) {
 	s.RLock()
@@ -55,3 +111,12 @@ func (s *memoryCartStore) GetCart(ctx context.Context, userID string) (*pb.Cart,
 	}
 	return &pb.Cart{UserId: userID}, nil
 }
+
+// 复制购物车，校验通过后才替换原来的购物车
+func copyItems(cart map[string]int32) map[string]int32 {
+	out := make(map[string]int32, len(cart)+1)
+	for p, q := range cart {
+		out[p] = q
+	}
+	return out
+}
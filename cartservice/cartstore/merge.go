@@ -0,0 +1,23 @@
+package cartstore
+
+import (
+	pb "cartservice/proto"
+)
+
+// 合并后的商品数量，inTarget表示目标购物车中是否已有该商品。相加超出int32时返回ErrQuantityOverflow，和AddItem一致
+func mergeQuantity(policy pb.MergePolicy, target, source int32, inTarget bool) (int32, error) {
+	if !inTarget {
+		return source, nil
+	}
+	switch policy {
+	case pb.MergePolicy_MAX:
+		if source > target {
+			return source, nil
+		}
+		return target, nil
+	case pb.MergePolicy_KEEP_TARGET:
+		return target, nil
+	default:
+		return addQuantity(target, source)
+	}
+}
@@ -0,0 +1,208 @@
+package cartstore
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+
+	pb "cartservice/proto"
+)
+
+// redis中购物车key的前缀，每个用户一个hash，field为商品id，value为数量
+const redisCartKeyPrefix = "cart:"
+
+// 购物车在读取和写入之间被其他请求修改时的最大重试次数
+const redisWatchRetries = 5
+
+// 数据保存在redis中的结构体，多个cartservice实例可以共享购物车
+type redisCartStore struct {
+	client *redis.Client
+	// 购物车闲置多久后过期，0表示不过期
+	ttl time.Duration
+}
+
+// 购物车key
+func redisCartKey(userID string) string {
+	return redisCartKeyPrefix + userID
+}
+
+// 添加商品，使用WATCH乐观锁读取、校验和写入
+func (s *redisCartStore) AddItem(ctx context.Context, userID, productID string, quantity int32, out *pb.Empty, check CartCheck) (r *pb.Empty, err error) {
+	key := redisCartKey(userID)
+	err = s.watch(ctx, func(tx *redis.Tx) error {
+		items, err := redisItems(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+		if items[productID], err = addQuantity(items[productID], quantity); err != nil {
+			return err
+		}
+		if err := runCheck(check, userID, items); err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, productID, items[productID])
+			s.touch(ctx, pipe, key)
+			return nil
+		})
+		return err
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// 清空购物车
+func (s *redisCartStore) EmptyCart(ctx context.Context, userID string) (out *pb.Empty, err error) {
+	if err := s.client.Del(ctx, redisCartKey(userID)).Err(); err != nil {
+		return nil, err
+	}
+	return new(pb.Empty), nil
+}
+
+// 修改商品数量，使用WATCH乐观锁读取、校验和写入
+func (s *redisCartStore) UpdateItemQuantity(ctx context.Context, userID, productID string, quantity int32, check CartCheck) (*pb.Empty, error) {
+	if quantity == 0 {
+		return s.RemoveItem(ctx, userID, productID)
+	}
+	key := redisCartKey(userID)
+	err := s.watch(ctx, func(tx *redis.Tx) error {
+		items, err := redisItems(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+		items[productID] = quantity
+		if err := runCheck(check, userID, items); err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, key, productID, quantity)
+			s.touch(ctx, pipe, key)
+			return nil
+		})
+		return err
+	}, key)
+	if err != nil {
+		return nil, err
+	}
+	return new(pb.Empty), nil
+}
+
+// 移除商品，hash中最后一个字段删除后redis会自动删除key
+func (s *redisCartStore) RemoveItem(ctx context.Context, userID, productID string) (*pb.Empty, error) {
+	key := redisCartKey(userID)
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HDel(ctx, key, productID)
+		s.touch(ctx, pipe, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return new(pb.Empty), nil
+}
+
+// 获得购物车，读取时同时刷新过期时间
+func (s *redisCartStore) GetCart(ctx context.Context, userID string) (*pb.Cart, error) {
+	key := redisCartKey(userID)
+	var fields *redis.StringStringMapCmd
+	_, err := s.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		fields = pipe.HGetAll(ctx, key)
+		s.touch(ctx, pipe, key)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	out := &pb.Cart{UserId: userID}
+	for p, v := range fields.Val() {
+		q, err := parseQuantity(v)
+		if err != nil {
+			return nil, err
+		}
+		out.Items = append(out.Items, &pb.CartItem{ProductId: p, Quantity: q})
+	}
+	return out, nil
+}
+
+// 合并购物车，使用WATCH乐观锁，两个购物车在合并过程中被修改时重试
+func (s *redisCartStore) MergeCarts(ctx context.Context, fromUserID, toUserID string, policy pb.MergePolicy, check CartCheck) (*pb.Cart, error) {
+	if fromUserID != toUserID {
+		fromKey, toKey := redisCartKey(fromUserID), redisCartKey(toUserID)
+		err := s.watch(ctx, func(tx *redis.Tx) error {
+			from, err := redisItems(ctx, tx, fromKey)
+			if err != nil || len(from) == 0 {
+				return err
+			}
+			to, err := redisItems(ctx, tx, toKey)
+			if err != nil {
+				return err
+			}
+			fields := make(map[string]interface{}, len(from))
+			for p, q := range from {
+				current, inTarget := to[p]
+				if to[p], err = mergeQuantity(policy, current, q, inTarget); err != nil {
+					return err
+				}
+				fields[p] = to[p]
+			}
+			if err := runCheck(check, toUserID, to); err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.HSet(ctx, toKey, fields)
+				pipe.Del(ctx, fromKey)
+				s.touch(ctx, pipe, toKey)
+				return nil
+			})
+			return err
+		}, fromKey, toKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s.GetCart(ctx, toUserID)
+}
+
+// 在WATCH乐观锁中执行fn，keys在执行过程中被其他请求修改时重试
+func (s *redisCartStore) watch(ctx context.Context, fn func(tx *redis.Tx) error, keys ...string) error {
+	var err error
+	for i := 0; i < redisWatchRetries; i++ {
+		if err = s.client.Watch(ctx, fn, keys...); err != redis.TxFailedErr {
+			break
+		}
+	}
+	return err
+}
+
+// 在事务中读取购物车中的所有商品，key为商品id
+func redisItems(ctx context.Context, tx *redis.Tx, key string) (map[string]int32, error) {
+	fields, err := tx.HGetAll(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string]int32, len(fields))
+	for p, v := range fields {
+		if items[p], err = parseQuantity(v); err != nil {
+			return nil, err
+		}
+	}
+	return items, nil
+}
+
+// 解析hash中保存的数量
+func parseQuantity(v string) (int32, error) {
+	q, err := strconv.ParseInt(v, 10, 32)
+	return int32(q), err
+}
+
+// 刷新购物车的过期时间
+func (s *redisCartStore) touch(ctx context.Context, pipe redis.Pipeliner, key string) {
+	if s.ttl > 0 {
+		pipe.Expire(ctx, key, s.ttl)
+	}
+}
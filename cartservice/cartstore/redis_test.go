@@ -0,0 +1,75 @@
+package cartstore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+// 测试中购物车的闲置过期时间
+const testCartTTL = time.Hour
+
+// 连接到新启动的miniredis的存储，购物车闲置testCartTTL后过期
+func openRedisStore(t *testing.T) CartStore {
+	s, _ := startRedisStore(t, testCartTTL)
+	return s
+}
+
+// 启动miniredis并连接，测试结束时关闭
+func startRedisStore(t *testing.T, ttl time.Duration) (CartStore, *miniredis.Miniredis) {
+	mr := miniredis.RunT(t)
+	s, err := NewRedisCartStore(mr.Addr(), "", 0, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.(*redisCartStore).client.Close() })
+	return s, mr
+}
+
+func TestRedisCartStoreTouch(t *testing.T) {
+	s, mr := startRedisStore(t, testCartTTL)
+	key := redisCartKey("u1")
+
+	addItem(t, s, "u1", "p1", 1)
+	if ttl := mr.TTL(key); ttl != testCartTTL {
+		t.Fatalf("添加商品后的过期时间为 %v，期望 %v", ttl, testCartTTL)
+	}
+
+	// 添加商品刷新过期时间
+	mr.FastForward(testCartTTL - time.Minute)
+	addItem(t, s, "u1", "p2", 1)
+	if ttl := mr.TTL(key); ttl != testCartTTL {
+		t.Fatalf("再次添加商品后的过期时间为 %v，期望 %v", ttl, testCartTTL)
+	}
+
+	// 读取购物车刷新过期时间
+	mr.FastForward(testCartTTL - time.Minute)
+	wantItems(t, s, "u1", map[string]int32{"p1": 1, "p2": 1})
+	if ttl := mr.TTL(key); ttl != testCartTTL {
+		t.Fatalf("读取购物车后的过期时间为 %v，期望 %v", ttl, testCartTTL)
+	}
+}
+
+func TestRedisCartStoreExpire(t *testing.T) {
+	s, mr := startRedisStore(t, testCartTTL)
+	addItem(t, s, "u1", "p1", 1)
+
+	mr.FastForward(testCartTTL)
+	if mr.Exists(redisCartKey("u1")) {
+		t.Fatal("购物车闲置超过过期时间后没有删除")
+	}
+	wantItems(t, s, "u1", map[string]int32{})
+}
+
+func TestRedisCartStoreNoTTL(t *testing.T) {
+	s, mr := startRedisStore(t, 0)
+	addItem(t, s, "u1", "p1", 1)
+
+	// ttl为0时购物车不过期
+	mr.FastForward(24 * time.Hour)
+	if ttl := mr.TTL(redisCartKey("u1")); ttl != 0 {
+		t.Fatalf("购物车的过期时间为 %v，期望不过期", ttl)
+	}
+	wantItems(t, s, "u1", map[string]int32{"p1": 1})
+}
@@ -4,17 +4,33 @@ import (
 	"cartservice/cartstore"
 	pb "cartservice/proto"
 	"context"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 // 购物车服务结构体
 type CartService struct {
 	Store cartstore.CartStore
+	// 校验规则，为nil时只做基本校验
+	Validator *CartValidator
 }
 
 // 添加商品调用cartstore
 func (s *CartService) AddItem(ctx context.Context, in *pb.AddItemRequest) (out *pb.Empty, err error) {
+	cart, err := s.Store.GetCart(ctx, in.UserId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "获得购物车失败: %v", err)
+	}
+	if err := s.validator().ValidateAddItem(ctx, cart, in.GetItem().GetProductId(), in.GetItem().GetQuantity()); err != nil {
+		return nil, err
+	}
+	// 上面按读取的购物车给出具体的错误，写入时存储再校验一次，并发的请求不会超出限制
 	out = new(pb.Empty)
-	return s.Store.AddItem(ctx, in.UserId, in.Item.ProductId, in.Item.Quantity, out)
+	if _, err := s.Store.AddItem(ctx, in.UserId, in.Item.ProductId, in.Item.Quantity, out, s.validator().ValidateCart); err != nil {
+		return nil, storeError(err, "添加商品失败")
+	}
+	return out, nil
 }
 
 // 获得购物车，调用cartstore
@@ -33,3 +49,68 @@ func (s *CartService) GetCart(ctx context.Context, in *pb.GetCartRequest) (out *
 func (s *CartService) EmptyCart(ctx context.Context, in *pb.EmptyCartRequest) (out *pb.Empty, err error) {
 	return s.Store.EmptyCart(ctx, in.UserId)
 }
+
+// 修改商品数量，调用cartstore
+func (s *CartService) UpdateItemQuantity(ctx context.Context, in *pb.UpdateItemQuantityRequest) (out *pb.Empty, err error) {
+	cart, err := s.Store.GetCart(ctx, in.UserId)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "获得购物车失败: %v", err)
+	}
+	if err := s.validator().ValidateUpdateItemQuantity(ctx, cart, in.GetItem().GetProductId(), in.GetItem().GetQuantity()); err != nil {
+		return nil, err
+	}
+	out, err = s.Store.UpdateItemQuantity(ctx, in.UserId, in.GetItem().GetProductId(), in.GetItem().GetQuantity(), s.validator().ValidateCart)
+	if err != nil {
+		return nil, storeError(err, "修改商品数量失败")
+	}
+	return out, nil
+}
+
+// 移除商品，调用cartstore
+func (s *CartService) RemoveItem(ctx context.Context, in *pb.RemoveItemRequest) (out *pb.Empty, err error) {
+	if in.UserId == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "用户id不能为空")
+	}
+	if in.ProductId == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "商品id不能为空")
+	}
+	out, err = s.Store.RemoveItem(ctx, in.UserId, in.ProductId)
+	if err != nil {
+		return nil, storeError(err, "移除商品失败")
+	}
+	return out, nil
+}
+
+// 合并购物车，调用cartstore，合并后的购物车超出校验规则时不合并，校验在存储写入时进行
+func (s *CartService) MergeCarts(ctx context.Context, in *pb.MergeCartsRequest) (out *pb.Cart, err error) {
+	if in.FromUserId == "" || in.ToUserId == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "用户id不能为空")
+	}
+	if _, ok := pb.MergePolicy_name[int32(in.Policy)]; !ok {
+		return nil, status.Errorf(codes.InvalidArgument, "不支持的合并方式: %d", in.Policy)
+	}
+	out, err = s.Store.MergeCarts(ctx, in.FromUserId, in.ToUserId, in.Policy, s.validator().ValidateCart)
+	if err != nil {
+		return nil, storeError(err, "合并购物车失败")
+	}
+	return out, nil
+}
+
+// 存储返回的错误，校验返回的status错误原样返回
+func storeError(err error, msg string) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+	if err == cartstore.ErrQuantityOverflow {
+		return status.Errorf(codes.FailedPrecondition, "%s: %v", msg, err)
+	}
+	return status.Errorf(codes.Internal, "%s: %v", msg, err)
+}
+
+// 校验规则
+func (s *CartService) validator() *CartValidator {
+	if s.Validator == nil {
+		return &CartValidator{}
+	}
+	return s.Validator
+}
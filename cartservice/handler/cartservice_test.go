@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"cartservice/cartstore"
+	pb "cartservice/proto"
+)
+
+// 并发添加商品时不会超出购物车商品总数的限制
+func TestAddItemConcurrentLimit(t *testing.T) {
+	const limit = 10
+	s := &CartService{
+		Store:     cartstore.NewMemoryCartStore(),
+		Validator: &CartValidator{MaxCartQuantity: limit},
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 3*limit)
+	for i := 0; i < 3*limit; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := s.AddItem(context.Background(), &pb.AddItemRequest{
+				UserId: "u1",
+				Item:   &pb.CartItem{ProductId: "p1", Quantity: 1},
+			})
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	added := 0
+	for err := range errs {
+		if err == nil {
+			added++
+		} else if status.Code(err) != codes.FailedPrecondition {
+			t.Fatalf("超出限制时返回 %v，期望FailedPrecondition", err)
+		}
+	}
+	cart, err := s.GetCart(context.Background(), &pb.GetCartRequest{UserId: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != limit || len(cart.GetItems()) != 1 || cart.GetItems()[0].GetQuantity() != limit {
+		t.Fatalf("成功添加%d次，购物车为 %v，限制为%d", added, cart.GetItems(), limit)
+	}
+}
+
+func TestMergeCartsOverLimit(t *testing.T) {
+	s := &CartService{
+		Store:     cartstore.NewMemoryCartStore(),
+		Validator: &CartValidator{MaxItemQuantity: 5},
+	}
+	ctx := context.Background()
+	for _, user := range []string{"anon", "u1"} {
+		if _, err := s.AddItem(ctx, &pb.AddItemRequest{UserId: user, Item: &pb.CartItem{ProductId: "p1", Quantity: 3}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, err := s.MergeCarts(ctx, &pb.MergeCartsRequest{FromUserId: "anon", ToUserId: "u1", Policy: pb.MergePolicy_SUM})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Fatalf("合并后超出限制时返回 %v，期望FailedPrecondition", err)
+	}
+	// 按最大值合并不超出限制
+	cart, err := s.MergeCarts(ctx, &pb.MergeCartsRequest{FromUserId: "anon", ToUserId: "u1", Policy: pb.MergePolicy_MAX})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cart.GetItems()) != 1 || cart.GetItems()[0].GetQuantity() != 3 {
+		t.Fatalf("合并后的购物车为 %v", cart.GetItems())
+	}
+}
+
+func TestRemoveItem(t *testing.T) {
+	s := &CartService{Store: cartstore.NewMemoryCartStore()}
+	ctx := context.Background()
+	for _, id := range []string{"p1", "p2"} {
+		if _, err := s.AddItem(ctx, &pb.AddItemRequest{UserId: "u1", Item: &pb.CartItem{ProductId: id, Quantity: 1}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, in := range []*pb.RemoveItemRequest{
+		{UserId: "", ProductId: "p1"},
+		{UserId: "u1", ProductId: ""},
+		{},
+	} {
+		if _, err := s.RemoveItem(ctx, in); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("RemoveItem(%v) 返回 %v，期望InvalidArgument", in, err)
+		}
+	}
+	if _, err := s.RemoveItem(ctx, &pb.RemoveItemRequest{UserId: "u1", ProductId: "p1"}); err != nil {
+		t.Fatal(err)
+	}
+	cart, err := s.GetCart(ctx, &pb.GetCartRequest{UserId: "u1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cart.GetItems()) != 1 || cart.GetItems()[0].GetProductId() != "p2" {
+		t.Fatalf("移除后的购物车为 %v", cart.GetItems())
+	}
+}
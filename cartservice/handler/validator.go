@@ -0,0 +1,119 @@
+package handler
+
+import (
+	pb "cartservice/proto"
+	"context"
+	"math"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 购物车校验规则，数值为0表示不限制
+type CartValidator struct {
+	// 单个商品的最大数量
+	MaxItemQuantity int32
+	// 购物车中不同商品的最大个数
+	MaxCartItems int
+	// 购物车商品总数的上限
+	MaxCartQuantity int32
+	// 不为nil时，校验商品是否存在于商品分类服务
+	ProductCatalogService pb.ProductCatalogServiceClient
+}
+
+// 校验添加商品，cart为添加前的购物车
+func (v *CartValidator) ValidateAddItem(ctx context.Context, cart *pb.Cart, productID string, quantity int32) error {
+	if quantity <= 0 {
+		return status.Errorf(codes.InvalidArgument, "商品数量必须大于0: %d", quantity)
+	}
+	return v.validateLine(ctx, cart, productID, quantity, int64(quantity)+int64(itemQuantity(cart, productID)))
+}
+
+// 校验修改商品数量，cart为修改前的购物车
+func (v *CartValidator) ValidateUpdateItemQuantity(ctx context.Context, cart *pb.Cart, productID string, quantity int32) error {
+	if quantity < 0 {
+		return status.Errorf(codes.InvalidArgument, "商品数量不能为负数: %d", quantity)
+	}
+	if quantity == 0 {
+		return nil
+	}
+	return v.validateLine(ctx, cart, productID, quantity, int64(quantity))
+}
+
+// 校验商品修改后的数量是否满足规则，requested为请求中的数量，newQuantity为修改后的数量，使用int64避免int32溢出。
+// 请求本身不合法时返回InvalidArgument，和购物车中已有商品合计后超出限制时返回FailedPrecondition
+func (v *CartValidator) validateLine(ctx context.Context, cart *pb.Cart, productID string, requested int32, newQuantity int64) error {
+	if productID == "" {
+		return status.Errorf(codes.InvalidArgument, "商品id不能为空")
+	}
+	if v.MaxItemQuantity > 0 && requested > v.MaxItemQuantity {
+		return status.Errorf(codes.InvalidArgument, "单个商品数量不能超过%d", v.MaxItemQuantity)
+	}
+	if v.MaxItemQuantity > 0 && newQuantity > int64(v.MaxItemQuantity) {
+		return status.Errorf(codes.FailedPrecondition, "购物车中该商品已有%d件，单个商品数量不能超过%d", newQuantity-int64(requested), v.MaxItemQuantity)
+	}
+	if newQuantity > math.MaxInt32 {
+		return status.Errorf(codes.FailedPrecondition, "商品数量过大: %d", newQuantity)
+	}
+
+	inCart := false
+	total := newQuantity
+	for _, item := range cart.GetItems() {
+		if item.GetProductId() == productID {
+			inCart = true
+			continue
+		}
+		total += int64(item.GetQuantity())
+	}
+	if !inCart && v.MaxCartItems > 0 && len(cart.GetItems()) >= v.MaxCartItems {
+		return status.Errorf(codes.FailedPrecondition, "购物车最多只能有%d种商品", v.MaxCartItems)
+	}
+	if v.MaxCartQuantity > 0 && total > int64(v.MaxCartQuantity) {
+		return status.Errorf(codes.FailedPrecondition, "购物车商品总数不能超过%d", v.MaxCartQuantity)
+	}
+
+	if !inCart && v.ProductCatalogService != nil {
+		return v.checkProduct(ctx, productID)
+	}
+	return nil
+}
+
+// 校验整个购物车是否满足数量规则，作为CartCheck在存储写入前调用
+func (v *CartValidator) ValidateCart(cart *pb.Cart) error {
+	if v.MaxCartItems > 0 && len(cart.GetItems()) > v.MaxCartItems {
+		return status.Errorf(codes.FailedPrecondition, "购物车最多只能有%d种商品", v.MaxCartItems)
+	}
+	var total int64
+	for _, item := range cart.GetItems() {
+		if v.MaxItemQuantity > 0 && item.GetQuantity() > v.MaxItemQuantity {
+			return status.Errorf(codes.FailedPrecondition, "单个商品数量不能超过%d", v.MaxItemQuantity)
+		}
+		total += int64(item.GetQuantity())
+	}
+	if v.MaxCartQuantity > 0 && total > int64(v.MaxCartQuantity) {
+		return status.Errorf(codes.FailedPrecondition, "购物车商品总数不能超过%d", v.MaxCartQuantity)
+	}
+	return nil
+}
+
+// 校验商品是否存在
+func (v *CartValidator) checkProduct(ctx context.Context, productID string) error {
+	_, err := v.ProductCatalogService.GetProduct(ctx, &pb.GetProductRequest{Id: productID})
+	if err == nil {
+		return nil
+	}
+	if status.Code(err) == codes.NotFound {
+		return status.Errorf(codes.InvalidArgument, "商品不存在: %s", productID)
+	}
+	return status.Errorf(codes.Unavailable, "查询商品失败: %v", err)
+}
+
+// 购物车中商品的当前数量
+func itemQuantity(cart *pb.Cart, productID string) int32 {
+	for _, item := range cart.GetItems() {
+		if item.GetProductId() == productID {
+			return item.GetQuantity()
+		}
+	}
+	return 0
+}
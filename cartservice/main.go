@@ -4,19 +4,81 @@ import (
 	"cartservice/cartstore"
 	handler "cartservice/handler"
 	pb "cartservice/proto"
+	"flag"
 	"fmt"
 	"net"
 	"strconv"
+	"time"
 
 	"github.com/hashicorp/consul/api"
 	"google.golang.org/grpc"
 )
 
+// 从consul获取健康的服务并连接，没有健康的服务时返回错误
+func GetGrpcConn(consulClient *api.Client, serviceName string, serviceTag string) (*grpc.ClientConn, error) {
+	service, _, err_service := consulClient.Health().Service(serviceName, serviceTag, true, nil)
+	if err_service != nil {
+		return nil, fmt.Errorf("获取健康服务报错: %v", err_service)
+	}
+	if len(service) == 0 {
+		return nil, fmt.Errorf("没有健康的服务: %s", serviceName)
+	}
+	s := service[0].Service
+	address := s.Address + ":" + strconv.Itoa(s.Port)
+	fmt.Printf("serviceName: %v\n", serviceName)
+
+	fmt.Printf("address:%s\n", address)
+
+	//链接grpc服务
+	return grpc.Dial(address, grpc.WithInsecure())
+}
+
 const PORT = 50011
 const ADDRESS = "127.0.0.1"
 
+// 购物车存储方式：memory 内存，bolt 本地文件，redis 多个实例共享
+var (
+	storeType     = flag.String("store", "memory", "购物车存储方式: memory、bolt 或 redis")
+	boltPath      = flag.String("bolt-path", "cart.db", "bolt数据库文件路径")
+	redisAddr     = flag.String("redis-addr", "127.0.0.1:6379", "redis地址")
+	redisPassword = flag.String("redis-password", "", "redis密码")
+	redisDB       = flag.Int("redis-db", 0, "redis数据库编号")
+	cartTTL       = flag.Duration("cart-ttl", 48*time.Hour, "购物车闲置过期时间，0表示不过期")
+)
+
+// 购物车校验规则，0表示不限制
+var (
+	maxItemQuantity = flag.Int("max-item-quantity", 99, "单个商品的最大数量")
+	maxCartItems    = flag.Int("max-cart-items", 50, "购物车中不同商品的最大个数")
+	maxCartQuantity = flag.Int("max-cart-quantity", 999, "购物车商品总数的上限")
+	checkProducts   = flag.Bool("check-products", false, "是否到商品分类服务校验商品是否存在")
+)
+
+// 根据启动参数创建购物车存储
+func newCartStore() (cartstore.CartStore, error) {
+	switch *storeType {
+	case "memory":
+		return cartstore.NewMemoryCartStore(), nil
+	case "bolt":
+		return cartstore.NewBoltCartStore(*boltPath)
+	case "redis":
+		return cartstore.NewRedisCartStore(*redisAddr, *redisPassword, *redisDB, *cartTTL)
+	default:
+		return nil, fmt.Errorf("不支持的存储方式: %s", *storeType)
+	}
+}
+
 func main() {
+	flag.Parse()
 	ipport := ADDRESS + ":" + strconv.Itoa(PORT)
+
+	// 初始化购物车存储
+	store, err_store := newCartStore()
+	if err_store != nil {
+		fmt.Println("初始化购物车存储报错：", err_store)
+		return
+	}
+
 	// ----------注册到consul上-------------
 	// 初始化consul配置
 	consulConfig := api.DefaultConfig()
@@ -47,8 +109,23 @@ func main() {
 	// 初始化grpc对象
 	grpcServer := grpc.NewServer()
 
+	// 校验规则
+	validator := &handler.CartValidator{
+		MaxItemQuantity: int32(*maxItemQuantity),
+		MaxCartItems:    *maxCartItems,
+		MaxCartQuantity: int32(*maxCartQuantity),
+	}
+	if *checkProducts {
+		conn, err_conn := GetGrpcConn(consulClient, "productcatalogservice", "productcatalogservice")
+		if err_conn != nil {
+			fmt.Println("连接商品分类服务报错：", err_conn)
+			return
+		}
+		validator.ProductCatalogService = pb.NewProductCatalogServiceClient(conn)
+	}
+
 	// 注册服务
-	pb.RegisterCartServiceServer(grpcServer, &handler.CartService{Store: cartstore.NewMemoryCartStore()})
+	pb.RegisterCartServiceServer(grpcServer, &handler.CartService{Store: store, Validator: validator})
 
 	// 设置监听
 	listien, err := net.Listen("tcp", ipport)
@@ -1,3 +1,10 @@
 module checkoutservice
 
 go 1.19
+
+require (
+	go.etcd.io/bbolt v1.3.7
+	golang.org/x/sync v0.3.0
+)
+
+require golang.org/x/sys v0.4.0 // indirect
@@ -1,24 +1,43 @@
 package handler
 
 import (
-	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"sync"
+	"time"
 
 	"github.com/google/uuid"
+	"golang.org/x/sync/errgroup"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
-	"checkoutservice/money"
+	"checkoutservice/orderstore"
+	"checkoutservice/promotion"
 	pb "checkoutservice/proto"
+	"checkoutservice/tax"
+	"money"
 )
 
-// 日志
-var (
-	buf    bytes.Buffer
-	logger = log.New(&buf, "logger: ", log.LstdFlags)
+// 日志，输出到标准错误，补偿失败等需要人工处理的错误要能被运维看到
+var logger = log.New(os.Stderr, "logger: ", log.LstdFlags)
+
+// 发送确认信息的重试次数和间隔
+const (
+	emailRetries      = 3
+	emailRetryBackoff = 100 * time.Millisecond
+)
+
+// 准备订单时同时查询商品的最大请求数
+const prepConcurrency = 8
+
+// 查询订单时每页的默认数量和最大数量
+const (
+	defaultOrderPageSize = 10
+	maxOrderPageSize     = 50
 )
 
 type CheckoutService struct {
@@ -28,49 +47,139 @@ type CheckoutService struct {
 	PaymentService        pb.PaymentServiceClient
 	ProductCatalogService pb.ProductCatalogServiceClient
 	ShippingService       pb.ShippingServiceClient
+	// 不为nil时，带幂等键的重复下单请求返回第一次下单的结果
+	Idempotency *IdempotencyStore
+	// 保存下单成功的订单
+	Orders orderstore.OrderStore
+	// 下单时使用的优惠，为nil表示没有优惠
+	Promotions *promotion.Engine
+	// 按配送地址计算税费，为nil表示不收税
+	Tax *tax.Calculator
 }
 
-// 下订单
+// 下订单，请求带幂等键时，重复的请求不会再次付款和配送
 func (s *CheckoutService) PlaceOrder(ctx context.Context, in *pb.PlaceOrderRequest) (out *pb.PlaceOrderResponse, e error) {
-	logger.Printf("[PlaceOrder] user_id=%q user_currency=%q", in.UserId, in.UserCurrency)
+	logger.Printf("[PlaceOrder] user_id=%q user_currency=%q idempotency_key=%q", in.UserId, in.UserCurrency, in.IdempotencyKey)
 
-	out = new(pb.PlaceOrderResponse)
-	orderID, err := uuid.NewUUID()
+	if in.IdempotencyKey == "" || s.Idempotency == nil {
+		return s.placeOrder(ctx, in)
+	}
+	key := idempotencyKey(in.UserId, in.IdempotencyKey)
+	hash, err := requestHash(in)
 	if err != nil {
-		log.Fatal(err)
-		return nil, status.Errorf(codes.Internal, "生成订单id失败")
+		return nil, status.Errorf(codes.Internal, "计算请求摘要失败: %v", err)
+	}
+	for {
+		entry, first := s.Idempotency.begin(key, hash)
+		if first {
+			out, err := s.placeIdempotentOrder(ctx, key, hash, in)
+			s.Idempotency.finish(key, entry, out.GetOrder())
+			return out, err
+		}
+		if entry.hash != hash {
+			return nil, errIdempotencyKeyReused
+		}
+		// 相同的请求正在处理，等待处理完成
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		if entry.order != nil {
+			logger.Printf("[PlaceOrder] 重复请求，返回已有订单 order_id=%q", entry.order.GetOrderId())
+			return &pb.PlaceOrderResponse{Order: entry.order}, nil
+		}
+		// 第一次请求失败，重新下单
 	}
+}
 
-	prep, err := s.prepareOrderItemsAndShippingQuoteFromCart(ctx, in.UserId, in.UserCurrency, in.Address)
+// 购物车中商品的数量不是正数
+var errInvalidQuantity = errors.New("商品数量必须大于0")
+
+// 幂等键用于内容不同的下单请求
+var errIdempotencyKeyReused = status.Errorf(codes.InvalidArgument, "幂等键已用于其他下单请求")
+
+// 订单存储中有未过期的幂等键时返回原来的订单，否则下单并保存幂等键
+func (s *CheckoutService) placeIdempotentOrder(ctx context.Context, key, hash string, in *pb.PlaceOrderRequest) (*pb.PlaceOrderResponse, error) {
+	record, err := s.Orders.GetIdempotencyKey(ctx, key, time.Now())
+	if err == nil {
+		if record.RequestHash != hash {
+			return nil, errIdempotencyKeyReused
+		}
+		order, err := s.Orders.GetOrder(ctx, record.OrderID)
+		if err != nil {
+			return nil, status.Errorf(codes.Internal, "查询幂等键对应的订单失败: %v", err)
+		}
+		logger.Printf("[PlaceOrder] 重复请求，返回已有订单 order_id=%q", record.OrderID)
+		return &pb.PlaceOrderResponse{Order: order.GetOrder()}, nil
+	}
+	if err != orderstore.ErrIdempotencyKeyNotFound {
+		return nil, status.Errorf(codes.Internal, "查询幂等键失败: %v", err)
+	}
+
+	out, err := s.placeOrder(ctx, in)
+	if err != nil {
+		return nil, err
+	}
+	// 订单已经完成，保存失败时只记录日志
+	err = s.Orders.SaveIdempotencyKey(ctx, key, &orderstore.IdempotencyRecord{
+		OrderID:     out.GetOrder().GetOrderId(),
+		RequestHash: hash,
+		Expires:     time.Now().Add(s.Idempotency.window),
+	})
 	if err != nil {
-		log.Fatal(err)
-		return nil, status.Errorf(codes.Internal, err.Error())
+		logger.Printf("保存幂等键失败 order_id=%q: %+v", out.GetOrder().GetOrderId(), err)
 	}
+	return out, nil
+}
 
-	total := &pb.Money{CurrencyCode: in.UserCurrency, Units: 0, Nanos: 0}
-	total = money.Must(money.Sum(total, prep.shippingCostLocalized))
-	for _, it := range prep.orderItems {
-		multPrice := money.MultiplySlow(it.Cost, uint32(it.GetItem().GetQuantity()))
-		total = money.Must(money.Sum(total, multPrice))
+// 下订单，清空购物车、付款、配送按saga执行，某一步失败时撤销已经完成的步骤
+func (s *CheckoutService) placeOrder(ctx context.Context, in *pb.PlaceOrderRequest) (out *pb.PlaceOrderResponse, e error) {
+	out = new(pb.PlaceOrderResponse)
+	orderID, err := uuid.NewUUID()
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "生成订单id失败: %v", err)
 	}
 
-	txID, err := s.chargeCard(ctx, total, in.CreditCard)
+	prep, err := s.prepareOrderItemsAndShippingQuoteFromCart(ctx, in.UserId, in.UserCurrency, in.Address)
+	if ctx.Err() != nil {
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
 	if err != nil {
-		log.Fatal(err)
-		return nil, status.Errorf(codes.Internal, "更改卡失败: %+v", err)
+		return nil, status.Errorf(codes.Internal, "%v", err)
+	}
+	if len(prep.cartItems) == 0 {
+		return nil, status.Errorf(codes.FailedPrecondition, "购物车是空的")
 	}
-	logger.Printf("付款交易 (transaction_id: %s)", txID)
 
-	shippingTrackingID, err := s.shipOrder(ctx, in.Address, prep.cartItems)
+	price, err := s.priceOrder(ctx, in.UserCurrency, in.PromoCode, in.Address, prep)
 	if err != nil {
-		log.Fatal(err)
-		return nil, status.Errorf(codes.Unavailable, "配送错误: %+v", err)
+		return nil, priceOrderError(err)
 	}
 
-	if err := s.emptyUserCart(ctx, in.UserId); err != nil {
-		logger.Printf("清空用户购物车失败： %s: %+v", in.UserId, err)
-		log.Fatal(err)
+	var txID, shippingTrackingID string
+	order := new(saga)
+	// 先清空购物车，避免同一个购物车重复下单，失败时恢复购物车
+	order.add("清空购物车", func(ctx context.Context) error {
+		return s.emptyUserCart(ctx, in.UserId)
+	}, func(ctx context.Context) error {
+		return s.restoreUserCart(ctx, in.UserId, prep.cartItems)
+	})
+	order.add("付款", func(ctx context.Context) (err error) {
+		txID, err = s.chargeCard(ctx, price.total, in.CreditCard)
+		return err
+	}, func(ctx context.Context) error {
+		return s.refund(ctx, txID)
+	})
+	// 配送成功后订单不再撤销
+	order.add("配送", func(ctx context.Context) (err error) {
+		shippingTrackingID, err = s.shipOrder(ctx, in.Address, prep.cartItems)
+		return err
+	}, nil)
+	if err := order.run(ctx); err != nil {
+		return nil, err
 	}
+	logger.Printf("付款交易 (transaction_id: %s)", txID)
 
 	orderResult := &pb.OrderResult{
 		OrderId:            orderID.String(),
@@ -78,50 +187,264 @@ func (s *CheckoutService) PlaceOrder(ctx context.Context, in *pb.PlaceOrderReque
 		ShippingCost:       prep.shippingCostLocalized,
 		ShippingAddress:    in.Address,
 		Items:              prep.orderItems,
+		Promotions:         price.promotions,
+		Tax:                price.tax,
+		Total:              price.total,
 	}
 
+	// 订单已经配送，保存失败时只记录日志
+	// 下单时间使用货币转换的时间，按这个时间可以查到下单时的汇率
+	err = s.Orders.SaveOrder(ctx, &pb.Order{
+		Order:       orderResult,
+		UserId:      in.UserId,
+		CreatedAt:   prep.rates.asOf,
+		TotalPaid:   price.total,
+		RateVersion: prep.rates.rateVersion(),
+	})
+	if err != nil {
+		logger.Printf("保存订单失败 order_id=%q: %+v", orderResult.OrderId, err)
+	}
+
+	// 订单已经完成，确认信息发送失败不影响下单结果
 	if err := s.sendOrderConfirmation(ctx, in.Email, orderResult); err != nil {
 		logger.Printf("发送订单确认信息失败： %q: %+v", in.Email, err)
-		log.Fatal(err)
 	} else {
 		logger.Printf("订单确认信息发送成功： %q", in.Email)
-		log.Printf(in.Email)
 	}
 	out.Order = orderResult
 	return out, nil
 }
 
+// 查询订单
+func (s *CheckoutService) GetOrder(ctx context.Context, in *pb.GetOrderRequest) (*pb.Order, error) {
+	logger.Printf("[GetOrder] order_id=%q user_id=%q", in.OrderId, in.UserId)
+	if in.OrderId == "" || in.UserId == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "订单id和用户id不能为空")
+	}
+	order, err := s.Orders.GetOrder(ctx, in.OrderId)
+	if err == orderstore.ErrOrderNotFound {
+		return nil, status.Errorf(codes.NotFound, "订单不存在: %s", in.OrderId)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "查询订单失败: %v", err)
+	}
+	// 其他用户的订单当作不存在
+	if in.UserId != order.UserId {
+		return nil, status.Errorf(codes.NotFound, "订单不存在: %s", in.OrderId)
+	}
+	return order, nil
+}
+
+// 分页查询用户的订单
+func (s *CheckoutService) ListOrders(ctx context.Context, in *pb.ListOrdersRequest) (*pb.ListOrdersResponse, error) {
+	logger.Printf("[ListOrders] user_id=%q page=%d page_size=%d", in.UserId, in.Page, in.PageSize)
+	if in.UserId == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "用户id不能为空")
+	}
+	if in.Page < 0 || in.PageSize < 0 {
+		return nil, status.Errorf(codes.InvalidArgument, "页码和每页数量不能为负数")
+	}
+	page, pageSize := int(in.Page), int(in.PageSize)
+	if page == 0 {
+		page = 1
+	}
+	if pageSize == 0 {
+		pageSize = defaultOrderPageSize
+	}
+	if pageSize > maxOrderPageSize {
+		pageSize = maxOrderPageSize
+	}
+	orders, total, err := s.Orders.ListOrders(ctx, in.UserId, (page-1)*pageSize, pageSize)
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "查询订单失败: %v", err)
+	}
+	return &pb.ListOrdersResponse{Orders: orders, TotalCount: int32(total)}, nil
+}
+
+// 计算购物车的订单金额，不付款也不配送
+func (s *CheckoutService) PreviewOrder(ctx context.Context, in *pb.PreviewOrderRequest) (*pb.PreviewOrderResponse, error) {
+	logger.Printf("[PreviewOrder] user_id=%q user_currency=%q", in.UserId, in.UserCurrency)
+	if in.UserCurrency == "" {
+		return nil, status.Errorf(codes.InvalidArgument, "货币不能为空")
+	}
+
+	prep, err := s.prepareOrderItemsAndShippingQuoteFromCart(ctx, in.UserId, in.UserCurrency, in.Address)
+	if ctx.Err() != nil {
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "%v", err)
+	}
+	price, err := s.priceOrder(ctx, in.UserCurrency, in.PromoCode, in.Address, prep)
+	if err != nil {
+		return nil, priceOrderError(err)
+	}
+	return &pb.PreviewOrderResponse{
+		Items:        prep.orderItems,
+		Subtotal:     price.subtotal,
+		ShippingCost: price.shipping,
+		Discount:     price.discount,
+		Tax:          price.tax,
+		Total:        price.total,
+		Promotions:   price.promotions,
+	}, nil
+}
+
+// 订单金额，都使用用户的货币
+type orderPrice struct {
+	// 商品合计
+	subtotal *pb.Money
+	shipping *pb.Money
+	discount *pb.Money
+	tax      *pb.Money
+	// 应付金额
+	total *pb.Money
+	// 使用的优惠，discount为这些优惠的合计
+	promotions []*pb.AppliedPromotion
+}
+
+// 计算订单金额，下单和预览订单使用同样的计算方法
+func (s *CheckoutService) priceOrder(ctx context.Context, userCurrency, promoCode string, address *pb.Address, prep orderPrep) (orderPrice, error) {
+	out := orderPrice{
+		subtotal: &pb.Money{CurrencyCode: userCurrency},
+		shipping: prep.shippingCostLocalized,
+		discount: &pb.Money{CurrencyCode: userCurrency},
+	}
+	var err error
+	lines := make([]promotion.Line, len(prep.orderItems))
+	lineTotals := make([]*pb.Money, len(prep.orderItems))
+	for i, it := range prep.orderItems {
+		if !money.IsValid(it.Cost) {
+			return out, money.ErrInvalidValue
+		}
+		// 数量转换成uint32前检查，负数会变成很大的数
+		if it.GetItem().GetQuantity() <= 0 {
+			return out, errInvalidQuantity
+		}
+		multPrice, err := money.Multiply(it.Cost, uint32(it.GetItem().GetQuantity()))
+		if err != nil {
+			return out, err
+		}
+		if out.subtotal, err = money.Sum(out.subtotal, multPrice); err != nil {
+			return out, err
+		}
+		lineTotals[i] = multPrice
+		lines[i] = promotion.Line{
+			ProductID:  it.GetItem().GetProductId(),
+			Categories: prep.products[it.GetItem().GetProductId()].GetCategories(),
+			Quantity:   it.GetItem().GetQuantity(),
+			Price:      it.Cost,
+		}
+	}
+
+	applied, err := s.Promotions.Apply(ctx, userCurrency, lines, promoCode, time.Now(), prep.rates.convert)
+	if err != nil {
+		return out, err
+	}
+	out.promotions = applied.Promotions
+	for _, p := range out.promotions {
+		if out.discount, err = money.Sum(out.discount, p.Discount); err != nil {
+			return out, err
+		}
+	}
+
+	// 按优惠后的金额计算税费
+	taxLines := make([]tax.Line, len(lines))
+	for i, line := range lines {
+		amount, err := money.Subtract(lineTotals[i], applied.LineDiscounts[i])
+		if err != nil {
+			return out, err
+		}
+		taxLines[i] = tax.Line{Categories: line.Categories, Amount: amount}
+	}
+	if out.tax, err = s.Tax.Calculate(address, userCurrency, taxLines); err != nil {
+		return out, err
+	}
+
+	if out.total, err = money.Sum(out.subtotal, out.shipping); err != nil {
+		return out, err
+	}
+	if out.total, err = money.Sum(out.total, out.tax); err != nil {
+		return out, err
+	}
+	if out.total, err = money.Subtract(out.total, out.discount); err != nil {
+		return out, err
+	}
+	return out, nil
+}
+
+// 计算订单金额失败时返回的错误，优惠码不能使用时返回InvalidArgument
+func priceOrderError(err error) error {
+	if err == promotion.ErrInvalidCode || err == promotion.ErrCodeNotApplicable || err == errInvalidQuantity {
+		return status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	return status.Errorf(codes.Internal, "计算订单金额失败: %v", err)
+}
+
 // 准备订单
 type orderPrep struct {
 	orderItems            []*pb.OrderItem
 	cartItems             []*pb.CartItem
 	shippingCostLocalized *pb.Money
+	// 订单中的商品，key为商品id
+	products map[string]*pb.Product
+	// 订单的货币转换
+	rates *orderConverter
 }
 
-// 准备订单和配送
+// 准备订单和配送，商品和运费同时查询，然后在一次请求中转换所有价格和运费
 func (s *CheckoutService) prepareOrderItemsAndShippingQuoteFromCart(ctx context.Context, userID, userCurrency string, address *pb.Address) (orderPrep, error) {
-	var out orderPrep
+	out := orderPrep{rates: s.newOrderConverter(time.Now())}
 
 	cartItems, err := s.getUserCart(ctx, userID)
 	if err != nil {
 		return out, fmt.Errorf("购物车错误: %+v", err)
 	}
-	orderItems, err := s.prepOrderItems(ctx, cartItems, userCurrency)
-	if err != nil {
-		return out, fmt.Errorf("准备订单失败: %+v", err)
+
+	// 商品和运费互不依赖，同时查询
+	var (
+		products    map[string]*pb.Product
+		shippingUSD *pb.Money
+	)
+	g, gctx := errgroup.WithContext(ctx)
+	g.Go(func() (err error) {
+		if products, err = s.getOrderProducts(gctx, cartItems); err != nil {
+			return fmt.Errorf("准备订单失败: %+v", err)
+		}
+		return nil
+	})
+	g.Go(func() (err error) {
+		shippingUSD, err = s.quoteShipping(gctx, address, cartItems)
+		return err
+	})
+	if err := g.Wait(); err != nil {
+		return out, err
 	}
-	shippingUSD, err := s.quoteShipping(ctx, address, cartItems)
-	if err != nil {
-		return out, fmt.Errorf("配送配额失败: %+v", err)
+
+	// 相同的价格只转换一次，运费放在最后
+	var from []*pb.Money
+	priceIndex := make(map[priceKey]int, len(products))
+	for _, item := range cartItems {
+		key := newPriceKey(products[item.GetProductId()].GetPriceUsd())
+		if _, ok := priceIndex[key]; !ok {
+			priceIndex[key] = len(from)
+			from = append(from, key.money())
+		}
 	}
-	shippingPrice, err := s.convertCurrency(ctx, shippingUSD, userCurrency)
+	from = append(from, shippingUSD)
+	prices, err := out.rates.convertBatch(ctx, from, userCurrency)
 	if err != nil {
 		return out, fmt.Errorf("货币转换失败: %+v", err)
 	}
 
-	out.shippingCostLocalized = shippingPrice
+	out.orderItems = make([]*pb.OrderItem, len(cartItems))
+	for i, item := range cartItems {
+		key := newPriceKey(products[item.GetProductId()].GetPriceUsd())
+		out.orderItems[i] = &pb.OrderItem{Item: item, Cost: prices[priceIndex[key]]}
+	}
+	out.shippingCostLocalized = prices[len(prices)-1]
 	out.cartItems = cartItems
-	out.orderItems = orderItems
+	out.products = products
 	return out, nil
 }
 
@@ -149,38 +472,140 @@ func (s *CheckoutService) getUserCart(ctx context.Context, userID string) ([]*pb
 // 清空用户购物车
 func (s *CheckoutService) emptyUserCart(ctx context.Context, userID string) error {
 	if _, err := s.CartService.EmptyCart(ctx, &pb.EmptyCartRequest{UserId: userID}); err != nil {
-		return fmt.Errorf("清空购物车失败: %+v", err)
+		return status.Errorf(codes.Unavailable, "清空购物车失败: %v", err)
 	}
 	return nil
 }
 
-// 准备订单项
-func (s *CheckoutService) prepOrderItems(ctx context.Context, items []*pb.CartItem, userCurrency string) ([]*pb.OrderItem, error) {
-	out := make([]*pb.OrderItem, len(items))
-	for i, item := range items {
-		product, err := s.ProductCatalogService.GetProduct(ctx, &pb.GetProductRequest{Id: item.GetProductId()})
-		if err != nil {
-			return nil, fmt.Errorf("获得商品失败 #%q", item.GetProductId())
+// 恢复用户购物车，下单失败时把商品放回购物车
+func (s *CheckoutService) restoreUserCart(ctx context.Context, userID string, items []*pb.CartItem) error {
+	for _, item := range items {
+		if _, err := s.CartService.AddItem(ctx, &pb.AddItemRequest{UserId: userID, Item: item}); err != nil {
+			return fmt.Errorf("恢复购物车商品失败 %q: %+v", item.GetProductId(), err)
 		}
-		price, err := s.convertCurrency(ctx, product.GetPriceUsd(), userCurrency)
-		if err != nil {
-			return nil, fmt.Errorf("价格转换失败 %q to %s", item.GetProductId(), userCurrency)
+	}
+	return nil
+}
+
+// 并发查询订单中的商品，同一个商品只查询一次，key为商品id
+func (s *CheckoutService) getOrderProducts(ctx context.Context, items []*pb.CartItem) (map[string]*pb.Product, error) {
+	var ids []string
+	seen := make(map[string]bool, len(items))
+	for _, item := range items {
+		if !seen[item.GetProductId()] {
+			seen[item.GetProductId()] = true
+			ids = append(ids, item.GetProductId())
 		}
-		out[i] = &pb.OrderItem{Item: item, Cost: price}
+	}
+	products := make([]*pb.Product, len(ids))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(prepConcurrency)
+	for i, id := range ids {
+		// 请求被取消或者已有查询失败时不再发起新的查询
+		if gctx.Err() != nil {
+			break
+		}
+		i, id := i, id
+		g.Go(func() (err error) {
+			if err := gctx.Err(); err != nil {
+				return err
+			}
+			if products[i], err = s.ProductCatalogService.GetProduct(gctx, &pb.GetProductRequest{Id: id}); err != nil {
+				return fmt.Errorf("获得商品失败 #%q: %+v", id, err)
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	// 没有发起查询就被取消时g.Wait不返回错误
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	out := make(map[string]*pb.Product, len(ids))
+	for i, id := range ids {
+		out[id] = products[i]
 	}
 	return out, nil
 }
 
+// 用作map key的价格
+type priceKey struct {
+	currencyCode string
+	units        int64
+	nanos        int32
+}
+
+func newPriceKey(m *pb.Money) priceKey {
+	return priceKey{currencyCode: m.GetCurrencyCode(), units: m.GetUnits(), nanos: m.GetNanos()}
+}
+
+func (k priceKey) money() *pb.Money {
+	return &pb.Money{CurrencyCode: k.currencyCode, Units: k.units, Nanos: k.nanos}
+}
+
+// 一个订单的货币转换，所有转换使用同一时间的汇率，并记录使用的汇率版本
+type orderConverter struct {
+	s *CheckoutService
+	// 使用该时间的汇率，unix时间戳（秒）
+	asOf int64
+
+	mu      sync.Mutex
+	version string
+}
+
+// 创建订单的货币转换，使用asOf时的汇率
+func (s *CheckoutService) newOrderConverter(asOf time.Time) *orderConverter {
+	return &orderConverter{s: s, asOf: asOf.Unix()}
+}
+
 // 货币转换
-func (s *CheckoutService) convertCurrency(ctx context.Context, from *pb.Money, toCurrency string) (*pb.Money, error) {
-	result, err := s.CurrencyService.Convert(context.TODO(), &pb.CurrencyConversionRequest{
+func (c *orderConverter) convert(ctx context.Context, from *pb.Money, toCurrency string) (*pb.Money, error) {
+	result, err := c.s.CurrencyService.Convert(ctx, &pb.CurrencyConversionRequest{
+		From:   from,
+		ToCode: toCurrency,
+		AsOf:   c.asOf,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("价格转换失败: %+v", err)
+	}
+	c.recordVersion(result.GetRateVersion())
+	return result.GetMoney(), nil
+}
+
+// 批量货币转换，结果和from一一对应
+func (c *orderConverter) convertBatch(ctx context.Context, from []*pb.Money, toCurrency string) ([]*pb.Money, error) {
+	result, err := c.s.CurrencyService.ConvertBatch(ctx, &pb.ConvertBatchRequest{
 		From:   from,
 		ToCode: toCurrency,
+		AsOf:   c.asOf,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("价格转换失败: %+v", err)
 	}
-	return result, err
+	if len(result.GetMoney()) != len(from) {
+		return nil, fmt.Errorf("价格转换失败: 返回%d个结果，需要%d个", len(result.GetMoney()), len(from))
+	}
+	c.recordVersion(result.GetRateVersion())
+	return result.GetMoney(), nil
+}
+
+// 记录使用的汇率版本
+func (c *orderConverter) recordVersion(version string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.version == "" {
+		c.version = version
+	}
+}
+
+// 使用的汇率版本
+func (c *orderConverter) rateVersion() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.version
 }
 
 // 结算卡
@@ -190,17 +615,36 @@ func (s *CheckoutService) chargeCard(ctx context.Context, amount *pb.Money, paym
 		CreditCard: paymentInfo,
 	})
 	if err != nil {
-		return "", fmt.Errorf("不能更换卡: %+v", err)
+		return "", status.Errorf(downstreamCode(err, codes.Unavailable), "不能更换卡: %v", status.Convert(err).Message())
 	}
 	return paymentResp.GetTransactionId(), nil
 }
 
-// 发送确认信息
+// 退款
+func (s *CheckoutService) refund(ctx context.Context, transactionID string) error {
+	if _, err := s.PaymentService.Refund(ctx, &pb.RefundRequest{TransactionId: transactionID}); err != nil {
+		return fmt.Errorf("退款失败 %q: %+v", transactionID, err)
+	}
+	return nil
+}
+
+// 发送确认信息，失败时重试
 func (s *CheckoutService) sendOrderConfirmation(ctx context.Context, email string, order *pb.OrderResult) error {
-	_, err := s.EmailService.SendOrderConfirmation(ctx, &pb.SendOrderConfirmationRequest{
-		Email: email,
-		Order: order,
-	})
+	var err error
+	for i := 0; i < emailRetries; i++ {
+		_, err = s.EmailService.SendOrderConfirmation(ctx, &pb.SendOrderConfirmationRequest{
+			Email: email,
+			Order: order,
+		})
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Duration(i+1) * emailRetryBackoff):
+		}
+	}
 	return err
 }
 
@@ -211,7 +655,15 @@ func (s *CheckoutService) shipOrder(ctx context.Context, address *pb.Address, it
 		Items:   items,
 	})
 	if err != nil {
-		return "", fmt.Errorf("配送失败: %+v", err)
+		return "", status.Errorf(downstreamCode(err, codes.Unavailable), "配送失败: %v", status.Convert(err).Message())
 	}
 	return resp.GetTrackingId(), nil
 }
+
+// 下游服务返回的参数错误原样返回给调用方，其他错误使用code
+func downstreamCode(err error, code codes.Code) codes.Code {
+	if c := status.Code(err); c == codes.InvalidArgument || c == codes.FailedPrecondition {
+		return c
+	}
+	return code
+}
@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"checkoutservice/promotion"
+	pb "checkoutservice/proto"
+	"checkoutservice/tax"
+)
+
+// 每次查询等待latency的商品服务，started为发起的查询次数
+type slowCatalog struct {
+	pb.ProductCatalogServiceClient
+	latency time.Duration
+	started int32
+}
+
+func (c *slowCatalog) GetProduct(ctx context.Context, in *pb.GetProductRequest, opts ...grpc.CallOption) (*pb.Product, error) {
+	atomic.AddInt32(&c.started, 1)
+	select {
+	case <-time.After(c.latency):
+		return &pb.Product{Id: in.GetId(), PriceUsd: &pb.Money{CurrencyCode: "USD", Units: 1}}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// n个不同商品的购物车
+func cartOf(n int) []*pb.CartItem {
+	items := make([]*pb.CartItem, n)
+	for i := range items {
+		items[i] = &pb.CartItem{ProductId: fmt.Sprintf("p%d", i), Quantity: 1}
+	}
+	return items
+}
+
+// 依次查询商品，作为并发查询的对照
+func getProductsSequential(ctx context.Context, c pb.ProductCatalogServiceClient, items []*pb.CartItem) (map[string]*pb.Product, error) {
+	out := make(map[string]*pb.Product, len(items))
+	for _, item := range items {
+		if _, ok := out[item.GetProductId()]; ok {
+			continue
+		}
+		p, err := c.GetProduct(ctx, &pb.GetProductRequest{Id: item.GetProductId()})
+		if err != nil {
+			return nil, err
+		}
+		out[item.GetProductId()] = p
+	}
+	return out, nil
+}
+
+func TestGetOrderProducts(t *testing.T) {
+	catalog := &slowCatalog{latency: time.Millisecond}
+	s := &CheckoutService{ProductCatalogService: catalog}
+	items := append(cartOf(20), &pb.CartItem{ProductId: "p0", Quantity: 2})
+	products, err := s.getOrderProducts(context.Background(), items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(products) != 20 {
+		t.Fatalf("查询到%d个商品，期望20个", len(products))
+	}
+	for id, p := range products {
+		if p.GetId() != id {
+			t.Fatalf("商品 %s 对应的是 %s", id, p.GetId())
+		}
+	}
+	// 重复的商品只查询一次
+	if catalog.started != 20 {
+		t.Fatalf("查询了%d次商品，期望20次", catalog.started)
+	}
+}
+
+// 取消请求后正在进行的查询立即返回，也不再发起新的查询
+func TestGetOrderProductsCancel(t *testing.T) {
+	catalog := &slowCatalog{latency: time.Hour}
+	s := &CheckoutService{ProductCatalogService: catalog}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.getOrderProducts(ctx, cartOf(4*prepConcurrency))
+		done <- err
+	}()
+	for atomic.LoadInt32(&catalog.started) < prepConcurrency {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Fatal("取消后查询商品没有返回错误")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("取消后查询商品没有返回")
+	}
+	if n := atomic.LoadInt32(&catalog.started); n > prepConcurrency {
+		t.Fatalf("取消后仍然发起查询，共查询%d次，最多同时查询%d个", n, prepConcurrency)
+	}
+}
+
+func TestGetOrderProductsCanceledBeforeStart(t *testing.T) {
+	catalog := &slowCatalog{latency: time.Hour}
+	s := &CheckoutService{ProductCatalogService: catalog}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.getOrderProducts(ctx, cartOf(3)); err != context.Canceled {
+		t.Fatalf("返回 %v，期望 %v", err, context.Canceled)
+	}
+	if catalog.started != 0 {
+		t.Fatalf("已经取消的请求查询了%d次商品", catalog.started)
+	}
+}
+
+// 每次查询2ms时，依次查询和并发查询16个商品的耗时
+func BenchmarkGetOrderProducts(b *testing.B) {
+	catalog := &slowCatalog{latency: 2 * time.Millisecond}
+	items := cartOf(16)
+	b.Run("sequential", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			if _, err := getProductsSequential(context.Background(), catalog, items); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("fanout", func(b *testing.B) {
+		s := &CheckoutService{ProductCatalogService: catalog}
+		for i := 0; i < b.N; i++ {
+			if _, err := s.getOrderProducts(context.Background(), items); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// 金额是否为 units.nanos 美元
+func isUSD(m *pb.Money, units int64, nanos int32) bool {
+	return m.GetCurrencyCode() == "USD" && m.GetUnits() == units && m.GetNanos() == nanos
+}
+
+// 商品合计、运费、优惠、税费和应付金额。购物车为2个10.50美元的服装和1个5美元的厨具，运费8美元，
+// 加州服装税率10%，其他商品5%
+func TestPreviewOrder(t *testing.T) {
+	calc, err := tax.NewCalculator([]tax.Rule{
+		{Country: "United States", Rate: "5"},
+		{Country: "United States", State: "CA", Categories: []string{"clothing"}, Rate: "10"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	engine, err := promotion.NewEngine([]promotion.Rule{
+		{ID: "clothing20", Type: promotion.PercentOff, Code: "SAVE20", Categories: []string{"clothing"}, Percent: 20},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	type amount struct {
+		units int64
+		nanos int32
+	}
+	tests := []struct {
+		name                                     string
+		promoCode                                string
+		subtotal, shipping, discount, tax, total amount
+	}{
+		// 税费 21.00*10% + 5.00*5% = 2.35
+		{"没有优惠", "", amount{26, 0}, amount{8, 0}, amount{0, 0}, amount{2, 350000000}, amount{36, 350000000}},
+		// 服装优惠4.20，税费 16.80*10% + 5.00*5% = 1.93
+		{"按优惠后的金额计算税费", "SAVE20", amount{26, 0}, amount{8, 0}, amount{4, 200000000}, amount{1, 930000000}, amount{31, 730000000}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, _ := newFakeCheckout()
+			s.Tax = calc
+			s.Promotions = engine
+			out, err := s.PreviewOrder(context.Background(), &pb.PreviewOrderRequest{
+				UserId:       "u1",
+				UserCurrency: "USD",
+				Address:      &pb.Address{Country: "United States", State: "CA"},
+				PromoCode:    tt.promoCode,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, c := range []struct {
+				name string
+				got  *pb.Money
+				want amount
+			}{
+				{"商品合计", out.GetSubtotal(), tt.subtotal},
+				{"运费", out.GetShippingCost(), tt.shipping},
+				{"优惠", out.GetDiscount(), tt.discount},
+				{"税费", out.GetTax(), tt.tax},
+				{"应付金额", out.GetTotal(), tt.total},
+			} {
+				if !isUSD(c.got, c.want.units, c.want.nanos) {
+					t.Errorf("%s为 %v，期望 %d.%09d美元", c.name, c.got, c.want.units, c.want.nanos)
+				}
+			}
+			if len(out.GetItems()) != 2 || !isUSD(out.GetItems()[0].GetCost(), 10, 500000000) {
+				t.Errorf("订单商品为 %v", out.GetItems())
+			}
+		})
+	}
+}
+
+// 数量不是正数时返回InvalidArgument，不能转换成uint32后变成很大的数
+func TestPreviewOrderInvalidQuantity(t *testing.T) {
+	for _, quantity := range []int32{0, -1} {
+		s, f := newFakeCheckout()
+		f.cart.items[1].Quantity = quantity
+		_, err := s.PreviewOrder(context.Background(), &pb.PreviewOrderRequest{UserId: "u1", UserCurrency: "USD"})
+		if status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("数量为%d时返回 %v，期望 %v", quantity, err, codes.InvalidArgument)
+		}
+		if _, err := s.PlaceOrder(context.Background(), placeOrderRequest()); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("数量为%d时下单返回 %v，期望 %v", quantity, err, codes.InvalidArgument)
+		}
+		if f.cart.emptied || len(f.payment.charged) != 0 {
+			t.Fatalf("数量为%d时清空了购物车或付款", quantity)
+		}
+	}
+}
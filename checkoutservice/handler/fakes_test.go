@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"context"
+	"sync"
+
+	"google.golang.org/grpc"
+
+	"checkoutservice/orderstore"
+	pb "checkoutservice/proto"
+)
+
+// 购物车服务，记录清空和恢复的商品
+type fakeCart struct {
+	pb.CartServiceClient
+	mu       sync.Mutex
+	items    []*pb.CartItem
+	emptied  bool
+	restored []*pb.CartItem
+	// 恢复购物车时返回的错误
+	addErr error
+}
+
+func (c *fakeCart) GetCart(ctx context.Context, in *pb.GetCartRequest, opts ...grpc.CallOption) (*pb.Cart, error) {
+	return &pb.Cart{UserId: in.GetUserId(), Items: c.items}, nil
+}
+
+func (c *fakeCart) EmptyCart(ctx context.Context, in *pb.EmptyCartRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.emptied = true
+	return &pb.Empty{}, nil
+}
+
+func (c *fakeCart) AddItem(ctx context.Context, in *pb.AddItemRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.addErr != nil {
+		return nil, c.addErr
+	}
+	c.restored = append(c.restored, in.GetItem())
+	return &pb.Empty{}, nil
+}
+
+// 商品服务，key为商品id
+type fakeCatalog struct {
+	pb.ProductCatalogServiceClient
+	products map[string]*pb.Product
+}
+
+func (c *fakeCatalog) GetProduct(ctx context.Context, in *pb.GetProductRequest, opts ...grpc.CallOption) (*pb.Product, error) {
+	return c.products[in.GetId()], nil
+}
+
+// 货币服务，只修改货币代码，金额不变
+type fakeCurrency struct {
+	pb.CurrencyServiceClient
+}
+
+func (c *fakeCurrency) Convert(ctx context.Context, in *pb.CurrencyConversionRequest, opts ...grpc.CallOption) (*pb.CurrencyConversionResponse, error) {
+	return &pb.CurrencyConversionResponse{Money: relabel(in.GetFrom(), in.GetToCode()), RateVersion: "v1"}, nil
+}
+
+func (c *fakeCurrency) ConvertBatch(ctx context.Context, in *pb.ConvertBatchRequest, opts ...grpc.CallOption) (*pb.ConvertBatchResponse, error) {
+	out := &pb.ConvertBatchResponse{RateVersion: "v1"}
+	for _, m := range in.GetFrom() {
+		out.Money = append(out.Money, relabel(m, in.GetToCode()))
+	}
+	return out, nil
+}
+
+func relabel(m *pb.Money, currency string) *pb.Money {
+	return &pb.Money{CurrencyCode: currency, Units: m.GetUnits(), Nanos: m.GetNanos()}
+}
+
+// 配送服务
+type fakeShipping struct {
+	pb.ShippingServiceClient
+	quote   *pb.Money
+	shipErr error
+}
+
+func (s *fakeShipping) GetQuote(ctx context.Context, in *pb.GetQuoteRequest, opts ...grpc.CallOption) (*pb.GetQuoteResponse, error) {
+	return &pb.GetQuoteResponse{CostUsd: s.quote}, nil
+}
+
+func (s *fakeShipping) ShipOrder(ctx context.Context, in *pb.ShipOrderRequest, opts ...grpc.CallOption) (*pb.ShipOrderResponse, error) {
+	if s.shipErr != nil {
+		return nil, s.shipErr
+	}
+	return &pb.ShipOrderResponse{TrackingId: "track-1"}, nil
+}
+
+// 支付服务，记录付款和退款
+type fakePayment struct {
+	pb.PaymentServiceClient
+	mu                   sync.Mutex
+	chargeErr, refundErr error
+	charged              []*pb.Money
+	refunded             []string
+}
+
+func (p *fakePayment) Charge(ctx context.Context, in *pb.ChargeRequest, opts ...grpc.CallOption) (*pb.ChargeResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.chargeErr != nil {
+		return nil, p.chargeErr
+	}
+	p.charged = append(p.charged, in.GetAmount())
+	return &pb.ChargeResponse{TransactionId: "tx-1"}, nil
+}
+
+func (p *fakePayment) Refund(ctx context.Context, in *pb.RefundRequest, opts ...grpc.CallOption) (*pb.RefundResponse, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.refundErr != nil {
+		return nil, p.refundErr
+	}
+	p.refunded = append(p.refunded, in.GetTransactionId())
+	return &pb.RefundResponse{RefundId: "refund-1"}, nil
+}
+
+// 邮件服务
+type fakeEmail struct {
+	pb.EmailServiceClient
+}
+
+func (e *fakeEmail) SendOrderConfirmation(ctx context.Context, in *pb.SendOrderConfirmationRequest, opts ...grpc.CallOption) (*pb.Empty, error) {
+	return &pb.Empty{}, nil
+}
+
+// 下单用到的下游服务
+type fakeServices struct {
+	cart     *fakeCart
+	shipping *fakeShipping
+	payment  *fakePayment
+}
+
+// 结算服务，购物车中有2个单价10.50美元的P1和1个单价5美元的P2，运费8美元
+func newFakeCheckout() (*CheckoutService, *fakeServices) {
+	f := &fakeServices{
+		cart: &fakeCart{items: []*pb.CartItem{
+			{ProductId: "P1", Quantity: 2},
+			{ProductId: "P2", Quantity: 1},
+		}},
+		shipping: &fakeShipping{quote: &pb.Money{CurrencyCode: "USD", Units: 8}},
+		payment:  &fakePayment{},
+	}
+	s := &CheckoutService{
+		CartService: f.cart,
+		ProductCatalogService: &fakeCatalog{products: map[string]*pb.Product{
+			"P1": {Id: "P1", PriceUsd: &pb.Money{CurrencyCode: "USD", Units: 10, Nanos: 500000000}, Categories: []string{"clothing"}},
+			"P2": {Id: "P2", PriceUsd: &pb.Money{CurrencyCode: "USD", Units: 5}, Categories: []string{"kitchen"}},
+		}},
+		CurrencyService: &fakeCurrency{},
+		ShippingService: f.shipping,
+		PaymentService:  f.payment,
+		EmailService:    &fakeEmail{},
+		Orders:          orderstore.NewMemoryOrderStore(),
+	}
+	return s, f
+}
+
+// 下单请求
+func placeOrderRequest() *pb.PlaceOrderRequest {
+	return &pb.PlaceOrderRequest{
+		UserId:       "u1",
+		UserCurrency: "USD",
+		Address:      &pb.Address{Country: "United States", State: "CA"},
+		Email:        "someone@example.com",
+		CreditCard:   &pb.CreditCardInfo{CreditCardNumber: "4432801561520454"},
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "checkoutservice/proto"
+)
+
+// 幂等下单，key为用户id和幂等键。
+// 下单完成的幂等键保存在订单存储中，有效期内相同key的重复请求直接返回第一次下单的订单，这里只记录正在处理的请求
+type IdempotencyStore struct {
+	sync.Mutex
+	// 幂等键保存多久，过期后相同的key会重新下单
+	window  time.Duration
+	entries map[string]*idempotencyEntry
+}
+
+// 一次正在处理的下单请求
+type idempotencyEntry struct {
+	// 下单完成后关闭，重复的请求等待第一次请求完成
+	done chan struct{}
+	// 请求内容的摘要
+	hash  string
+	order *pb.OrderResult
+}
+
+// 创建幂等下单记录
+func NewIdempotencyStore(window time.Duration) *IdempotencyStore {
+	return &IdempotencyStore{
+		window:  window,
+		entries: make(map[string]*idempotencyEntry),
+	}
+}
+
+// 开始处理key对应的请求，没有正在处理的请求时占用key并返回first为true，否则返回正在处理的请求
+func (s *IdempotencyStore) begin(key, hash string) (e *idempotencyEntry, first bool) {
+	s.Lock()
+	defer s.Unlock()
+	if e, ok := s.entries[key]; ok {
+		return e, false
+	}
+	e = &idempotencyEntry{done: make(chan struct{}), hash: hash}
+	s.entries[key] = e
+	return e, true
+}
+
+// 完成key对应的请求，order为nil表示下单失败，等待的请求会重新下单
+func (s *IdempotencyStore) finish(key string, e *idempotencyEntry, order *pb.OrderResult) {
+	s.Lock()
+	defer s.Unlock()
+	e.order = order
+	delete(s.entries, key)
+	close(e.done)
+}
+
+// 幂等键加上用户id，不同用户使用相同的幂等键互不影响
+func idempotencyKey(userID, key string) string {
+	return userID + "/" + key
+}
+
+// 下单请求内容的摘要，不包括幂等键。
+// 摘要会保存在订单存储中，信用卡只使用后4位和有效期，不使用完整卡号和cvv
+func requestHash(in *pb.PlaceOrderRequest) (string, error) {
+	req := proto.Clone(in).(*pb.PlaceOrderRequest)
+	req.IdempotencyKey = ""
+	if card := req.GetCreditCard(); card != nil {
+		if n := len(card.CreditCardNumber); n > 4 {
+			card.CreditCardNumber = card.CreditCardNumber[n-4:]
+		}
+		card.CreditCardCvv = 0
+	}
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"testing"
+
+	pb "checkoutservice/proto"
+)
+
+func TestRequestHash(t *testing.T) {
+	request := func() *pb.PlaceOrderRequest {
+		return &pb.PlaceOrderRequest{
+			UserId:         "u1",
+			UserCurrency:   "CNY",
+			Address:        &pb.Address{StreetAddress: "中关村大街1号", City: "北京", Country: "中国"},
+			Email:          "someone@example.com",
+			IdempotencyKey: "k1",
+			CreditCard: &pb.CreditCardInfo{
+				CreditCardNumber:          "4432801561520454",
+				CreditCardCvv:             672,
+				CreditCardExpirationYear:  2030,
+				CreditCardExpirationMonth: 1,
+			},
+		}
+	}
+	base, err := requestHash(request())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name   string
+		modify func(in *pb.PlaceOrderRequest)
+		same   bool
+	}{
+		{"幂等键不影响摘要", func(in *pb.PlaceOrderRequest) { in.IdempotencyKey = "k2" }, true},
+		{"cvv不影响摘要", func(in *pb.PlaceOrderRequest) { in.CreditCard.CreditCardCvv = 123 }, true},
+		{"地址不同", func(in *pb.PlaceOrderRequest) { in.Address.City = "上海" }, false},
+		{"货币不同", func(in *pb.PlaceOrderRequest) { in.UserCurrency = "USD" }, false},
+		{"优惠码不同", func(in *pb.PlaceOrderRequest) { in.PromoCode = "SAVE10" }, false},
+		{"卡号后4位不同", func(in *pb.PlaceOrderRequest) { in.CreditCard.CreditCardNumber = "4432801561520455" }, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			in := request()
+			tt.modify(in)
+			got, err := requestHash(in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got == base) != tt.same {
+				t.Fatalf("摘要相同为 %v，期望 %v", got == base, tt.same)
+			}
+		})
+	}
+	// 计算摘要不修改请求
+	in := request()
+	if _, err := requestHash(in); err != nil {
+		t.Fatal(err)
+	}
+	if in.IdempotencyKey != "k1" || in.CreditCard.CreditCardCvv != 672 {
+		t.Fatalf("计算摘要修改了请求: %v", in)
+	}
+}
@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"checkoutservice/orderstore"
+	pb "checkoutservice/proto"
+)
+
+// 保存了u1的三个订单的结算服务
+func newOrdersService(t *testing.T) *CheckoutService {
+	s := &CheckoutService{Orders: orderstore.NewMemoryOrderStore()}
+	for i := 1; i <= 3; i++ {
+		err := s.Orders.SaveOrder(context.Background(), &pb.Order{
+			Order:     &pb.OrderResult{OrderId: fmt.Sprintf("o%d", i)},
+			UserId:    "u1",
+			CreatedAt: int64(i),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return s
+}
+
+func TestGetOrder(t *testing.T) {
+	s := newOrdersService(t)
+	tests := []struct {
+		name    string
+		orderID string
+		userID  string
+		code    codes.Code
+	}{
+		{"自己的订单", "o1", "u1", codes.OK},
+		{"其他用户的订单", "o1", "u2", codes.NotFound},
+		{"不存在的订单", "o9", "u1", codes.NotFound},
+		{"用户id为空", "o1", "", codes.InvalidArgument},
+		{"订单id为空", "", "u1", codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order, err := s.GetOrder(context.Background(), &pb.GetOrderRequest{OrderId: tt.orderID, UserId: tt.userID})
+			if code := status.Code(err); code != tt.code {
+				t.Fatalf("GetOrder 返回 %v，期望 %v", err, tt.code)
+			}
+			if err == nil && order.GetOrder().GetOrderId() != tt.orderID {
+				t.Fatalf("GetOrder 返回订单 %s", order.GetOrder().GetOrderId())
+			}
+		})
+	}
+}
+
+func TestListOrders(t *testing.T) {
+	s := newOrdersService(t)
+	ctx := context.Background()
+	tests := []struct {
+		page, pageSize int32
+		want           string
+	}{
+		{1, 2, "[o3 o2]"},
+		{2, 2, "[o1]"},
+		{3, 2, "[]"},
+		{0, 0, "[o3 o2 o1]"},
+	}
+	for _, tt := range tests {
+		out, err := s.ListOrders(ctx, &pb.ListOrdersRequest{UserId: "u1", Page: tt.page, PageSize: tt.pageSize})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := []string{}
+		for _, o := range out.GetOrders() {
+			ids = append(ids, o.GetOrder().GetOrderId())
+		}
+		if got := fmt.Sprint(ids); got != tt.want || out.GetTotalCount() != 3 {
+			t.Fatalf("第%d页（每页%d个）为 %s，共%d个，期望 %s，共3个", tt.page, tt.pageSize, got, out.GetTotalCount(), tt.want)
+		}
+	}
+	// 其他用户看不到u1的订单
+	out, err := s.ListOrders(ctx, &pb.ListOrdersRequest{UserId: "u2"})
+	if err != nil || len(out.GetOrders()) != 0 {
+		t.Fatalf("u2 的订单为 %v, %v", out.GetOrders(), err)
+	}
+	for _, in := range []*pb.ListOrdersRequest{{}, {UserId: "u1", Page: -1}, {UserId: "u1", PageSize: -1}} {
+		if _, err := s.ListOrders(ctx, in); status.Code(err) != codes.InvalidArgument {
+			t.Fatalf("ListOrders(%v) 返回 %v", in, err)
+		}
+	}
+}
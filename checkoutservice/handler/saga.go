@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc/status"
+)
+
+// 补偿操作的超时时间，补偿不使用请求的ctx，避免请求被取消后无法撤销
+const compensateTimeout = 10 * time.Second
+
+// 下单流程中的一步
+type sagaStep struct {
+	name string
+	// 执行操作
+	action func(ctx context.Context) error
+	// 补偿操作，撤销action的结果，为nil表示不需要补偿
+	compensate func(ctx context.Context) error
+}
+
+// 下单流程，按顺序执行每一步，某一步失败时按相反顺序补偿已经完成的步骤
+type saga struct {
+	steps []sagaStep
+}
+
+// 添加一步
+func (s *saga) add(name string, action, compensate func(ctx context.Context) error) {
+	s.steps = append(s.steps, sagaStep{name: name, action: action, compensate: compensate})
+}
+
+// 执行，返回失败步骤的错误。补偿也失败时错误中带上补偿失败的步骤，状态码仍然使用失败步骤的状态码
+func (s *saga) run(ctx context.Context) error {
+	for i, step := range s.steps {
+		if err := step.action(ctx); err != nil {
+			logger.Printf("[saga] %s 失败: %v", step.name, err)
+			if cerr := s.rollback(i); cerr != nil {
+				st := status.Convert(err)
+				return status.Errorf(st.Code(), "%s（%v）", st.Message(), cerr)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// 补偿第done步之前已经完成的步骤，某一步补偿失败时继续补偿其他步骤，返回补偿失败的步骤，需要人工处理
+func (s *saga) rollback(done int) error {
+	ctx, cancel := context.WithTimeout(context.Background(), compensateTimeout)
+	defer cancel()
+	var failed []string
+	for i := done - 1; i >= 0; i-- {
+		step := s.steps[i]
+		if step.compensate == nil {
+			continue
+		}
+		if err := step.compensate(ctx); err != nil {
+			logger.Printf("[saga] 补偿 %s 失败，需要人工处理: %v", step.name, err)
+			failed = append(failed, fmt.Sprintf("%s: %v", step.name, err))
+		} else {
+			logger.Printf("[saga] 补偿 %s 成功", step.name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("补偿失败，需要人工处理: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// 某一步失败时按相反顺序补偿已经完成的步骤，不补偿失败的步骤和之后的步骤
+func TestSagaRollbackOrder(t *testing.T) {
+	var calls []string
+	step := func(name string, fail bool) (func(ctx context.Context) error, func(ctx context.Context) error) {
+		return func(ctx context.Context) error {
+				calls = append(calls, name)
+				if fail {
+					return status.Errorf(codes.Unavailable, "%s 失败", name)
+				}
+				return nil
+			}, func(ctx context.Context) error {
+				calls = append(calls, "撤销"+name)
+				return nil
+			}
+	}
+	s := new(saga)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		action, compensate := step(name, name == "c")
+		s.add(name, action, compensate)
+	}
+	err := s.run(context.Background())
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("run 返回 %v", err)
+	}
+	if want := []string{"a", "b", "c", "撤销b", "撤销a"}; !reflect.DeepEqual(calls, want) {
+		t.Fatalf("执行顺序为 %v，期望 %v", calls, want)
+	}
+}
+
+// 配送失败时退款并恢复购物车
+func TestPlaceOrderShippingFailureRefunds(t *testing.T) {
+	s, f := newFakeCheckout()
+	f.shipping.shipErr = status.Errorf(codes.Unavailable, "配送服务不可用")
+	_, err := s.PlaceOrder(context.Background(), placeOrderRequest())
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("PlaceOrder 返回 %v", err)
+	}
+	if len(f.payment.charged) != 1 || !reflect.DeepEqual(f.payment.refunded, []string{"tx-1"}) {
+		t.Fatalf("付款 %v，退款 %v，期望退款 tx-1", f.payment.charged, f.payment.refunded)
+	}
+	if !f.cart.emptied || !reflect.DeepEqual(f.cart.restored, f.cart.items) {
+		t.Fatalf("恢复的购物车为 %v，期望 %v", f.cart.restored, f.cart.items)
+	}
+}
+
+// 付款失败时恢复购物车，不退款
+func TestPlaceOrderChargeFailureRestoresCart(t *testing.T) {
+	s, f := newFakeCheckout()
+	f.payment.chargeErr = status.Errorf(codes.InvalidArgument, "卡号无效")
+	_, err := s.PlaceOrder(context.Background(), placeOrderRequest())
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("PlaceOrder 返回 %v", err)
+	}
+	if len(f.payment.refunded) != 0 {
+		t.Fatalf("付款失败后退款 %v", f.payment.refunded)
+	}
+	if !reflect.DeepEqual(f.cart.restored, f.cart.items) {
+		t.Fatalf("恢复的购物车为 %v，期望 %v", f.cart.restored, f.cart.items)
+	}
+}
+
+// 补偿失败时返回的错误中带上补偿失败的步骤，其他步骤仍然补偿
+func TestPlaceOrderCompensationFailureReported(t *testing.T) {
+	s, f := newFakeCheckout()
+	f.shipping.shipErr = status.Errorf(codes.Unavailable, "配送服务不可用")
+	f.payment.refundErr = errors.New("支付服务不可用")
+	_, err := s.PlaceOrder(context.Background(), placeOrderRequest())
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("PlaceOrder 返回 %v", err)
+	}
+	msg := status.Convert(err).Message()
+	if !strings.Contains(msg, "需要人工处理") || !strings.Contains(msg, "付款") || !strings.Contains(msg, "支付服务不可用") {
+		t.Fatalf("错误中没有补偿失败的信息: %s", msg)
+	}
+	if !reflect.DeepEqual(f.cart.restored, f.cart.items) {
+		t.Fatalf("退款失败后没有恢复购物车: %v", f.cart.restored)
+	}
+}
@@ -2,10 +2,15 @@ package main
 
 import (
 	handler "checkoutservice/handler"
+	"checkoutservice/orderstore"
+	"checkoutservice/promotion"
 	pb "checkoutservice/proto"
+	"checkoutservice/tax"
+	"flag"
 	"fmt"
 	"net"
 	"strconv"
+	"time"
 
 	"github.com/hashicorp/consul/api"
 	"google.golang.org/grpc"
@@ -32,8 +37,64 @@ func GetGrpcConn(consulClient *api.Client, serviceName string, serviceTag string
 const PORT = 50020
 const ADDRESS = "127.0.0.1"
 
+// 带幂等键的下单结果保存多久，有效期内的重复请求不会再次付款
+var idempotencyWindow = flag.Duration("idempotency-window", 24*time.Hour, "幂等下单结果的保存时间，0表示不处理幂等键")
+
+// 订单存储方式：memory 内存，bolt 本地文件
+var (
+	orderStoreType = flag.String("order-store", "bolt", "订单存储方式: memory 或 bolt")
+	orderDBPath    = flag.String("order-db", "orders.db", "bolt数据库文件路径")
+)
+
+// 优惠规则和税率规则文件，为空表示不使用
+var (
+	promotionsPath = flag.String("promotions", "data/promotions.json", "优惠规则json文件路径，为空表示不使用优惠")
+	taxPath        = flag.String("tax", "data/tax.json", "税率规则json文件路径，为空表示不收税")
+)
+
+// 根据启动参数创建订单存储
+func newOrderStore() (orderstore.OrderStore, error) {
+	switch *orderStoreType {
+	case "memory":
+		return orderstore.NewMemoryOrderStore(), nil
+	case "bolt":
+		return orderstore.NewBoltOrderStore(*orderDBPath)
+	default:
+		return nil, fmt.Errorf("不支持的存储方式: %s", *orderStoreType)
+	}
+}
+
 func main() {
+	flag.Parse()
 	ipport := ADDRESS + ":" + strconv.Itoa(PORT)
+
+	// 初始化订单存储
+	orders, err_store := newOrderStore()
+	if err_store != nil {
+		fmt.Println("初始化订单存储报错：", err_store)
+		return
+	}
+
+	// 加载优惠规则
+	var promotions *promotion.Engine
+	if *promotionsPath != "" {
+		var err_promotions error
+		if promotions, err_promotions = promotion.LoadFile(*promotionsPath); err_promotions != nil {
+			fmt.Println("加载优惠规则报错：", err_promotions)
+			return
+		}
+	}
+
+	// 加载税率规则
+	var taxes *tax.Calculator
+	if *taxPath != "" {
+		var err_tax error
+		if taxes, err_tax = tax.LoadFile(*taxPath); err_tax != nil {
+			fmt.Println("加载税率规则报错：", err_tax)
+			return
+		}
+	}
+
 	// ----------------注册到consul上---------------------
 	// 初始化consul配置
 	consulConfig := api.DefaultConfig()
@@ -72,6 +133,12 @@ func main() {
 		ProductCatalogService: pb.NewProductCatalogServiceClient(GetGrpcConn(consulClient, "productcatalogservice", "productcatalogservice")),
 		PaymentService:        pb.NewPaymentServiceClient(GetGrpcConn(consulClient, "paymentservice", "paymentservice")),
 		ShippingService:       pb.NewShippingServiceClient(GetGrpcConn(consulClient, "shippingservice", "shippingservice")),
+		Orders:                orders,
+		Promotions:            promotions,
+		Tax:                   taxes,
+	}
+	if *idempotencyWindow > 0 {
+		checkoutService.Idempotency = handler.NewIdempotencyStore(*idempotencyWindow)
 	}
 
 	// 注册服务
@@ -0,0 +1,162 @@
+package orderstore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/protobuf/proto"
+
+	pb "checkoutservice/proto"
+)
+
+var (
+	// 保存订单的bucket，key为订单id，value为序列化后的订单
+	ordersBucket = []byte("orders")
+	// 用户订单索引，每个用户一个子bucket，key为下单时间加订单id，按下单时间排序
+	userOrdersBucket = []byte("user_orders")
+	// 幂等键对应的下单记录，value为json
+	idempotencyBucket = []byte("idempotency_keys")
+	// 幂等键按过期时间排序的索引，key为过期时间加幂等键，用来删除过期的记录
+	idempotencyExpiresBucket = []byte("idempotency_expires")
+)
+
+// 数据保存在本地bolt数据库中的结构体，重启后订单不会丢失
+type boltOrderStore struct {
+	db *bolt.DB
+}
+
+// 保存订单
+func (s *boltOrderStore) SaveOrder(ctx context.Context, order *pb.Order) error {
+	data, err := proto.Marshal(order)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		id := []byte(order.GetOrder().GetOrderId())
+		if err := tx.Bucket(ordersBucket).Put(id, data); err != nil {
+			return err
+		}
+		user, err := tx.Bucket(userOrdersBucket).CreateBucketIfNotExists([]byte(order.GetUserId()))
+		if err != nil {
+			return err
+		}
+		return user.Put(timeKey(order.GetCreatedAt(), id), id)
+	})
+}
+
+// 查询订单
+func (s *boltOrderStore) GetOrder(ctx context.Context, orderID string) (*pb.Order, error) {
+	out := new(pb.Order)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(ordersBucket).Get([]byte(orderID))
+		if data == nil {
+			return ErrOrderNotFound
+		}
+		return proto.Unmarshal(data, out)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// 查询用户的订单，从索引的最后一个key开始倒序遍历
+func (s *boltOrderStore) ListOrders(ctx context.Context, userID string, offset, limit int) ([]*pb.Order, int, error) {
+	var (
+		out   []*pb.Order
+		total int
+	)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		user := tx.Bucket(userOrdersBucket).Bucket([]byte(userID))
+		if user == nil {
+			return nil
+		}
+		total = user.Stats().KeyN
+		orders := tx.Bucket(ordersBucket)
+		c := user.Cursor()
+		i := 0
+		for k, id := c.Last(); k != nil && len(out) < limit; k, id = c.Prev() {
+			if i++; i <= offset {
+				continue
+			}
+			order := new(pb.Order)
+			if err := proto.Unmarshal(orders.Get(id), order); err != nil {
+				return err
+			}
+			out = append(out, order)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	return out, total, nil
+}
+
+// 保存幂等键，删除已有记录的过期索引，再从索引开头删除过期的记录
+func (s *boltOrderStore) SaveIdempotencyKey(ctx context.Context, key string, record *IdempotencyRecord) error {
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		keys := tx.Bucket(idempotencyBucket)
+		expires := tx.Bucket(idempotencyExpiresBucket)
+		if old := keys.Get([]byte(key)); old != nil {
+			var r IdempotencyRecord
+			if err := json.Unmarshal(old, &r); err != nil {
+				return err
+			}
+			if err := expires.Delete(timeKey(r.Expires.UnixNano(), []byte(key))); err != nil {
+				return err
+			}
+		}
+		if err := keys.Put([]byte(key), data); err != nil {
+			return err
+		}
+		if err := expires.Put(timeKey(record.Expires.UnixNano(), []byte(key)), []byte(key)); err != nil {
+			return err
+		}
+		now := timeKey(time.Now().UnixNano(), nil)
+		c := expires.Cursor()
+		for k, v := c.First(); k != nil && bytes.Compare(k, now) < 0; k, v = c.First() {
+			if err := keys.Delete(v); err != nil {
+				return err
+			}
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 查询幂等键
+func (s *boltOrderStore) GetIdempotencyKey(ctx context.Context, key string, now time.Time) (*IdempotencyRecord, error) {
+	out := new(IdempotencyRecord)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(idempotencyBucket).Get([]byte(key))
+		if data == nil {
+			return ErrIdempotencyKeyNotFound
+		}
+		return json.Unmarshal(data, out)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if now.After(out.Expires) {
+		return nil, ErrIdempotencyKeyNotFound
+	}
+	return out, nil
+}
+
+// 按时间排序的索引的key，8字节的时间加id，用于用户订单索引和幂等键过期索引
+func timeKey(t int64, id []byte) []byte {
+	key := make([]byte, 8, 8+len(id))
+	binary.BigEndian.PutUint64(key, uint64(t))
+	return append(key, id...)
+}
@@ -0,0 +1,72 @@
+package orderstore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	pb "checkoutservice/proto"
+)
+
+var (
+	// 订单不存在
+	ErrOrderNotFound = errors.New("订单不存在")
+	// 幂等键不存在或者已经过期
+	ErrIdempotencyKeyNotFound = errors.New("幂等键不存在")
+)
+
+// 幂等键对应的下单记录
+type IdempotencyRecord struct {
+	// 第一次下单的订单id
+	OrderID string `json:"order_id"`
+	// 下单请求的摘要，相同的幂等键只能用于内容相同的请求
+	RequestHash string `json:"request_hash"`
+	// 过期时间，过期后相同的幂等键会重新下单
+	Expires time.Time `json:"expires"`
+}
+
+// 订单接口
+type OrderStore interface {
+	// 保存订单
+	SaveOrder(ctx context.Context, order *pb.Order) error
+	// 查询订单，不存在时返回ErrOrderNotFound
+	GetOrder(ctx context.Context, orderID string) (*pb.Order, error)
+	// 按下单时间倒序查询用户的订单，跳过前offset个，最多返回limit个，同时返回用户的订单总数
+	ListOrders(ctx context.Context, userID string, offset, limit int) ([]*pb.Order, int, error)
+	// 保存幂等键对应的下单记录，覆盖已有的记录，同时删除已经过期的记录
+	SaveIdempotencyKey(ctx context.Context, key string, record *IdempotencyRecord) error
+	// 查询幂等键对应的下单记录，不存在或者在now之前过期时返回ErrIdempotencyKeyNotFound
+	GetIdempotencyKey(ctx context.Context, key string, now time.Time) (*IdempotencyRecord, error)
+}
+
+// 实例化OrderStore
+func NewMemoryOrderStore() OrderStore {
+	return &memoryOrderStore{
+		orders: make(map[string]*pb.Order),
+		users:  make(map[string][]string),
+		keys:   make(map[string]IdempotencyRecord),
+	}
+}
+
+// 实例化基于bolt文件的OrderStore，path为数据库文件路径
+func NewBoltOrderStore(path string) (OrderStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{ordersBucket, userOrdersBucket, idempotencyBucket, idempotencyExpiresBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltOrderStore{db: db}, nil
+}
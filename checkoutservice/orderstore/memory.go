@@ -0,0 +1,96 @@
+package orderstore
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "checkoutservice/proto"
+)
+
+// 数据保存在内存中的结构体，重启后订单会丢失
+type memoryOrderStore struct {
+	// 读写锁
+	sync.RWMutex
+	// key为订单id
+	orders map[string]*pb.Order
+	// 每个用户的订单id，按下单时间排列
+	users map[string][]string
+	// 幂等键对应的下单记录
+	keys map[string]IdempotencyRecord
+}
+
+// 保存订单
+func (s *memoryOrderStore) SaveOrder(ctx context.Context, order *pb.Order) error {
+	s.Lock()
+	defer s.Unlock()
+	id := order.GetOrder().GetOrderId()
+	if _, ok := s.orders[id]; !ok {
+		// 和bolt的索引相同，按下单时间和订单id排序
+		ids := s.users[order.GetUserId()]
+		i := sort.Search(len(ids), func(i int) bool {
+			o := s.orders[ids[i]]
+			if o.GetCreatedAt() != order.GetCreatedAt() {
+				return o.GetCreatedAt() > order.GetCreatedAt()
+			}
+			return ids[i] > id
+		})
+		ids = append(ids, "")
+		copy(ids[i+1:], ids[i:])
+		ids[i] = id
+		s.users[order.GetUserId()] = ids
+	}
+	s.orders[id] = proto.Clone(order).(*pb.Order)
+	return nil
+}
+
+// 查询订单
+func (s *memoryOrderStore) GetOrder(ctx context.Context, orderID string) (*pb.Order, error) {
+	s.RLock()
+	defer s.RUnlock()
+	order, ok := s.orders[orderID]
+	if !ok {
+		return nil, ErrOrderNotFound
+	}
+	return proto.Clone(order).(*pb.Order), nil
+}
+
+// 查询用户的订单，最后下单的在前
+func (s *memoryOrderStore) ListOrders(ctx context.Context, userID string, offset, limit int) ([]*pb.Order, int, error) {
+	s.RLock()
+	defer s.RUnlock()
+	ids := s.users[userID]
+	var out []*pb.Order
+	for i := len(ids) - 1 - offset; i >= 0 && len(out) < limit; i-- {
+		out = append(out, proto.Clone(s.orders[ids[i]]).(*pb.Order))
+	}
+	return out, len(ids), nil
+}
+
+// 保存幂等键，顺便删除过期的记录
+func (s *memoryOrderStore) SaveIdempotencyKey(ctx context.Context, key string, record *IdempotencyRecord) error {
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	for k, r := range s.keys {
+		if now.After(r.Expires) {
+			delete(s.keys, k)
+		}
+	}
+	s.keys[key] = *record
+	return nil
+}
+
+// 查询幂等键
+func (s *memoryOrderStore) GetIdempotencyKey(ctx context.Context, key string, now time.Time) (*IdempotencyRecord, error) {
+	s.RLock()
+	defer s.RUnlock()
+	r, ok := s.keys[key]
+	if !ok || now.After(r.Expires) {
+		return nil, ErrIdempotencyKeyNotFound
+	}
+	return &r, nil
+}
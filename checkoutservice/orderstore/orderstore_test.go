@@ -0,0 +1,192 @@
+package orderstore
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "checkoutservice/proto"
+)
+
+// 被测试的存储，open每次返回一个空的存储
+var orderStores = []struct {
+	name string
+	open func(t *testing.T) OrderStore
+}{
+	{"memory", func(t *testing.T) OrderStore { return NewMemoryOrderStore() }},
+	{"bolt", func(t *testing.T) OrderStore {
+		s, err := NewBoltOrderStore(filepath.Join(t.TempDir(), "orders.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.(*boltOrderStore).db.Close() })
+		return s
+	}},
+}
+
+// 测试用的订单，createdAt为下单时间
+func testOrder(id, userID string, createdAt int64) *pb.Order {
+	return &pb.Order{
+		Order: &pb.OrderResult{
+			OrderId:         id,
+			ShippingAddress: &pb.Address{City: "北京"},
+			Total:           &pb.Money{CurrencyCode: "CNY", Units: 100},
+		},
+		UserId:    userID,
+		CreatedAt: createdAt,
+		TotalPaid: &pb.Money{CurrencyCode: "CNY", Units: 100},
+	}
+}
+
+func TestSaveGetOrder(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range orderStores {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.open(t)
+			if _, err := s.GetOrder(ctx, "o1"); err != ErrOrderNotFound {
+				t.Fatalf("查询不存在的订单返回 %v", err)
+			}
+			want := testOrder("o1", "u1", 100)
+			if err := s.SaveOrder(ctx, want); err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.GetOrder(ctx, "o1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(got, want) {
+				t.Fatalf("查询到的订单为 %v，期望 %v", got, want)
+			}
+			// 返回的是副本，修改后不影响保存的订单
+			got.UserId = "u2"
+			if again, _ := s.GetOrder(ctx, "o1"); again.GetUserId() != "u1" {
+				t.Fatalf("修改查询结果后保存的订单变成 %v", again)
+			}
+		})
+	}
+}
+
+func TestListOrders(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range orderStores {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.open(t)
+			// 保存顺序和下单时间不同，按下单时间倒序返回
+			for _, createdAt := range []int64{300, 100, 500, 200, 400} {
+				if err := s.SaveOrder(ctx, testOrder(fmt.Sprintf("o%d", createdAt), "u1", createdAt)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := s.SaveOrder(ctx, testOrder("other", "u2", 600)); err != nil {
+				t.Fatal(err)
+			}
+			pages := []struct {
+				offset, limit int
+				want          []string
+			}{
+				{0, 2, []string{"o500", "o400"}},
+				{2, 2, []string{"o300", "o200"}},
+				{4, 2, []string{"o100"}},
+				{6, 2, nil},
+				{0, 10, []string{"o500", "o400", "o300", "o200", "o100"}},
+			}
+			for _, p := range pages {
+				orders, total, err := s.ListOrders(ctx, "u1", p.offset, p.limit)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if total != 5 {
+					t.Fatalf("u1 的订单总数为 %d，期望 5", total)
+				}
+				var ids []string
+				for _, o := range orders {
+					ids = append(ids, o.GetOrder().GetOrderId())
+				}
+				if fmt.Sprint(ids) != fmt.Sprint(p.want) {
+					t.Fatalf("ListOrders(offset=%d, limit=%d) 返回 %v，期望 %v", p.offset, p.limit, ids, p.want)
+				}
+			}
+			if orders, total, err := s.ListOrders(ctx, "u3", 0, 10); err != nil || total != 0 || len(orders) != 0 {
+				t.Fatalf("没有订单的用户返回 %v, %d, %v", orders, total, err)
+			}
+		})
+	}
+}
+
+func TestIdempotencyKey(t *testing.T) {
+	ctx := context.Background()
+	for _, tt := range orderStores {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.open(t)
+			now := time.Now()
+			if _, err := s.GetIdempotencyKey(ctx, "u1/k1", now); err != ErrIdempotencyKeyNotFound {
+				t.Fatalf("查询不存在的幂等键返回 %v", err)
+			}
+			want := &IdempotencyRecord{OrderID: "o1", RequestHash: "h1", Expires: now.Add(time.Hour)}
+			if err := s.SaveIdempotencyKey(ctx, "u1/k1", want); err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.GetIdempotencyKey(ctx, "u1/k1", now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.OrderID != want.OrderID || got.RequestHash != want.RequestHash || !got.Expires.Equal(want.Expires) {
+				t.Fatalf("幂等键记录为 %+v，期望 %+v", got, want)
+			}
+			if _, err := s.GetIdempotencyKey(ctx, "u1/k1", now.Add(2*time.Hour)); err != ErrIdempotencyKeyNotFound {
+				t.Fatalf("查询过期的幂等键返回 %v", err)
+			}
+
+			// 保存新的记录时删除过期的记录
+			expired := &IdempotencyRecord{OrderID: "o2", RequestHash: "h2", Expires: now.Add(-time.Minute)}
+			if err := s.SaveIdempotencyKey(ctx, "u1/k2", expired); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SaveIdempotencyKey(ctx, "u1/k3", want); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.GetIdempotencyKey(ctx, "u1/k2", now.Add(-time.Hour)); err != ErrIdempotencyKeyNotFound {
+				t.Fatalf("过期的幂等键没有删除: %v", err)
+			}
+			if _, err := s.GetIdempotencyKey(ctx, "u1/k1", now); err != nil {
+				t.Fatalf("未过期的幂等键被删除: %v", err)
+			}
+		})
+	}
+}
+
+func TestBoltIdempotencyKeyReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "orders.db")
+	s, err := NewBoltOrderStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := &IdempotencyRecord{OrderID: "o1", RequestHash: "h1", Expires: time.Now().Add(time.Hour)}
+	if err := s.SaveIdempotencyKey(ctx, "u1/k1", record); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.(*boltOrderStore).db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = NewBoltOrderStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.(*boltOrderStore).db.Close()
+	got, err := s.GetIdempotencyKey(ctx, "u1/k1", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.OrderID != "o1" {
+		t.Fatalf("重新打开后幂等键对应的订单为 %s", got.OrderID)
+	}
+}
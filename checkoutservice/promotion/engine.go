@@ -0,0 +1,261 @@
+package promotion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	pb "checkoutservice/proto"
+	"money"
+)
+
+var (
+	// 优惠码不存在或者不在有效期内
+	ErrInvalidCode = errors.New("优惠码无效")
+	// 优惠码不适用于购物车中的商品
+	ErrCodeNotApplicable = errors.New("优惠码不适用于当前购物车")
+)
+
+// 订单中的一行商品，单价使用订单的货币
+type Line struct {
+	ProductID  string
+	Categories []string
+	Quantity   int32
+	Price      *pb.Money
+}
+
+// 计算结果
+type Result struct {
+	// 使用的优惠
+	Promotions []*pb.AppliedPromotion
+	// 每行商品分到的优惠金额，和传入的商品一一对应
+	LineDiscounts []*pb.Money
+}
+
+// 货币转换，减免金额和订单货币不同时使用
+type ConvertFunc func(ctx context.Context, from *pb.Money, toCurrency string) (*pb.Money, error)
+
+// 优惠引擎，规则创建后不再修改，可以并发使用
+type Engine struct {
+	rules  []*Rule
+	byCode map[string]*Rule
+}
+
+// 优惠规则文件的格式
+type rulesFile struct {
+	Promotions []Rule `json:"promotions"`
+}
+
+// 创建优惠引擎，规则的id和优惠码不能重复
+func NewEngine(rules []Rule) (*Engine, error) {
+	e := &Engine{byCode: make(map[string]*Rule)}
+	ids := make(map[string]bool, len(rules))
+	for i := range rules {
+		r := rules[i]
+		if err := r.validate(); err != nil {
+			return nil, err
+		}
+		if ids[r.ID] {
+			return nil, fmt.Errorf("优惠id重复: %s", r.ID)
+		}
+		ids[r.ID] = true
+		if r.Code != "" {
+			r.Code = normalizeCode(r.Code)
+			if _, ok := e.byCode[r.Code]; ok {
+				return nil, fmt.Errorf("优惠码重复: %s", r.Code)
+			}
+			e.byCode[r.Code] = &r
+		}
+		e.rules = append(e.rules, &r)
+	}
+	return e, nil
+}
+
+// 从json文件加载优惠规则
+func LoadFile(path string) (*Engine, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f rulesFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("解析优惠规则失败: %v", err)
+	}
+	return NewEngine(f.Promotions)
+}
+
+// 计算订单使用的优惠，code为用户输入的优惠码，可以为空。
+// 每行商品只使用优惠最多的一个打折或买赠优惠，减免金额的优惠在打折后的金额上计算，不会超过适用商品的金额
+func (e *Engine) Apply(ctx context.Context, currency string, lines []Line, code string, now time.Time, convert ConvertFunc) (*Result, error) {
+	var coded *Rule
+	if code != "" {
+		if e != nil {
+			coded = e.byCode[normalizeCode(code)]
+		}
+		if coded == nil || !coded.active(now) {
+			return nil, ErrInvalidCode
+		}
+	}
+	out := &Result{LineDiscounts: make([]*pb.Money, len(lines))}
+	for i := range lines {
+		out.LineDiscounts[i] = &pb.Money{CurrencyCode: currency}
+	}
+	if e == nil {
+		return out, nil
+	}
+	var rules []*Rule
+	for _, r := range e.rules {
+		if r == coded || (r.Code == "" && r.active(now)) {
+			rules = append(rules, r)
+		}
+	}
+
+	discounts := make(map[*Rule]*pb.Money)
+	addDiscount := func(r *Rule, d *pb.Money) (err error) {
+		if discounts[r] == nil {
+			discounts[r] = d
+			return nil
+		}
+		discounts[r], err = money.Sum(discounts[r], d)
+		return err
+	}
+	// 每行商品的金额和扣除优惠后的金额
+	totals := make([]*pb.Money, len(lines))
+	remaining := make([]*pb.Money, len(lines))
+	for i, line := range lines {
+		total, err := money.Multiply(line.Price, uint32(line.Quantity))
+		if err != nil {
+			return nil, err
+		}
+		totals[i], remaining[i] = total, total
+		var (
+			best     *pb.Money
+			bestRule *Rule
+		)
+		for _, r := range rules {
+			if r.Type == FixedOff || !r.matches(line) {
+				continue
+			}
+			d, err := r.lineDiscount(line, total)
+			if err != nil {
+				return nil, err
+			}
+			if c, err := compare(d, best); err != nil {
+				return nil, err
+			} else if c > 0 && money.IsPositive(d) {
+				best, bestRule = d, r
+			}
+		}
+		if bestRule != nil {
+			if err := addDiscount(bestRule, best); err != nil {
+				return nil, err
+			}
+			if remaining[i], err = money.Subtract(total, best); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, r := range rules {
+		if r.Type != FixedOff {
+			continue
+		}
+		scoped := &pb.Money{CurrencyCode: currency}
+		for i, line := range lines {
+			if r.matches(line) {
+				var err error
+				if scoped, err = money.Sum(scoped, remaining[i]); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if !money.IsPositive(scoped) {
+			continue
+		}
+		amount, err := r.amountIn(ctx, currency, convert)
+		if err != nil {
+			return nil, err
+		}
+		if amount, err = minMoney(amount, scoped); err != nil {
+			return nil, err
+		}
+		if err := addDiscount(r, amount); err != nil {
+			return nil, err
+		}
+		// 从适用的商品中依次扣除，后面的减免优惠不会重复计算同一部分金额
+		for i, line := range lines {
+			if money.IsZero(amount) {
+				break
+			}
+			if !r.matches(line) {
+				continue
+			}
+			d, err := minMoney(remaining[i], amount)
+			if err != nil {
+				return nil, err
+			}
+			if remaining[i], err = money.Subtract(remaining[i], d); err != nil {
+				return nil, err
+			}
+			if amount, err = money.Subtract(amount, d); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if coded != nil && !money.IsPositive(discounts[coded]) {
+		return nil, ErrCodeNotApplicable
+	}
+	for _, r := range rules {
+		if d := discounts[r]; money.IsPositive(d) {
+			out.Promotions = append(out.Promotions, &pb.AppliedPromotion{
+				PromotionId: r.ID,
+				Description: r.Description,
+				Discount:    d,
+			})
+		}
+	}
+	for i := range lines {
+		d, err := money.Subtract(totals[i], remaining[i])
+		if err != nil {
+			return nil, err
+		}
+		out.LineDiscounts[i] = d
+	}
+	return out, nil
+}
+
+// 减免金额转换成订单的货币
+func (r *Rule) amountIn(ctx context.Context, currency string, convert ConvertFunc) (*pb.Money, error) {
+	amount := r.AmountOff
+	if amount.GetCurrencyCode() != currency {
+		if convert == nil {
+			return nil, fmt.Errorf("优惠 %s 的货币 %s 不能转换成 %s", r.ID, amount.GetCurrencyCode(), currency)
+		}
+		var err error
+		if amount, err = convert(ctx, amount, currency); err != nil {
+			return nil, err
+		}
+	}
+	return amount, nil
+}
+
+// 比较两个金额，r为nil时l更大
+func compare(l, r *pb.Money) (int, error) {
+	if r == nil {
+		return 1, nil
+	}
+	return money.Compare(l, r)
+}
+
+// 两个金额中较小的一个
+func minMoney(l, r *pb.Money) (*pb.Money, error) {
+	c, err := money.Compare(l, r)
+	if err != nil || c <= 0 {
+		return l, err
+	}
+	return r, nil
+}
@@ -0,0 +1,90 @@
+package promotion
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "checkoutservice/proto"
+	"money"
+)
+
+func usd(units int64, nanos int32) *pb.Money {
+	return &pb.Money{CurrencyCode: "USD", Units: units, Nanos: nanos}
+}
+
+func newTestEngine(t *testing.T, rules ...Rule) *Engine {
+	t.Helper()
+	e, err := NewEngine(rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func TestApplyPercentOffRounding(t *testing.T) {
+	e := newTestEngine(t, Rule{ID: "p15", Type: PercentOff, Percent: 15})
+	lines := []Line{
+		{ProductID: "a", Quantity: 1, Price: usd(9, 990000000)},
+		{ProductID: "b", Quantity: 3, Price: usd(0, 330000000)},
+	}
+	out, err := e.Apply(context.Background(), "USD", lines, "", time.Now(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 9.99的15%为1.4985，0.99的15%为0.1485，每行分别舍入到分
+	want := []*pb.Money{usd(1, 500000000), usd(0, 150000000)}
+	for i, d := range out.LineDiscounts {
+		if !money.AreEquals(d, want[i]) {
+			t.Errorf("第%d行的优惠为 %v，期望 %v", i+1, d, want[i])
+		}
+	}
+	if len(out.Promotions) != 1 || !money.AreEquals(out.Promotions[0].Discount, usd(1, 650000000)) {
+		t.Fatalf("使用的优惠为 %v，期望合计1.65", out.Promotions)
+	}
+}
+
+func TestApplyFixedOffAfterPercentOff(t *testing.T) {
+	e := newTestEngine(t,
+		Rule{ID: "p50", Type: PercentOff, Percent: 50, ProductIDs: []string{"a"}},
+		Rule{ID: "off", Type: FixedOff, Code: "save5", AmountOff: usd(5, 0)},
+	)
+	lines := []Line{
+		{ProductID: "a", Quantity: 1, Price: usd(4, 0)},
+		{ProductID: "b", Quantity: 2, Price: usd(1, 0)},
+	}
+	out, err := e.Apply(context.Background(), "USD", lines, "SAVE5", time.Now(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 打折后剩余2+2=4，减免金额不超过剩余的金额
+	want := []*pb.Money{usd(4, 0), usd(2, 0)}
+	for i, d := range out.LineDiscounts {
+		if !money.AreEquals(d, want[i]) {
+			t.Errorf("第%d行的优惠为 %v，期望 %v", i+1, d, want[i])
+		}
+	}
+}
+
+func TestApplyBuyXGetY(t *testing.T) {
+	e := newTestEngine(t, Rule{ID: "b2g1", Type: BuyXGetY, Buy: 2, Get: 1})
+	lines := []Line{{ProductID: "a", Quantity: 7, Price: usd(2, 500000000)}}
+	out, err := e.Apply(context.Background(), "USD", lines, "", time.Now(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !money.AreEquals(out.LineDiscounts[0], usd(5, 0)) {
+		t.Fatalf("买2送1，7件的优惠为 %v，期望5.00", out.LineDiscounts[0])
+	}
+}
+
+func TestApplyInvalidCode(t *testing.T) {
+	e := newTestEngine(t, Rule{ID: "off", Type: FixedOff, Code: "SAVE5", AmountOff: usd(5, 0), ProductIDs: []string{"a"}})
+	lines := []Line{{ProductID: "b", Quantity: 1, Price: usd(10, 0)}}
+	if _, err := e.Apply(context.Background(), "USD", lines, "NOPE", time.Now(), nil); err != ErrInvalidCode {
+		t.Fatalf("不存在的优惠码返回 %v，期望 %v", err, ErrInvalidCode)
+	}
+	if _, err := e.Apply(context.Background(), "USD", lines, "save5", time.Now(), nil); err != ErrCodeNotApplicable {
+		t.Fatalf("不适用的优惠码返回 %v，期望 %v", err, ErrCodeNotApplicable)
+	}
+}
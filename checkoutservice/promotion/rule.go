@@ -0,0 +1,120 @@
+package promotion
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	pb "checkoutservice/proto"
+	"money"
+)
+
+// 优惠类型
+type Type string
+
+const (
+	// 按百分比打折
+	PercentOff Type = "percent_off"
+	// 减免固定金额
+	FixedOff Type = "fixed_off"
+	// 买Buy件送Get件
+	BuyXGetY Type = "buy_x_get_y"
+)
+
+// 优惠规则
+type Rule struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	Type        Type   `json:"type"`
+	// 优惠码，为空表示满足条件时自动使用
+	Code string `json:"code,omitempty"`
+	// 适用的商品id和分类，满足其中一个即可，都为空表示适用于所有商品
+	ProductIDs []string `json:"product_ids,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+	// 打折的百分比，percent_off使用，例如20表示8折
+	Percent int32 `json:"percent,omitempty"`
+	// 减免的金额，fixed_off使用，和订单货币不同时按汇率转换
+	AmountOff *pb.Money `json:"amount_off,omitempty"`
+	// 买几件送几件，buy_x_get_y使用
+	Buy int32 `json:"buy,omitempty"`
+	Get int32 `json:"get,omitempty"`
+	// 有效期，零值表示不限制
+	StartTime time.Time `json:"start_time,omitempty"`
+	EndTime   time.Time `json:"end_time,omitempty"`
+}
+
+// 校验规则
+func (r *Rule) validate() error {
+	if r.ID == "" {
+		return fmt.Errorf("优惠id不能为空")
+	}
+	switch r.Type {
+	case PercentOff:
+		if r.Percent <= 0 || r.Percent > 100 {
+			return fmt.Errorf("优惠 %s 的百分比必须在1到100之间: %d", r.ID, r.Percent)
+		}
+	case FixedOff:
+		if r.AmountOff.GetCurrencyCode() == "" || !money.IsPositive(r.AmountOff) {
+			return fmt.Errorf("优惠 %s 的减免金额无效", r.ID)
+		}
+	case BuyXGetY:
+		if r.Buy <= 0 || r.Get <= 0 {
+			return fmt.Errorf("优惠 %s 的买赠数量必须大于0", r.ID)
+		}
+	default:
+		return fmt.Errorf("优惠 %s 的类型不支持: %q", r.ID, r.Type)
+	}
+	if !r.StartTime.IsZero() && !r.EndTime.IsZero() && !r.EndTime.After(r.StartTime) {
+		return fmt.Errorf("优惠 %s 的结束时间必须晚于开始时间", r.ID)
+	}
+	return nil
+}
+
+// 是否在有效期内
+func (r *Rule) active(now time.Time) bool {
+	if !r.StartTime.IsZero() && now.Before(r.StartTime) {
+		return false
+	}
+	if !r.EndTime.IsZero() && !now.Before(r.EndTime) {
+		return false
+	}
+	return true
+}
+
+// 是否适用于这行商品
+func (r *Rule) matches(line Line) bool {
+	if len(r.ProductIDs) == 0 && len(r.Categories) == 0 {
+		return true
+	}
+	for _, id := range r.ProductIDs {
+		if id == line.ProductID {
+			return true
+		}
+	}
+	for _, c := range r.Categories {
+		for _, lc := range line.Categories {
+			if c == lc {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 打折和买赠优惠在这行商品上的优惠金额，total为这行商品的金额。
+// 打折的金额四舍五入到货币的最小单位
+func (r *Rule) lineDiscount(line Line, total *pb.Money) (*pb.Money, error) {
+	switch r.Type {
+	case PercentOff:
+		return money.MultiplyByRatio(total, int64(r.Percent), 100, money.RoundHalfUp)
+	case BuyXGetY:
+		free := line.Quantity / (r.Buy + r.Get) * r.Get
+		return money.Multiply(line.Price, uint32(free))
+	}
+	return &pb.Money{CurrencyCode: total.GetCurrencyCode()}, nil
+}
+
+// 优惠码不区分大小写
+func normalizeCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
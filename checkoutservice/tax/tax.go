@@ -0,0 +1,139 @@
+package tax
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	pb "checkoutservice/proto"
+	"money"
+)
+
+// 税率规则，按配送地址的国家、省和商品分类匹配
+type Rule struct {
+	Country string `json:"country"`
+	// 为空表示适用于整个国家
+	State string `json:"state,omitempty"`
+	// 适用的商品分类，为空表示适用于所有商品
+	Categories []string `json:"categories,omitempty"`
+	// 税率，百分比，例如 "7.25"
+	Rate string `json:"rate"`
+
+	rate *big.Rat
+}
+
+// 规则匹配的优先级，省比分类优先，不匹配时返回-1
+func (r *Rule) score(country, state string, categories []string) int {
+	if normalize(r.Country) != country {
+		return -1
+	}
+	score := 0
+	if r.State != "" {
+		if normalize(r.State) != state {
+			return -1
+		}
+		score += 2
+	}
+	if len(r.Categories) > 0 {
+		if !hasCategory(r.Categories, categories) {
+			return -1
+		}
+		score++
+	}
+	return score
+}
+
+// 税费计算，规则创建后不再修改，可以并发使用
+type Calculator struct {
+	rules []*Rule
+}
+
+// 税率规则文件的格式
+type rulesFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// 创建税费计算
+func NewCalculator(rules []Rule) (*Calculator, error) {
+	c := new(Calculator)
+	for i := range rules {
+		r := rules[i]
+		if r.Country == "" {
+			return nil, fmt.Errorf("第%d条税率规则的国家不能为空", i+1)
+		}
+		rate, ok := new(big.Rat).SetString(r.Rate)
+		if !ok || rate.Sign() < 0 {
+			return nil, fmt.Errorf("第%d条税率规则的税率无效: %q", i+1, r.Rate)
+		}
+		r.rate = rate.Quo(rate, big.NewRat(100, 1))
+		c.rules = append(c.rules, &r)
+	}
+	return c, nil
+}
+
+// 从json文件加载税率规则
+func LoadFile(path string) (*Calculator, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f rulesFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("解析税率规则失败: %v", err)
+	}
+	return NewCalculator(f.Rules)
+}
+
+// 订单中的一行商品
+type Line struct {
+	Categories []string
+	// 应税金额，商品金额减去优惠，使用订单的货币
+	Amount *pb.Money
+}
+
+// 计算订单的税费，每行商品使用优先级最高的规则，没有匹配的规则时不收税。
+// 使用精确的分数计算，最后四舍五入到货币的最小单位
+func (c *Calculator) Calculate(address *pb.Address, currency string, lines []Line) (*pb.Money, error) {
+	total := new(big.Rat)
+	if c != nil && address != nil {
+		country, state := normalize(address.GetCountry()), normalize(address.GetState())
+		for _, line := range lines {
+			var (
+				best  *Rule
+				score = -1
+			)
+			for _, r := range c.rules {
+				if s := r.score(country, state, line.Categories); s > score {
+					best, score = r, s
+				}
+			}
+			if best == nil {
+				continue
+			}
+			if !money.IsValid(line.Amount) {
+				return nil, money.ErrInvalidValue
+			}
+			total.Add(total, new(big.Rat).Mul(money.ToRat(line.Amount), best.rate))
+		}
+	}
+	return money.FromRat(&pb.Money{}, currency, total, money.RoundHalfUp)
+}
+
+// 国家和省不区分大小写
+func normalize(s string) string {
+	return strings.ToLower(strings.TrimSpace(s))
+}
+
+// 商品是否属于其中一个分类
+func hasCategory(ruleCategories, categories []string) bool {
+	for _, rc := range ruleCategories {
+		for _, c := range categories {
+			if rc == c {
+				return true
+			}
+		}
+	}
+	return false
+}
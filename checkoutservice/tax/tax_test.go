@@ -0,0 +1,82 @@
+package tax
+
+import (
+	"testing"
+
+	pb "checkoutservice/proto"
+	"money"
+)
+
+func newTestCalculator(t *testing.T) *Calculator {
+	t.Helper()
+	c, err := NewCalculator([]Rule{
+		{Country: "中国", Rate: "13"},
+		{Country: "中国", Categories: []string{"kitchen"}, Rate: "9"},
+		{Country: "United States", State: "CA", Rate: "7.25"},
+		{Country: "Japan", Rate: "10"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestCalculate(t *testing.T) {
+	c := newTestCalculator(t)
+	tests := []struct {
+		name     string
+		address  *pb.Address
+		currency string
+		lines    []Line
+		want     *pb.Money
+	}{
+		{
+			name:     "按分类匹配更具体的规则",
+			address:  &pb.Address{Country: "中国"},
+			currency: "CNY",
+			lines: []Line{
+				{Amount: &pb.Money{CurrencyCode: "CNY", Units: 100}},
+				{Categories: []string{"kitchen"}, Amount: &pb.Money{CurrencyCode: "CNY", Units: 100}},
+			},
+			want: &pb.Money{CurrencyCode: "CNY", Units: 22},
+		},
+		{
+			// 9.99 * 7.25% = 0.724275
+			name:     "四舍五入到分",
+			address:  &pb.Address{Country: "united states", State: "ca"},
+			currency: "USD",
+			lines:    []Line{{Amount: &pb.Money{CurrencyCode: "USD", Units: 9, Nanos: 990000000}}},
+			want:     &pb.Money{CurrencyCode: "USD", Nanos: 720000000},
+		},
+		{
+			// 1005 * 10% = 100.5
+			name:     "日元四舍五入到元",
+			address:  &pb.Address{Country: "Japan"},
+			currency: "JPY",
+			lines:    []Line{{Amount: &pb.Money{CurrencyCode: "JPY", Units: 1005}}},
+			want:     &pb.Money{CurrencyCode: "JPY", Units: 101},
+		},
+		{
+			name:     "没有匹配的规则不收税",
+			address:  &pb.Address{Country: "United States", State: "NY"},
+			currency: "USD",
+			lines:    []Line{{Amount: &pb.Money{CurrencyCode: "USD", Units: 10}}},
+			want:     &pb.Money{CurrencyCode: "USD"},
+		},
+		{
+			name:     "没有地址不收税",
+			currency: "USD",
+			lines:    []Line{{Amount: &pb.Money{CurrencyCode: "USD", Units: 10}}},
+			want:     &pb.Money{CurrencyCode: "USD"},
+		},
+	}
+	for _, tt := range tests {
+		got, err := c.Calculate(tt.address, tt.currency, tt.lines)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !money.AreEquals(got, tt.want) {
+			t.Errorf("%s: 税费为 %v，期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
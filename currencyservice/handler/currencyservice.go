@@ -2,67 +2,105 @@ package handler
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
-	"io/ioutil"
-	"math"
+	"math/big"
+	"time"
 
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
 	pb "currencyservice/proto"
+	"money"
 )
 
-type CurrencyService struct{}
+type CurrencyService struct {
+	// 汇率表
+	Rates *RateTable
+}
 
 // 获得货币
 func (s *CurrencyService) GetSupportedCurrencies(ctx context.Context, in *pb.Empty) (out *pb.GetSupportedCurrenciesResponse, e error) {
-	data, err := ioutil.ReadFile("data/currency_conversion.json")
+	codes := s.Rates.snapshot().codes
+	out = new(pb.GetSupportedCurrenciesResponse)
+	out.CurrencyCodes = make([]string, len(codes))
+	copy(out.CurrencyCodes, codes)
+	return out, nil
+}
 
+// 转换，按汇率精确计算，结果按银行家舍入到目标货币的最小单位，例如美元到分、日元到元，负数按绝对值舍入。
+// 请求带as_of时使用该时间生效的汇率，响应中返回使用的汇率版本
+func (s *CurrencyService) Convert(ctx context.Context, in *pb.CurrencyConversionRequest) (out *pb.CurrencyConversionResponse, e error) {
+	snapshot, err := s.snapshotAt(in.GetAsOf())
 	if err != nil {
-		return nil, status.Errorf(codes.Internal, "加载货币数据失败: %+v", err)
+		return nil, err
 	}
-
-	currencies := make(map[string]float32)
-	if err := json.Unmarshal(data, &currencies); err != nil {
-		return nil, status.Errorf(codes.Internal, "解析货币数据失败: %+v", err)
+	converted, err := convert(snapshot, in.GetFrom(), in.GetToCode())
+	if err != nil {
+		return nil, err
 	}
+	return &pb.CurrencyConversionResponse{Money: converted, RateVersion: snapshot.version}, nil
+}
 
-	fmt.Printf("货币: %v\n", currencies)
-
-	out = new(pb.GetSupportedCurrenciesResponse)
-
-	out.CurrencyCodes = make([]string, 0, len(currencies))
-
-	for k := range currencies {
-		out.CurrencyCodes = append(out.CurrencyCodes, k)
+// 批量转换，所有金额使用同一版本的汇率，有一个金额转换失败时整个请求失败。没有金额时也检查目标货币
+func (s *CurrencyService) ConvertBatch(ctx context.Context, in *pb.ConvertBatchRequest) (*pb.ConvertBatchResponse, error) {
+	snapshot, err := s.snapshotAt(in.GetAsOf())
+	if err != nil {
+		return nil, err
+	}
+	if _, found := snapshot.rates[in.GetToCode()]; !found {
+		return nil, status.Errorf(codes.InvalidArgument, "不支持的币种: %s", in.GetToCode())
+	}
+	out := &pb.ConvertBatchResponse{
+		Money:       make([]*pb.Money, len(in.GetFrom())),
+		RateVersion: snapshot.version,
+	}
+	for i, from := range in.GetFrom() {
+		if out.Money[i], err = convert(snapshot, from, in.GetToCode()); err != nil {
+			return nil, err
+		}
 	}
 	return out, nil
 }
 
-// 转换
-func (s *CurrencyService) Convert(ctx context.Context, in *pb.CurrencyConversionRequest) (out *pb.Money, e error) {
-	data, err := ioutil.ReadFile("data/currency_conversion.json")
-	if err != nil {
-		return nil, status.Errorf(codes.Internal, "加载货币失败: %+v", err)
+// asOf时间生效的汇率，asOf为0时使用当前汇率
+func (s *CurrencyService) snapshotAt(asOf int64) (*rateSnapshot, error) {
+	if asOf == 0 {
+		return s.Rates.snapshot(), nil
 	}
-	currencies := make(map[string]float64)
-	if err := json.Unmarshal(data, &currencies); err != nil {
-		return nil, status.Errorf(codes.Internal, "解析货币失败: %+v", err)
+	snapshot := s.Rates.snapshotAt(time.Unix(asOf, 0))
+	if snapshot == nil {
+		return nil, status.Errorf(codes.NotFound, "没有该时间的汇率: %s", time.Unix(asOf, 0).Format(time.RFC3339))
 	}
-	fromCurrency, found := currencies[in.From.CurrencyCode]
+	return snapshot, nil
+}
+
+// 按汇率快照转换一个金额
+func convert(snapshot *rateSnapshot, from *pb.Money, toCode string) (*pb.Money, error) {
+	if !money.IsValid(from) {
+		return nil, status.Errorf(codes.InvalidArgument, "转换金额失败: %v", money.ErrInvalidValue)
+	}
+	fromRate, found := snapshot.rates[from.GetCurrencyCode()]
 	if !found {
-		return nil, status.Errorf(codes.InvalidArgument, "不支持的币种: %s", in.From.CurrencyCode)
+		return nil, status.Errorf(codes.InvalidArgument, "不支持的币种: %s", from.GetCurrencyCode())
 	}
-	toCurrency, found := currencies[in.ToCode]
+	toRate, found := snapshot.rates[toCode]
 	if !found {
-		return nil, status.Errorf(codes.InvalidArgument, "不支持的币种: %s", in.ToCode)
+		return nil, status.Errorf(codes.InvalidArgument, "不支持的币种: %s", toCode)
 	}
 
-	out = new(pb.Money)
-	out.CurrencyCode = in.ToCode
-	total := int64(math.Floor(float64(in.From.Units*10^9+int64(in.From.Nanos)) / fromCurrency * toCurrency))
-	out.Units = total / 1e9
-	out.Nanos = int32(total % 1e9)
+	// 汇率都是相对于同一种货币，换算比例为 toRate / fromRate，结果舍入到目标货币的最小单位
+	ratio := new(big.Rat).Quo(toRate, fromRate)
+	out, err := money.FromRat(from, toCode, ratio.Mul(ratio, money.ToRat(from)), money.RoundHalfEven)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "转换金额失败: %v", err)
+	}
 	return out, nil
 }
+
+// 获得当前使用的汇率版本
+func (s *CurrencyService) GetRatesVersion(ctx context.Context, in *pb.Empty) (*pb.RatesVersion, error) {
+	snapshot := s.Rates.snapshot()
+	return &pb.RatesVersion{
+		Version:  snapshot.version,
+		LoadedAt: snapshot.loadedAt.Unix(),
+	}, nil
+}
@@ -0,0 +1,224 @@
+package handler
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "currencyservice/proto"
+	"currencyservice/rates"
+	"money"
+)
+
+// 货币最小单位的一半，单位为元
+func halfMinorUnit(code string) *big.Rat {
+	r := big.NewRat(1, 2)
+	for i := 0; i < money.MinorUnits(code); i++ {
+		r.Quo(r, big.NewRat(10, 1))
+	}
+	return r
+}
+
+// 转换成另一种货币再转换回来，误差不超过两次舍入的误差之和
+func TestConvertRoundTrip(t *testing.T) {
+	table, err := NewRateTable(context.Background(), rates.NewJSONProvider("../data/currency_conversion.json"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &CurrencyService{Rates: table}
+	snapshot := table.snapshot()
+	if len(snapshot.codes) < 2 {
+		t.Fatalf("汇率文件中只有%d种货币", len(snapshot.codes))
+	}
+
+	for _, from := range snapshot.codes {
+		for _, to := range snapshot.codes {
+			// 1234.5678舍入到原货币的最小单位
+			in, err := money.FromRat(&pb.Money{}, from, big.NewRat(12345678, 10000), money.RoundHalfEven)
+			if err != nil {
+				t.Fatal(err)
+			}
+			there, err := s.Convert(context.Background(), &pb.CurrencyConversionRequest{From: in, ToCode: to})
+			if err != nil {
+				t.Fatalf("%s -> %s: %v", from, to, err)
+			}
+			converted := there.GetMoney()
+			if converted.GetCurrencyCode() != to {
+				t.Fatalf("%s -> %s 返回的货币为 %s", from, to, converted.GetCurrencyCode())
+			}
+			// 结果是目标货币最小单位的整数倍
+			units := new(big.Rat).Quo(money.ToRat(converted), halfMinorUnit(to))
+			if !units.IsInt() || units.Num().Bit(0) != 0 {
+				t.Fatalf("%s -> %s 的结果 %v 没有舍入到最小单位", from, to, converted)
+			}
+
+			back, err := s.Convert(context.Background(), &pb.CurrencyConversionRequest{From: converted, ToCode: from})
+			if err != nil {
+				t.Fatalf("%s -> %s: %v", to, from, err)
+			}
+			// 第一次舍入的误差按汇率换算回原货币，加上第二次舍入的误差
+			ratio := new(big.Rat).Quo(snapshot.rates[from], snapshot.rates[to])
+			tolerance := new(big.Rat).Mul(halfMinorUnit(to), ratio)
+			tolerance.Add(tolerance, halfMinorUnit(from))
+			diff := new(big.Rat).Sub(money.ToRat(back.GetMoney()), money.ToRat(in))
+			if diff.Abs(diff).Cmp(tolerance) > 0 {
+				t.Errorf("%s -> %s -> %s: %v 变成了 %v，误差超过 %s", from, to, from, in, back.GetMoney(), tolerance.FloatString(9))
+			}
+		}
+	}
+}
+
+func TestConvertUnsupportedCurrency(t *testing.T) {
+	table, err := NewRateTable(context.Background(), rates.NewJSONProvider("../data/currency_conversion.json"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &CurrencyService{Rates: table}
+	_, err = s.Convert(context.Background(), &pb.CurrencyConversionRequest{
+		From:   &pb.Money{CurrencyCode: "USD", Units: 1},
+		ToCode: "XXX",
+	})
+	if err == nil {
+		t.Fatal("转换成不支持的货币没有返回错误")
+	}
+}
+
+// 已知结果的转换，汇率为 EUR=1、USD=2、JPY=200，覆盖负数、最小单位上的银行家舍入和没有小数的货币
+func TestConvertKnownValues(t *testing.T) {
+	p := &fakeProvider{rates: map[string]*big.Rat{
+		"EUR": big.NewRat(1, 1),
+		"USD": big.NewRat(2, 1),
+		"JPY": big.NewRat(200, 1),
+	}}
+	table, err := NewRateTable(context.Background(), p, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &CurrencyService{Rates: table}
+	m := func(code string, units int64, nanos int32) *pb.Money {
+		return &pb.Money{CurrencyCode: code, Units: units, Nanos: nanos}
+	}
+	tests := []struct {
+		name string
+		from *pb.Money
+		to   string
+		want *pb.Money
+	}{
+		{"整数倍", m("EUR", 1, 230000000), "USD", m("USD", 2, 460000000)},
+		{"相同货币", m("USD", 3, 140000000), "USD", m("USD", 3, 140000000)},
+		// 0.005、0.015、0.025欧元舍入到偶数的分
+		{"舍入到0", m("USD", 0, 10000000), "EUR", m("EUR", 0, 0)},
+		{"向上舍入到偶数", m("USD", 0, 30000000), "EUR", m("EUR", 0, 20000000)},
+		{"向下舍入到偶数", m("USD", 0, 50000000), "EUR", m("EUR", 0, 20000000)},
+		{"不是一半时四舍五入", m("USD", 0, 70000001), "EUR", m("EUR", 0, 40000000)},
+		{"负数向上舍入到偶数", m("USD", 0, -30000000), "EUR", m("EUR", 0, -20000000)},
+		{"负数向下舍入到偶数", m("USD", -1, -50000000), "EUR", m("EUR", 0, -520000000)},
+		{"负数舍入到0", m("USD", 0, -10000000), "EUR", m("EUR", 0, 0)},
+		// 日元没有小数，舍入到元
+		{"转换成日元", m("EUR", 1, 230000000), "JPY", m("JPY", 246, 0)},
+		{"日元向下舍入到偶数", m("USD", 0, 25000000), "JPY", m("JPY", 2, 0)},
+		{"日元向上舍入到偶数", m("USD", 0, 35000000), "JPY", m("JPY", 4, 0)},
+		{"负数日元", m("USD", -1, -25000000), "JPY", m("JPY", -102, 0)},
+		{"日元转换成欧元", m("JPY", 3, 0), "EUR", m("EUR", 0, 20000000)},
+		{"日元转换成美元", m("JPY", -1, 0), "USD", m("USD", 0, -10000000)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := s.Convert(context.Background(), &pb.CurrencyConversionRequest{From: tt.from, ToCode: tt.to})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := out.GetMoney()
+			if got.GetCurrencyCode() != tt.want.GetCurrencyCode() || got.GetUnits() != tt.want.GetUnits() || got.GetNanos() != tt.want.GetNanos() {
+				t.Fatalf("%v 转换成 %s 为 %v，期望 %v", tt.from, tt.to, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertBatch(t *testing.T) {
+	p := &fakeProvider{rates: map[string]*big.Rat{
+		"EUR": big.NewRat(1, 1),
+		"USD": big.NewRat(2, 1),
+		"JPY": big.NewRat(200, 1),
+	}}
+	table, err := NewRateTable(context.Background(), p, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &CurrencyService{Rates: table}
+	version := table.snapshot().version
+
+	// 不同货币的金额转换成同一种货币，结果和请求一一对应，和逐个转换的结果相同
+	from := []*pb.Money{
+		{CurrencyCode: "EUR", Units: 1, Nanos: 230000000},
+		{CurrencyCode: "JPY", Units: 300},
+		{CurrencyCode: "USD", Units: -1, Nanos: -50000000},
+		{CurrencyCode: "USD", Units: 0, Nanos: 30000000},
+	}
+	out, err := s.ConvertBatch(context.Background(), &pb.ConvertBatchRequest{From: from, ToCode: "EUR"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out.GetRateVersion() != version || len(out.GetMoney()) != len(from) {
+		t.Fatalf("返回%d个结果，汇率版本 %s，期望%d个，版本 %s", len(out.GetMoney()), out.GetRateVersion(), len(from), version)
+	}
+	want := []struct {
+		units int64
+		nanos int32
+	}{{1, 230000000}, {1, 500000000}, {0, -520000000}, {0, 20000000}}
+	for i, m := range out.GetMoney() {
+		if m.GetCurrencyCode() != "EUR" || m.GetUnits() != want[i].units || m.GetNanos() != want[i].nanos {
+			t.Errorf("第%d个金额 %v 转换为 %v，期望 EUR %d.%09d", i, from[i], m, want[i].units, want[i].nanos)
+		}
+		one, err := s.Convert(context.Background(), &pb.CurrencyConversionRequest{From: from[i], ToCode: "EUR"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if one.GetMoney().GetUnits() != m.GetUnits() || one.GetMoney().GetNanos() != m.GetNanos() {
+			t.Errorf("第%d个金额批量转换为 %v，单独转换为 %v", i, m, one.GetMoney())
+		}
+	}
+
+	// 没有金额时返回空结果
+	out, err = s.ConvertBatch(context.Background(), &pb.ConvertBatchRequest{ToCode: "USD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.GetMoney()) != 0 || out.GetRateVersion() != version {
+		t.Fatalf("空请求返回 %v", out)
+	}
+}
+
+// 有一个金额转换失败时整个请求失败，不返回部分结果
+func TestConvertBatchFailsWhole(t *testing.T) {
+	table, err := NewRateTable(context.Background(), &fakeProvider{rates: map[string]*big.Rat{
+		"EUR": big.NewRat(1, 1),
+		"USD": big.NewRat(2, 1),
+	}}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &CurrencyService{Rates: table}
+	tests := []struct {
+		name   string
+		from   []*pb.Money
+		toCode string
+	}{
+		{"中间有不支持的货币", []*pb.Money{{CurrencyCode: "USD", Units: 1}, {CurrencyCode: "XXX", Units: 1}, {CurrencyCode: "EUR", Units: 1}}, "EUR"},
+		{"最后一个金额无效", []*pb.Money{{CurrencyCode: "USD", Units: 1}, {CurrencyCode: "USD", Units: 1, Nanos: -1}}, "EUR"},
+		{"目标货币不支持", []*pb.Money{{CurrencyCode: "USD", Units: 1}}, "XXX"},
+		{"空请求目标货币不支持", nil, "XXX"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := s.ConvertBatch(context.Background(), &pb.ConvertBatchRequest{From: tt.from, ToCode: tt.toCode})
+			if status.Code(err) != codes.InvalidArgument || out != nil {
+				t.Fatalf("返回 %v, %v，期望 %v", out, err, codes.InvalidArgument)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+)
+
+// 历史汇率文件中的一行，每行为一个版本
+type rateHistoryEntry struct {
+	Version  string            `json:"version"`
+	LoadedAt int64             `json:"loaded_at"`
+	Rates    map[string]string `json:"rates"`
+}
+
+// 从文件中加载历史汇率，文件不存在时返回空
+func loadRateHistory(path string) ([]*rateSnapshot, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var history []*rateSnapshot
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var e rateHistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("第%d行: %v", line, err)
+		}
+		rates := make(map[string]*big.Rat, len(e.Rates))
+		for code, v := range e.Rates {
+			r, ok := new(big.Rat).SetString(v)
+			if !ok || r.Sign() <= 0 {
+				return nil, fmt.Errorf("第%d行: %s的汇率无效: %s", line, code, v)
+			}
+			rates[code] = r
+		}
+		s, err := newRateSnapshot(rates, time.Unix(e.LoadedAt, 0))
+		if err != nil {
+			return nil, fmt.Errorf("第%d行: %v", line, err)
+		}
+		if n := len(history); n > 0 && s.loadedAt.Before(history[n-1].loadedAt) {
+			return nil, fmt.Errorf("第%d行: 生效时间早于上一个版本", line)
+		}
+		history = append(history, s)
+	}
+	return history, scanner.Err()
+}
+
+// 把一个版本追加到历史汇率文件
+func appendRateHistory(f *os.File, s *rateSnapshot) error {
+	e := rateHistoryEntry{
+		Version:  s.version,
+		LoadedAt: s.loadedAt.Unix(),
+		Rates:    make(map[string]string, len(s.rates)),
+	}
+	for code, r := range s.rates {
+		e.Rates[code] = r.RatString()
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
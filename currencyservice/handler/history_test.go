@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "currencyservice/proto"
+)
+
+// 写入历史汇率文件，每行一个版本
+func writeHistory(t *testing.T, lines ...string) string {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadRateHistory(t *testing.T) {
+	path := writeHistory(t,
+		`{"version":"a","loaded_at":1000,"rates":{"EUR":"1","USD":"1"}}`,
+		`{"version":"b","loaded_at":2000,"rates":{"EUR":"1","USD":"2"}}`,
+		`{"version":"c","loaded_at":2000,"rates":{"EUR":"1","USD":"4"}}`,
+	)
+	history, err := loadRateHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 3 {
+		t.Fatalf("加载了%d个版本，期望3个", len(history))
+	}
+	for i, want := range []struct {
+		loadedAt int64
+		usd      int64
+	}{{1000, 1}, {2000, 2}, {2000, 4}} {
+		s := history[i]
+		if s.loadedAt.Unix() != want.loadedAt || s.rates["USD"].Cmp(big.NewRat(want.usd, 1)) != 0 {
+			t.Errorf("第%d个版本生效时间为 %d，美元汇率为 %v", i, s.loadedAt.Unix(), s.rates["USD"])
+		}
+	}
+	// 版本按汇率重新计算，不使用文件中的版本
+	if history[0].version == "a" || history[0].version == history[1].version {
+		t.Errorf("版本为 %s, %s", history[0].version, history[1].version)
+	}
+
+	if history, err := loadRateHistory(filepath.Join(t.TempDir(), "missing.jsonl")); err != nil || history != nil {
+		t.Fatalf("文件不存在时返回 %v, %v", history, err)
+	}
+}
+
+func TestLoadRateHistoryInvalid(t *testing.T) {
+	tests := map[string]string{
+		"不是json":    `{"loaded_at":`,
+		"汇率无效":      `{"loaded_at":1000,"rates":{"USD":"abc"}}`,
+		"汇率为0":      `{"loaded_at":1000,"rates":{"USD":"0"}}`,
+		"没有汇率":      `{"loaded_at":1000,"rates":{}}`,
+		"生效时间早于上一个": `{"loaded_at":1000,"rates":{"USD":"1"}}` + "\n" + `{"loaded_at":999,"rates":{"USD":"2"}}`,
+	}
+	for name, content := range tests {
+		if _, err := loadRateHistory(writeHistory(t, content)); err == nil {
+			t.Errorf("%s: 没有返回错误", name)
+		}
+	}
+}
+
+// 重新加载的版本追加到历史汇率文件，重启后恢复所有版本，汇率没有变化时不追加
+func TestAppendRateHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.jsonl")
+	p := &fakeProvider{rates: map[string]*big.Rat{"EUR": big.NewRat(1, 1), "USD": big.NewRat(11, 10)}}
+	table, err := NewRateTable(context.Background(), p, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.set(map[string]*big.Rat{"EUR": big.NewRat(1, 1), "USD": big.NewRat(12, 10)}, nil)
+	if err := table.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	want := *table.history.Load()
+
+	restarted, err := NewRateTable(context.Background(), p, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := *restarted.history.Load()
+	if len(got) != len(want) {
+		t.Fatalf("重启后有%d个版本，期望%d个", len(got), len(want))
+	}
+	for i := range want {
+		if got[i].version != want[i].version || !got[i].loadedAt.Equal(want[i].loadedAt) {
+			t.Errorf("第%d个版本为 %s %v，期望 %s %v", i, got[i].version, got[i].loadedAt, want[i].version, want[i].loadedAt)
+		}
+	}
+	if history, err := loadRateHistory(path); err != nil || len(history) != 2 {
+		t.Fatalf("历史汇率文件中有%d个版本，期望2个: %v", len(history), err)
+	}
+}
+
+// 按as_of查询最早版本之前、两个版本之间、版本生效时和最后一个版本之后的汇率
+func TestConvertAsOf(t *testing.T) {
+	path := writeHistory(t,
+		`{"loaded_at":1000,"rates":{"EUR":"1","USD":"1"}}`,
+		`{"loaded_at":2000,"rates":{"EUR":"1","USD":"2"}}`,
+		// 同一秒内的两个版本，使用后加载的
+		`{"loaded_at":3000,"rates":{"EUR":"1","USD":"3"}}`,
+		`{"loaded_at":3000,"rates":{"EUR":"1","USD":"4"}}`,
+	)
+	// 当前汇率在现在生效
+	p := &fakeProvider{rates: map[string]*big.Rat{"EUR": big.NewRat(1, 1), "USD": big.NewRat(5, 1)}}
+	table, err := NewRateTable(context.Background(), p, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &CurrencyService{Rates: table}
+	tests := []struct {
+		name string
+		asOf int64
+		want int64
+	}{
+		{"当前汇率", 0, 5},
+		{"第一个版本生效时", 1000, 1},
+		{"两个版本之间", 1999, 1},
+		{"第二个版本生效时", 2000, 2},
+		{"同一秒内的版本", 3000, 4},
+		{"历史版本之后", 3001, 4},
+		{"现在", time.Now().Unix(), 5},
+		{"将来", time.Now().Add(time.Hour).Unix(), 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := s.Convert(context.Background(), &pb.CurrencyConversionRequest{
+				From:   &pb.Money{CurrencyCode: "EUR", Units: 1},
+				ToCode: "USD",
+				AsOf:   tt.asOf,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := out.GetMoney(); got.GetUnits() != tt.want || got.GetNanos() != 0 {
+				t.Fatalf("as_of %d 转换结果为 %v，期望 %d美元", tt.asOf, got, tt.want)
+			}
+		})
+	}
+
+	_, err = s.Convert(context.Background(), &pb.CurrencyConversionRequest{
+		From:   &pb.Money{CurrencyCode: "EUR", Units: 1},
+		ToCode: "USD",
+		AsOf:   999,
+	})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("最早版本之前的as_of返回 %v，期望 %v", err, codes.NotFound)
+	}
+}
+
+// 同一秒内多次重新加载时，生效时间不晚于加载时间，当前汇率为最后加载的
+func TestReloadLoadedAtNotInFuture(t *testing.T) {
+	p := &fakeProvider{rates: map[string]*big.Rat{"EUR": big.NewRat(1, 1), "USD": big.NewRat(1, 1)}}
+	table, err := NewRateTable(context.Background(), p, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &CurrencyService{Rates: table}
+	for i := int64(2); i <= 5; i++ {
+		p.set(map[string]*big.Rat{"EUR": big.NewRat(1, 1), "USD": big.NewRat(i, 1)}, nil)
+		if err := table.Reload(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now()
+	history := *table.history.Load()
+	for i, snapshot := range history {
+		if snapshot.loadedAt.After(now) {
+			t.Fatalf("第%d个版本的生效时间 %v 晚于现在 %v", i, snapshot.loadedAt, now)
+		}
+	}
+	v, err := s.GetRatesVersion(context.Background(), &pb.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := history[len(history)-1]; v.GetVersion() != last.version || v.GetLoadedAt() > now.Unix() {
+		t.Fatalf("当前版本为 %s，生效时间 %d，期望 %s，不晚于 %d", v.GetVersion(), v.GetLoadedAt(), last.version, now.Unix())
+	}
+	if got := table.snapshotAt(now); got != history[len(history)-1] {
+		t.Fatalf("现在生效的版本为 %s，期望最后加载的 %s", got.version, history[len(history)-1].version)
+	}
+}
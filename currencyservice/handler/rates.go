@@ -0,0 +1,201 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"currencyservice/rates"
+)
+
+// 文件变化后等待多久再重新加载，编辑器保存文件时会连续产生多个事件
+const reloadDelay = 200 * time.Millisecond
+
+// 从汇率来源加载的超时时间
+const reloadTimeout = 30 * time.Second
+
+// 汇率快照，创建后不再修改，可以在多个请求间共享
+type rateSnapshot struct {
+	// 汇率，都是相对于同一种货币
+	rates map[string]*big.Rat
+	// 排好序的货币代码
+	codes []string
+	// 版本，为汇率数据的sha256，内容相同的汇率版本相同
+	version string
+	// 加载时间，从这个时间开始生效，精确到秒，不早于上一个版本
+	loadedAt time.Time
+}
+
+// 汇率表，从汇率来源重新加载后原子替换快照，正在处理的请求继续使用旧的快照。
+// 保留每个版本的汇率，可以查询过去某个时间使用的汇率
+type RateTable struct {
+	provider rates.RateProvider
+	// 按生效时间排序的所有版本，最后一个为当前汇率，替换时创建新的切片
+	history atomic.Pointer[[]*rateSnapshot]
+	// 保存历史汇率的文件，为nil表示只保存在内存中
+	historyFile *os.File
+	// 串行执行重新加载，监听文件和定时刷新可能同时触发
+	reloadMu sync.Mutex
+}
+
+// 创建汇率表，historyPath不为空时从文件中恢复历史汇率，并把新的版本追加到文件中，然后立即加载一次
+func NewRateTable(ctx context.Context, provider rates.RateProvider, historyPath string) (*RateTable, error) {
+	t := &RateTable{provider: provider}
+	var history []*rateSnapshot
+	if historyPath != "" {
+		var err error
+		if history, err = loadRateHistory(historyPath); err != nil {
+			return nil, fmt.Errorf("加载历史汇率失败: %v", err)
+		}
+		if t.historyFile, err = os.OpenFile(historyPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644); err != nil {
+			return nil, err
+		}
+	}
+	t.history.Store(&history)
+	if err := t.Reload(ctx); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// 当前的汇率快照
+func (t *RateTable) snapshot() *rateSnapshot {
+	history := *t.history.Load()
+	return history[len(history)-1]
+}
+
+// 在asOf时间生效的汇率快照，生效时间相同时使用后加载的版本，asOf早于最早的汇率时返回nil
+func (t *RateTable) snapshotAt(asOf time.Time) *rateSnapshot {
+	history := *t.history.Load()
+	i := sort.Search(len(history), func(i int) bool { return history[i].loadedAt.After(asOf) })
+	if i == 0 {
+		return nil
+	}
+	return history[i-1]
+}
+
+// 从汇率来源重新加载，加载失败时保留原来的快照，汇率没有变化时不替换快照
+func (t *RateTable) Reload(ctx context.Context) error {
+	t.reloadMu.Lock()
+	defer t.reloadMu.Unlock()
+	r, err := t.provider.Rates(ctx)
+	if err != nil {
+		return err
+	}
+	s, err := newRateSnapshot(r, time.Now())
+	if err != nil {
+		return err
+	}
+	history := *t.history.Load()
+	if n := len(history); n > 0 {
+		if history[n-1].version == s.version {
+			return nil
+		}
+		// 生效时间不晚于加载时间。同一秒内多次更新时生效时间相同，按时间查询时使用后加载的版本；
+		// 时钟回拨时使用上一个版本的生效时间，保证生效时间不递减
+		if last := history[n-1].loadedAt; s.loadedAt.Before(last) {
+			s.loadedAt = last
+		}
+	}
+	if t.historyFile != nil {
+		if err := appendRateHistory(t.historyFile, s); err != nil {
+			return fmt.Errorf("保存历史汇率失败: %v", err)
+		}
+	}
+	updated := make([]*rateSnapshot, len(history), len(history)+1)
+	copy(updated, history)
+	updated = append(updated, s)
+	t.history.Store(&updated)
+	log.Printf("汇率已更新，版本 %s", s.version)
+	return nil
+}
+
+// 重新加载，失败时记录日志并继续使用原来的汇率
+func (t *RateTable) reloadOrKeep() {
+	ctx, cancel := context.WithTimeout(context.Background(), reloadTimeout)
+	defer cancel()
+	if err := t.Reload(ctx); err != nil {
+		log.Printf("重新加载汇率失败，继续使用版本 %s: %v", t.snapshot().version, err)
+	}
+}
+
+// 定时从汇率来源刷新
+func (t *RateTable) RefreshEvery(interval time.Duration) {
+	go func() {
+		for range time.Tick(interval) {
+			t.reloadOrKeep()
+		}
+	}()
+}
+
+// 监听汇率文件，文件变化时重新加载。监听的是文件所在的目录，文件被替换（如编辑器保存、mv）后仍然有效
+func (t *RateTable) Watch(path string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return err
+	}
+	name := filepath.Clean(path)
+
+	go func() {
+		defer watcher.Close()
+		var timer <-chan time.Time
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == name && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					timer = time.After(reloadDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("监听汇率文件出错: %v", err)
+			case <-timer:
+				timer = nil
+				t.reloadOrKeep()
+			}
+		}
+	}()
+	return nil
+}
+
+// 创建汇率快照，版本按排好序的货币代码和汇率计算
+func newRateSnapshot(r map[string]*big.Rat, loadedAt time.Time) (*rateSnapshot, error) {
+	if len(r) == 0 {
+		return nil, errors.New("没有汇率")
+	}
+	s := &rateSnapshot{
+		rates:    r,
+		codes:    make([]string, 0, len(r)),
+		loadedAt: time.Unix(loadedAt.Unix(), 0),
+	}
+	for code := range r {
+		s.codes = append(s.codes, code)
+	}
+	sort.Strings(s.codes)
+	h := sha256.New()
+	for _, code := range s.codes {
+		h.Write([]byte(code + "=" + r[code].RatString() + "\n"))
+	}
+	s.version = hex.EncodeToString(h.Sum(nil)[:8])
+	return s, nil
+}
@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	pb "currencyservice/proto"
+	"currencyservice/rates"
+)
+
+// 测试用的汇率来源，返回设置的汇率或错误
+type fakeProvider struct {
+	mu    sync.Mutex
+	rates map[string]*big.Rat
+	err   error
+}
+
+func (p *fakeProvider) Rates(ctx context.Context) (map[string]*big.Rat, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.rates, p.err
+}
+
+func (p *fakeProvider) set(rates map[string]*big.Rat, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.rates, p.err = rates, err
+}
+
+func TestReloadFailureKeepsSnapshot(t *testing.T) {
+	p := &fakeProvider{rates: map[string]*big.Rat{"EUR": big.NewRat(1, 1), "USD": big.NewRat(11305, 10000)}}
+	table, err := NewRateTable(context.Background(), p, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := table.snapshot()
+
+	// 加载失败和汇率为空时都继续使用原来的快照
+	p.set(nil, errors.New("下载失败"))
+	if err := table.Reload(context.Background()); err == nil {
+		t.Fatal("汇率来源失败时Reload没有返回错误")
+	}
+	table.reloadOrKeep()
+	p.set(map[string]*big.Rat{}, nil)
+	if err := table.Reload(context.Background()); err == nil {
+		t.Fatal("没有汇率时Reload没有返回错误")
+	}
+	if s := table.snapshot(); s != good {
+		t.Fatalf("加载失败后汇率版本为 %s，期望 %s", s.version, good.version)
+	}
+	if n := len(*table.history.Load()); n != 1 {
+		t.Fatalf("加载失败后有%d个汇率版本，期望1个", n)
+	}
+
+	// 恢复后使用新的汇率
+	p.set(map[string]*big.Rat{"EUR": big.NewRat(1, 1), "USD": big.NewRat(11, 10)}, nil)
+	if err := table.Reload(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if s := table.snapshot(); s == good || s.rates["USD"].Cmp(big.NewRat(11, 10)) != 0 {
+		t.Fatalf("恢复后的汇率为 %v", s.rates)
+	}
+}
+
+// 写入汇率文件，先写临时文件再重命名，和编辑器保存文件的方式相同
+func writeRates(t *testing.T, path, content string) {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// 当前的汇率版本
+func ratesVersion(t *testing.T, s *CurrencyService) *pb.RatesVersion {
+	v, err := s.GetRatesVersion(context.Background(), &pb.Empty{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+// 等待汇率版本变化，返回新的版本
+func waitForNewVersion(t *testing.T, s *CurrencyService, old string) *pb.RatesVersion {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if v := ratesVersion(t, s); v.GetVersion() != old {
+			return v
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("汇率文件被修改后没有重新加载")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// 1欧元换算成美元的金额
+func usdPerEUR(t *testing.T, s *CurrencyService) int64 {
+	out, err := s.Convert(context.Background(), &pb.CurrencyConversionRequest{
+		From:   &pb.Money{CurrencyCode: "EUR", Units: 1},
+		ToCode: "USD",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out.GetMoney().GetUnits()
+}
+
+// 修改汇率文件后重新加载，版本变化并使用新的汇率；文件无效时继续使用原来的汇率
+func TestWatchRatesFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rates.json")
+	writeRates(t, path, `{"EUR": 1, "USD": 2}`)
+	table, err := NewRateTable(context.Background(), rates.NewJSONProvider(path), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Watch(path); err != nil {
+		t.Fatal(err)
+	}
+	s := &CurrencyService{Rates: table}
+	first := ratesVersion(t, s)
+	if now := time.Now().Unix(); first.GetVersion() == "" || first.GetLoadedAt() > now || first.GetLoadedAt() < now-5 {
+		t.Fatalf("加载后的汇率版本为 %v", first)
+	}
+	if got := usdPerEUR(t, s); got != 2 {
+		t.Fatalf("1欧元换算为%d美元，期望2", got)
+	}
+
+	writeRates(t, path, `{"EUR": 1, "USD": 3}`)
+	second := waitForNewVersion(t, s, first.GetVersion())
+	if second.GetLoadedAt() < first.GetLoadedAt() {
+		t.Fatalf("新版本的生效时间 %d 早于原来的 %d", second.GetLoadedAt(), first.GetLoadedAt())
+	}
+	if got := usdPerEUR(t, s); got != 3 {
+		t.Fatalf("重新加载后1欧元换算为%d美元，期望3", got)
+	}
+
+	// 文件无效、没有汇率和内容不变时都不产生新的版本
+	for _, content := range []string{`{"EUR": 1, "USD":`, `{}`, `{"EUR": 1, "USD": -3}`, `{"USD": 3, "EUR": 1}`} {
+		writeRates(t, path, content)
+		time.Sleep(5 * reloadDelay)
+		if v := ratesVersion(t, s); v.GetVersion() != second.GetVersion() {
+			t.Fatalf("写入 %s 后汇率版本为 %s，期望 %s", content, v.GetVersion(), second.GetVersion())
+		}
+		if got := usdPerEUR(t, s); got != 3 {
+			t.Fatalf("写入 %s 后1欧元换算为%d美元，期望3", content, got)
+		}
+	}
+	if n := len(*table.history.Load()); n != 2 {
+		t.Fatalf("有%d个汇率版本，期望2个", n)
+	}
+
+	// 加载失败后仍然监听文件，直接写文件也能触发重新加载
+	if err := os.WriteFile(path, []byte(`{"EUR": 1, "USD": 4}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitForNewVersion(t, s, second.GetVersion())
+	if got := usdPerEUR(t, s); got != 4 {
+		t.Fatalf("重新加载后1欧元换算为%d美元，期望4", got)
+	}
+}
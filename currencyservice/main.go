@@ -1,11 +1,15 @@
 package main
 
 import (
+	"context"
 	handler "currencyservice/handler"
 	pb "currencyservice/proto"
+	"currencyservice/rates"
+	"flag"
 	"fmt"
 	"net"
 	"strconv"
+	"time"
 
 	"github.com/hashicorp/consul/api"
 	"google.golang.org/grpc"
@@ -14,7 +18,35 @@ import (
 const PORT = 50012
 const ADDRESS = "127.0.0.1"
 
+// 汇率来源：json、ecb（欧洲央行eurofxref xml）或 csv 文件，文件修改后自动重新加载，也会定时刷新
+var (
+	ratesProvider = flag.String("rates-provider", "json", "汇率来源: json、ecb 或 csv")
+	ratesPath     = flag.String("rates", "data/currency_conversion.json", "汇率文件路径")
+	ratesRefresh  = flag.Duration("rates-refresh", time.Hour, "定时刷新汇率的间隔，0表示不定时刷新")
+	ratesHistory  = flag.String("rates-history", "rates_history.jsonl", "保存历史汇率的文件路径，为空表示只保存在内存中")
+)
+
 func main() {
+	flag.Parse()
+
+	provider, err_provider := rates.NewProvider(*ratesProvider, *ratesPath)
+	if err_provider != nil {
+		fmt.Println("汇率来源报错：", err_provider)
+		return
+	}
+	rateTable, err_rates := handler.NewRateTable(context.Background(), provider, *ratesHistory)
+	if err_rates != nil {
+		fmt.Println("加载汇率报错：", err_rates)
+		return
+	}
+	if err_watch := rateTable.Watch(*ratesPath); err_watch != nil {
+		fmt.Println("监听汇率文件报错：", err_watch)
+		return
+	}
+	if *ratesRefresh > 0 {
+		rateTable.RefreshEvery(*ratesRefresh)
+	}
+
 	ipport := ADDRESS + ":" + strconv.Itoa(PORT)
 	// -------------注册到consul上---------------
 	// 初始化consul配置
@@ -47,7 +79,7 @@ func main() {
 	grpcServer := grpc.NewServer()
 
 	// 注册服务
-	pb.RegisterCurrencyServiceServer(grpcServer, new(handler.CurrencyService))
+	pb.RegisterCurrencyServiceServer(grpcServer, &handler.CurrencyService{Rates: rateTable})
 
 	// 设置监听
 	listien, err := net.Listen("tcp", ipport)
@@ -188,6 +188,8 @@ type CurrencyConversionRequest struct {
 
 	From   *Money `protobuf:"bytes,1,opt,name=from,proto3" json:"from,omitempty"`
 	ToCode string `protobuf:"bytes,2,opt,name=to_code,json=toCode,proto3" json:"to_code,omitempty"`
+	// 使用该时间的汇率，unix时间戳（秒），0表示使用当前汇率
+	AsOf int64 `protobuf:"varint,3,opt,name=as_of,json=asOf,proto3" json:"as_of,omitempty"`
 }
 
 func (x *CurrencyConversionRequest) Reset() {
@@ -236,6 +238,251 @@ func (x *CurrencyConversionRequest) GetToCode() string {
 	return ""
 }
 
+func (x *CurrencyConversionRequest) GetAsOf() int64 {
+	if x != nil {
+		return x.AsOf
+	}
+	return 0
+}
+
+// 货币转换响应消息
+type CurrencyConversionResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Money *Money `protobuf:"bytes,1,opt,name=money,proto3" json:"money,omitempty"`
+	// 转换使用的汇率版本
+	RateVersion string `protobuf:"bytes,2,opt,name=rate_version,json=rateVersion,proto3" json:"rate_version,omitempty"`
+}
+
+func (x *CurrencyConversionResponse) Reset() {
+	*x = CurrencyConversionResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_currencyservice_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *CurrencyConversionResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CurrencyConversionResponse) ProtoMessage() {}
+
+func (x *CurrencyConversionResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_currencyservice_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CurrencyConversionResponse.ProtoReflect.Descriptor instead.
+func (*CurrencyConversionResponse) Descriptor() ([]byte, []int) {
+	return file_proto_currencyservice_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *CurrencyConversionResponse) GetMoney() *Money {
+	if x != nil {
+		return x.Money
+	}
+	return nil
+}
+
+func (x *CurrencyConversionResponse) GetRateVersion() string {
+	if x != nil {
+		return x.RateVersion
+	}
+	return ""
+}
+
+// 批量货币转换请求消息
+type ConvertBatchRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	From   []*Money `protobuf:"bytes,1,rep,name=from,proto3" json:"from,omitempty"`
+	ToCode string   `protobuf:"bytes,2,opt,name=to_code,json=toCode,proto3" json:"to_code,omitempty"`
+	// 使用该时间的汇率，unix时间戳（秒），0表示使用当前汇率
+	AsOf int64 `protobuf:"varint,3,opt,name=as_of,json=asOf,proto3" json:"as_of,omitempty"`
+}
+
+func (x *ConvertBatchRequest) Reset() {
+	*x = ConvertBatchRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_currencyservice_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConvertBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConvertBatchRequest) ProtoMessage() {}
+
+func (x *ConvertBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_currencyservice_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConvertBatchRequest.ProtoReflect.Descriptor instead.
+func (*ConvertBatchRequest) Descriptor() ([]byte, []int) {
+	return file_proto_currencyservice_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ConvertBatchRequest) GetFrom() []*Money {
+	if x != nil {
+		return x.From
+	}
+	return nil
+}
+
+func (x *ConvertBatchRequest) GetToCode() string {
+	if x != nil {
+		return x.ToCode
+	}
+	return ""
+}
+
+func (x *ConvertBatchRequest) GetAsOf() int64 {
+	if x != nil {
+		return x.AsOf
+	}
+	return 0
+}
+
+// 批量货币转换响应消息
+type ConvertBatchResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 转换结果，和请求中的from一一对应
+	Money []*Money `protobuf:"bytes,1,rep,name=money,proto3" json:"money,omitempty"`
+	// 转换使用的汇率版本
+	RateVersion string `protobuf:"bytes,2,opt,name=rate_version,json=rateVersion,proto3" json:"rate_version,omitempty"`
+}
+
+func (x *ConvertBatchResponse) Reset() {
+	*x = ConvertBatchResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_currencyservice_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConvertBatchResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConvertBatchResponse) ProtoMessage() {}
+
+func (x *ConvertBatchResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_currencyservice_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConvertBatchResponse.ProtoReflect.Descriptor instead.
+func (*ConvertBatchResponse) Descriptor() ([]byte, []int) {
+	return file_proto_currencyservice_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *ConvertBatchResponse) GetMoney() []*Money {
+	if x != nil {
+		return x.Money
+	}
+	return nil
+}
+
+func (x *ConvertBatchResponse) GetRateVersion() string {
+	if x != nil {
+		return x.RateVersion
+	}
+	return ""
+}
+
+// 当前使用的汇率版本
+type RatesVersion struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// 汇率数据的版本，内容相同的汇率数据版本相同
+	Version string `protobuf:"bytes,1,opt,name=version,proto3" json:"version,omitempty"`
+	// 加载时间，unix秒
+	LoadedAt int64 `protobuf:"varint,2,opt,name=loaded_at,json=loadedAt,proto3" json:"loaded_at,omitempty"`
+}
+
+func (x *RatesVersion) Reset() {
+	*x = RatesVersion{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_currencyservice_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *RatesVersion) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RatesVersion) ProtoMessage() {}
+
+func (x *RatesVersion) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_currencyservice_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RatesVersion.ProtoReflect.Descriptor instead.
+func (*RatesVersion) Descriptor() ([]byte, []int) {
+	return file_proto_currencyservice_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *RatesVersion) GetVersion() string {
+	if x != nil {
+		return x.Version
+	}
+	return ""
+}
+
+func (x *RatesVersion) GetLoadedAt() int64 {
+	if x != nil {
+		return x.LoadedAt
+	}
+	return 0
+}
+
 var File_proto_currencyservice_proto protoreflect.FileDescriptor
 
 var file_proto_currencyservice_proto_rawDesc = []byte{
@@ -252,27 +499,65 @@ var file_proto_currencyservice_proto_rawDesc = []byte{
 	0x75, 0x72, 0x72, 0x65, 0x6e, 0x63, 0x69, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
 	0x65, 0x12, 0x25, 0x0a, 0x0e, 0x63, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63, 0x79, 0x5f, 0x63, 0x6f,
 	0x64, 0x65, 0x73, 0x18, 0x01, 0x20, 0x03, 0x28, 0x09, 0x52, 0x0d, 0x63, 0x75, 0x72, 0x72, 0x65,
-	0x6e, 0x63, 0x79, 0x43, 0x6f, 0x64, 0x65, 0x73, 0x22, 0x5e, 0x0a, 0x19, 0x43, 0x75, 0x72, 0x72,
+	0x6e, 0x63, 0x79, 0x43, 0x6f, 0x64, 0x65, 0x73, 0x22, 0x73, 0x0a, 0x19, 0x43, 0x75, 0x72, 0x72,
 	0x65, 0x6e, 0x63, 0x79, 0x43, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65,
 	0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x28, 0x0a, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x18, 0x01, 0x20,
 	0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x73, 0x68, 0x6f, 0x70, 0x70,
 	0x69, 0x6e, 0x67, 0x2e, 0x4d, 0x6f, 0x6e, 0x65, 0x79, 0x52, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x12,
 	0x17, 0x0a, 0x07, 0x74, 0x6f, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
-	0x52, 0x06, 0x74, 0x6f, 0x43, 0x6f, 0x64, 0x65, 0x32, 0xbf, 0x01, 0x0a, 0x0f, 0x43, 0x75, 0x72,
-	0x72, 0x65, 0x6e, 0x63, 0x79, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x5f, 0x0a, 0x16,
-	0x47, 0x65, 0x74, 0x53, 0x75, 0x70, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x64, 0x43, 0x75, 0x72, 0x72,
-	0x65, 0x6e, 0x63, 0x69, 0x65, 0x73, 0x12, 0x14, 0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x73, 0x68,
-	0x6f, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x2d, 0x2e, 0x6d,
-	0x69, 0x63, 0x72, 0x6f, 0x73, 0x68, 0x6f, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x2e, 0x47, 0x65, 0x74,
-	0x53, 0x75, 0x70, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x64, 0x43, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63,
-	0x69, 0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x4b, 0x0a,
-	0x07, 0x43, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x74, 0x12, 0x28, 0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f,
-	0x73, 0x68, 0x6f, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x2e, 0x43, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63,
-	0x79, 0x43, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65,
-	0x73, 0x74, 0x1a, 0x14, 0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x73, 0x68, 0x6f, 0x70, 0x70, 0x69,
-	0x6e, 0x67, 0x2e, 0x4d, 0x6f, 0x6e, 0x65, 0x79, 0x22, 0x00, 0x42, 0x17, 0x5a, 0x15, 0x2e, 0x2f,
-	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x3b, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x73, 0x68, 0x6f, 0x70, 0x70,
-	0x69, 0x6e, 0x67, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+	0x52, 0x06, 0x74, 0x6f, 0x43, 0x6f, 0x64, 0x65, 0x12, 0x13, 0x0a, 0x05, 0x61, 0x73, 0x5f, 0x6f,
+	0x66, 0x18, 0x03, 0x20, 0x01, 0x28, 0x03, 0x52, 0x04, 0x61, 0x73, 0x4f, 0x66, 0x22, 0x6b, 0x0a,
+	0x1a, 0x43, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63, 0x79, 0x43, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73,
+	0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12, 0x2a, 0x0a, 0x05, 0x6d,
+	0x6f, 0x6e, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x6d, 0x69, 0x63,
+	0x72, 0x6f, 0x73, 0x68, 0x6f, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x2e, 0x4d, 0x6f, 0x6e, 0x65, 0x79,
+	0x52, 0x05, 0x6d, 0x6f, 0x6e, 0x65, 0x79, 0x12, 0x21, 0x0a, 0x0c, 0x72, 0x61, 0x74, 0x65, 0x5f,
+	0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x72,
+	0x61, 0x74, 0x65, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22, 0x6d, 0x0a, 0x13, 0x43, 0x6f,
+	0x6e, 0x76, 0x65, 0x72, 0x74, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x12, 0x28, 0x0a, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b, 0x32,
+	0x14, 0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x73, 0x68, 0x6f, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x2e,
+	0x4d, 0x6f, 0x6e, 0x65, 0x79, 0x52, 0x04, 0x66, 0x72, 0x6f, 0x6d, 0x12, 0x17, 0x0a, 0x07, 0x74,
+	0x6f, 0x5f, 0x63, 0x6f, 0x64, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x74, 0x6f,
+	0x43, 0x6f, 0x64, 0x65, 0x12, 0x13, 0x0a, 0x05, 0x61, 0x73, 0x5f, 0x6f, 0x66, 0x18, 0x03, 0x20,
+	0x01, 0x28, 0x03, 0x52, 0x04, 0x61, 0x73, 0x4f, 0x66, 0x22, 0x65, 0x0a, 0x14, 0x43, 0x6f, 0x6e,
+	0x76, 0x65, 0x72, 0x74, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73,
+	0x65, 0x12, 0x2a, 0x0a, 0x05, 0x6d, 0x6f, 0x6e, 0x65, 0x79, 0x18, 0x01, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x14, 0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x73, 0x68, 0x6f, 0x70, 0x70, 0x69, 0x6e, 0x67,
+	0x2e, 0x4d, 0x6f, 0x6e, 0x65, 0x79, 0x52, 0x05, 0x6d, 0x6f, 0x6e, 0x65, 0x79, 0x12, 0x21, 0x0a,
+	0x0c, 0x72, 0x61, 0x74, 0x65, 0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x02, 0x20,
+	0x01, 0x28, 0x09, 0x52, 0x0b, 0x72, 0x61, 0x74, 0x65, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x22, 0x45, 0x0a, 0x0c, 0x52, 0x61, 0x74, 0x65, 0x73, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e,
+	0x12, 0x18, 0x0a, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x07, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x1b, 0x0a, 0x09, 0x6c, 0x6f,
+	0x61, 0x64, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x03, 0x52, 0x08, 0x6c,
+	0x6f, 0x61, 0x64, 0x65, 0x64, 0x41, 0x74, 0x32, 0xf7, 0x02, 0x0a, 0x0f, 0x43, 0x75, 0x72, 0x72,
+	0x65, 0x6e, 0x63, 0x79, 0x53, 0x65, 0x72, 0x76, 0x69, 0x63, 0x65, 0x12, 0x5f, 0x0a, 0x16, 0x47,
+	0x65, 0x74, 0x53, 0x75, 0x70, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x64, 0x43, 0x75, 0x72, 0x72, 0x65,
+	0x6e, 0x63, 0x69, 0x65, 0x73, 0x12, 0x14, 0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x73, 0x68, 0x6f,
+	0x70, 0x70, 0x69, 0x6e, 0x67, 0x2e, 0x45, 0x6d, 0x70, 0x74, 0x79, 0x1a, 0x2d, 0x2e, 0x6d, 0x69,
+	0x63, 0x72, 0x6f, 0x73, 0x68, 0x6f, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x2e, 0x47, 0x65, 0x74, 0x53,
+	0x75, 0x70, 0x70, 0x6f, 0x72, 0x74, 0x65, 0x64, 0x43, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63, 0x69,
+	0x65, 0x73, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x60, 0x0a, 0x07,
+	0x43, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x74, 0x12, 0x28, 0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x73,
+	0x68, 0x6f, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x2e, 0x43, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63, 0x79,
+	0x43, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73,
+	0x74, 0x1a, 0x29, 0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x73, 0x68, 0x6f, 0x70, 0x70, 0x69, 0x6e,
+	0x67, 0x2e, 0x43, 0x75, 0x72, 0x72, 0x65, 0x6e, 0x63, 0x79, 0x43, 0x6f, 0x6e, 0x76, 0x65, 0x72,
+	0x73, 0x69, 0x6f, 0x6e, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x59,
+	0x0a, 0x0c, 0x43, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x74, 0x42, 0x61, 0x74, 0x63, 0x68, 0x12, 0x22,
+	0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x73, 0x68, 0x6f, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x2e, 0x43,
+	0x6f, 0x6e, 0x76, 0x65, 0x72, 0x74, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52, 0x65, 0x71, 0x75, 0x65,
+	0x73, 0x74, 0x1a, 0x23, 0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x73, 0x68, 0x6f, 0x70, 0x70, 0x69,
+	0x6e, 0x67, 0x2e, 0x43, 0x6f, 0x6e, 0x76, 0x65, 0x72, 0x74, 0x42, 0x61, 0x74, 0x63, 0x68, 0x52,
+	0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x22, 0x00, 0x12, 0x46, 0x0a, 0x0f, 0x47, 0x65, 0x74,
+	0x52, 0x61, 0x74, 0x65, 0x73, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x14, 0x2e, 0x6d,
+	0x69, 0x63, 0x72, 0x6f, 0x73, 0x68, 0x6f, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x2e, 0x45, 0x6d, 0x70,
+	0x74, 0x79, 0x1a, 0x1b, 0x2e, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x73, 0x68, 0x6f, 0x70, 0x70, 0x69,
+	0x6e, 0x67, 0x2e, 0x52, 0x61, 0x74, 0x65, 0x73, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x22,
+	0x00, 0x42, 0x17, 0x5a, 0x15, 0x2e, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x3b, 0x6d, 0x69, 0x63,
+	0x72, 0x6f, 0x73, 0x68, 0x6f, 0x70, 0x70, 0x69, 0x6e, 0x67, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74,
+	0x6f, 0x33,
 }
 
 var (
@@ -287,24 +572,35 @@ func file_proto_currencyservice_proto_rawDescGZIP() []byte {
 	return file_proto_currencyservice_proto_rawDescData
 }
 
-var file_proto_currencyservice_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_proto_currencyservice_proto_msgTypes = make([]protoimpl.MessageInfo, 8)
 var file_proto_currencyservice_proto_goTypes = []interface{}{
 	(*Empty)(nil),                          // 0: microshopping.Empty
 	(*Money)(nil),                          // 1: microshopping.Money
 	(*GetSupportedCurrenciesResponse)(nil), // 2: microshopping.GetSupportedCurrenciesResponse
 	(*CurrencyConversionRequest)(nil),      // 3: microshopping.CurrencyConversionRequest
+	(*CurrencyConversionResponse)(nil),     // 4: microshopping.CurrencyConversionResponse
+	(*ConvertBatchRequest)(nil),            // 5: microshopping.ConvertBatchRequest
+	(*ConvertBatchResponse)(nil),           // 6: microshopping.ConvertBatchResponse
+	(*RatesVersion)(nil),                   // 7: microshopping.RatesVersion
 }
 var file_proto_currencyservice_proto_depIdxs = []int32{
 	1, // 0: microshopping.CurrencyConversionRequest.from:type_name -> microshopping.Money
-	0, // 1: microshopping.CurrencyService.GetSupportedCurrencies:input_type -> microshopping.Empty
-	3, // 2: microshopping.CurrencyService.Convert:input_type -> microshopping.CurrencyConversionRequest
-	2, // 3: microshopping.CurrencyService.GetSupportedCurrencies:output_type -> microshopping.GetSupportedCurrenciesResponse
-	1, // 4: microshopping.CurrencyService.Convert:output_type -> microshopping.Money
-	3, // [3:5] is the sub-list for method output_type
-	1, // [1:3] is the sub-list for method input_type
-	1, // [1:1] is the sub-list for extension type_name
-	1, // [1:1] is the sub-list for extension extendee
-	0, // [0:1] is the sub-list for field type_name
+	1, // 1: microshopping.CurrencyConversionResponse.money:type_name -> microshopping.Money
+	1, // 2: microshopping.ConvertBatchRequest.from:type_name -> microshopping.Money
+	1, // 3: microshopping.ConvertBatchResponse.money:type_name -> microshopping.Money
+	0, // 4: microshopping.CurrencyService.GetSupportedCurrencies:input_type -> microshopping.Empty
+	3, // 5: microshopping.CurrencyService.Convert:input_type -> microshopping.CurrencyConversionRequest
+	5, // 6: microshopping.CurrencyService.ConvertBatch:input_type -> microshopping.ConvertBatchRequest
+	0, // 7: microshopping.CurrencyService.GetRatesVersion:input_type -> microshopping.Empty
+	2, // 8: microshopping.CurrencyService.GetSupportedCurrencies:output_type -> microshopping.GetSupportedCurrenciesResponse
+	4, // 9: microshopping.CurrencyService.Convert:output_type -> microshopping.CurrencyConversionResponse
+	6, // 10: microshopping.CurrencyService.ConvertBatch:output_type -> microshopping.ConvertBatchResponse
+	7, // 11: microshopping.CurrencyService.GetRatesVersion:output_type -> microshopping.RatesVersion
+	8, // [8:12] is the sub-list for method output_type
+	4, // [4:8] is the sub-list for method input_type
+	4, // [4:4] is the sub-list for extension type_name
+	4, // [4:4] is the sub-list for extension extendee
+	0, // [0:4] is the sub-list for field type_name
 }
 
 func init() { file_proto_currencyservice_proto_init() }
@@ -361,6 +657,54 @@ func file_proto_currencyservice_proto_init() {
 				return nil
 			}
 		}
+		file_proto_currencyservice_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*CurrencyConversionResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_currencyservice_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ConvertBatchRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_currencyservice_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ConvertBatchResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_currencyservice_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*RatesVersion); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
 	}
 	type x struct{}
 	out := protoimpl.TypeBuilder{
@@ -368,7 +712,7 @@ func file_proto_currencyservice_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: file_proto_currencyservice_proto_rawDesc,
 			NumEnums:      0,
-			NumMessages:   4,
+			NumMessages:   8,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
@@ -397,7 +741,11 @@ type CurrencyServiceClient interface {
 	// 获得支持的货币
 	GetSupportedCurrencies(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*GetSupportedCurrenciesResponse, error)
 	// 转换
-	Convert(ctx context.Context, in *CurrencyConversionRequest, opts ...grpc.CallOption) (*Money, error)
+	Convert(ctx context.Context, in *CurrencyConversionRequest, opts ...grpc.CallOption) (*CurrencyConversionResponse, error)
+	// 批量转换，多个金额转换成同一种货币，使用同一版本的汇率
+	ConvertBatch(ctx context.Context, in *ConvertBatchRequest, opts ...grpc.CallOption) (*ConvertBatchResponse, error)
+	// 获得当前使用的汇率版本
+	GetRatesVersion(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*RatesVersion, error)
 }
 
 type currencyServiceClient struct {
@@ -417,8 +765,8 @@ func (c *currencyServiceClient) GetSupportedCurrencies(ctx context.Context, in *
 	return out, nil
 }
 
-func (c *currencyServiceClient) Convert(ctx context.Context, in *CurrencyConversionRequest, opts ...grpc.CallOption) (*Money, error) {
-	out := new(Money)
+func (c *currencyServiceClient) Convert(ctx context.Context, in *CurrencyConversionRequest, opts ...grpc.CallOption) (*CurrencyConversionResponse, error) {
+	out := new(CurrencyConversionResponse)
 	err := c.cc.Invoke(ctx, "/microshopping.CurrencyService/Convert", in, out, opts...)
 	if err != nil {
 		return nil, err
@@ -426,12 +774,34 @@ func (c *currencyServiceClient) Convert(ctx context.Context, in *CurrencyConvers
 	return out, nil
 }
 
+func (c *currencyServiceClient) ConvertBatch(ctx context.Context, in *ConvertBatchRequest, opts ...grpc.CallOption) (*ConvertBatchResponse, error) {
+	out := new(ConvertBatchResponse)
+	err := c.cc.Invoke(ctx, "/microshopping.CurrencyService/ConvertBatch", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *currencyServiceClient) GetRatesVersion(ctx context.Context, in *Empty, opts ...grpc.CallOption) (*RatesVersion, error) {
+	out := new(RatesVersion)
+	err := c.cc.Invoke(ctx, "/microshopping.CurrencyService/GetRatesVersion", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // CurrencyServiceServer is the server API for CurrencyService service.
 type CurrencyServiceServer interface {
 	// 获得支持的货币
 	GetSupportedCurrencies(context.Context, *Empty) (*GetSupportedCurrenciesResponse, error)
 	// 转换
-	Convert(context.Context, *CurrencyConversionRequest) (*Money, error)
+	Convert(context.Context, *CurrencyConversionRequest) (*CurrencyConversionResponse, error)
+	// 批量转换，多个金额转换成同一种货币，使用同一版本的汇率
+	ConvertBatch(context.Context, *ConvertBatchRequest) (*ConvertBatchResponse, error)
+	// 获得当前使用的汇率版本
+	GetRatesVersion(context.Context, *Empty) (*RatesVersion, error)
 }
 
 // UnimplementedCurrencyServiceServer can be embedded to have forward compatible implementations.
@@ -441,9 +811,15 @@ type UnimplementedCurrencyServiceServer struct {
 func (*UnimplementedCurrencyServiceServer) GetSupportedCurrencies(context.Context, *Empty) (*GetSupportedCurrenciesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetSupportedCurrencies not implemented")
 }
-func (*UnimplementedCurrencyServiceServer) Convert(context.Context, *CurrencyConversionRequest) (*Money, error) {
+func (*UnimplementedCurrencyServiceServer) Convert(context.Context, *CurrencyConversionRequest) (*CurrencyConversionResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method Convert not implemented")
 }
+func (*UnimplementedCurrencyServiceServer) ConvertBatch(context.Context, *ConvertBatchRequest) (*ConvertBatchResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ConvertBatch not implemented")
+}
+func (*UnimplementedCurrencyServiceServer) GetRatesVersion(context.Context, *Empty) (*RatesVersion, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRatesVersion not implemented")
+}
 
 func RegisterCurrencyServiceServer(s *grpc.Server, srv CurrencyServiceServer) {
 	s.RegisterService(&_CurrencyService_serviceDesc, srv)
@@ -485,6 +861,42 @@ func _CurrencyService_Convert_Handler(srv interface{}, ctx context.Context, dec
 	return interceptor(ctx, in, info, handler)
 }
 
+func _CurrencyService_ConvertBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ConvertBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CurrencyServiceServer).ConvertBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/microshopping.CurrencyService/ConvertBatch",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CurrencyServiceServer).ConvertBatch(ctx, req.(*ConvertBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _CurrencyService_GetRatesVersion_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(Empty)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(CurrencyServiceServer).GetRatesVersion(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/microshopping.CurrencyService/GetRatesVersion",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(CurrencyServiceServer).GetRatesVersion(ctx, req.(*Empty))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 var _CurrencyService_serviceDesc = grpc.ServiceDesc{
 	ServiceName: "microshopping.CurrencyService",
 	HandlerType: (*CurrencyServiceServer)(nil),
@@ -497,6 +909,14 @@ var _CurrencyService_serviceDesc = grpc.ServiceDesc{
 			MethodName: "Convert",
 			Handler:    _CurrencyService_Convert_Handler,
 		},
+		{
+			MethodName: "ConvertBatch",
+			Handler:    _CurrencyService_ConvertBatch_Handler,
+		},
+		{
+			MethodName: "GetRatesVersion",
+			Handler:    _CurrencyService_GetRatesVersion_Handler,
+		},
 	},
 	Streams:  []grpc.StreamDesc{},
 	Metadata: "proto/currencyservice.proto",
@@ -0,0 +1,50 @@
+package rates
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// 读取csv文件的汇率来源
+type csvProvider struct {
+	path string
+}
+
+// 获得汇率
+func (p *csvProvider) Rates(ctx context.Context) (map[string]*big.Rat, error) {
+	f, err := os.Open(p.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	r := csv.NewReader(f)
+	r.Comment = '#'
+	r.FieldsPerRecord = 2
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	// 跳过表头
+	if len(records) > 0 && strings.EqualFold(strings.TrimSpace(records[0][0]), "currency") {
+		records = records[1:]
+	}
+
+	out := make(map[string]*big.Rat, len(records))
+	for _, rec := range records {
+		code := strings.ToUpper(strings.TrimSpace(rec[0]))
+		rate, err := parseRate(code, strings.TrimSpace(rec[1]))
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := out[code]; ok {
+			return nil, fmt.Errorf("货币代码重复: %s", code)
+		}
+		out[code] = rate
+	}
+	return out, nil
+}
@@ -0,0 +1,43 @@
+package rates
+
+import (
+	"context"
+	"testing"
+)
+
+func TestCSVProvider(t *testing.T) {
+	got, err := NewCSVProvider("testdata/rates.csv").Rates(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 跳过表头和注释，货币代码转为大写
+	wantRates(t, got, map[string]string{"EUR": "1.0", "USD": "1.1305", "JPY": "126.40"})
+}
+
+func TestCSVProviderWithoutHeader(t *testing.T) {
+	got, err := NewCSVProvider(writeTemp(t, "rates.csv", "EUR,1\nUSD,1.1305\n")).Rates(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantRates(t, got, map[string]string{"EUR": "1", "USD": "1.1305"})
+}
+
+func TestCSVProviderInvalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"货币代码重复", "EUR,1\nUSD,1.1305\nusd,1.1306\n"},
+		{"汇率为0", "EUR,1\nUSD,0\n"},
+		{"汇率为负数", "EUR,1\nUSD,-1.1305\n"},
+		{"汇率不是数字", "EUR,1\nUSD,abc\n"},
+		{"货币代码无效", "EUR,1\nUS,1.1305\n"},
+		{"列数不对", "EUR,1\nUSD,1.1305,1\n"},
+	}
+	for _, tt := range tests {
+		path := writeTemp(t, "rates.csv", tt.content)
+		if r, err := NewCSVProvider(path).Rates(context.Background()); err == nil {
+			t.Errorf("%s: 没有返回错误，汇率为 %v", tt.name, r)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package rates
+
+import (
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// 欧洲央行汇率的基准货币
+const ecbBaseCurrency = "EUR"
+
+// 读取欧洲央行eurofxref xml文件的汇率来源
+type ecbProvider struct {
+	path string
+}
+
+// eurofxref文件的结构，只解析需要的部分：
+//
+//	<Cube><Cube time="2023-06-01"><Cube currency="USD" rate="1.0700"/>...</Cube></Cube>
+type ecbEnvelope struct {
+	Days []ecbDay `xml:"Cube>Cube"`
+}
+
+// 一天的汇率
+type ecbDay struct {
+	Time  string `xml:"time,attr"`
+	Rates []struct {
+		Currency string `xml:"currency,attr"`
+		Rate     string `xml:"rate,attr"`
+	} `xml:"Cube"`
+}
+
+// 获得汇率，使用最新一天的汇率，并加上欧元本身
+func (p *ecbProvider) Rates(ctx context.Context) (map[string]*big.Rat, error) {
+	f, err := os.Open(p.path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var env ecbEnvelope
+	if err := xml.NewDecoder(f).Decode(&env); err != nil {
+		return nil, err
+	}
+	if len(env.Days) == 0 {
+		return nil, errors.New("eurofxref文件中没有汇率")
+	}
+	// 日期格式为yyyy-mm-dd，可以直接按字符串比较
+	latest := env.Days[0]
+	for _, day := range env.Days[1:] {
+		if day.Time > latest.Time {
+			latest = day
+		}
+	}
+
+	out := map[string]*big.Rat{ecbBaseCurrency: big.NewRat(1, 1)}
+	for _, v := range latest.Rates {
+		r, err := parseRate(v.Currency, v.Rate)
+		if err != nil {
+			return nil, err
+		}
+		// 同一天中的货币代码不能重复，也不能再出现基准货币
+		if _, ok := out[v.Currency]; ok {
+			return nil, fmt.Errorf("货币代码重复: %s", v.Currency)
+		}
+		out[v.Currency] = r
+	}
+	return out, nil
+}
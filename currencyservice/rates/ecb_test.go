@@ -0,0 +1,80 @@
+package rates
+
+import (
+	"context"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 把内容写入临时文件，返回文件路径
+func writeTemp(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// 检查汇率，want的值为十进制字符串
+func wantRates(t *testing.T, got map[string]*big.Rat, want map[string]string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("汇率为 %v，期望 %v", got, want)
+	}
+	for code, v := range want {
+		r, _ := new(big.Rat).SetString(v)
+		if got[code] == nil || got[code].Cmp(r) != 0 {
+			t.Fatalf("%s的汇率为 %v，期望 %s", code, got[code], v)
+		}
+	}
+}
+
+func TestECBProviderLatestDay(t *testing.T) {
+	got, err := NewECBProvider("testdata/eurofxref-hist.xml").Rates(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 最新一天不在文件开头，欧元作为基准货币加入
+	wantRates(t, got, map[string]string{"EUR": "1", "USD": "1.1305", "JPY": "126.40", "GBP": "0.85970"})
+}
+
+func TestECBProviderDaily(t *testing.T) {
+	got, err := NewECBProvider("../data/eurofxref-daily.xml").Rates(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for code, want := range map[string]*big.Rat{"EUR": big.NewRat(1, 1), "USD": big.NewRat(11305, 10000)} {
+		if got[code] == nil || got[code].Cmp(want) != 0 {
+			t.Fatalf("%s的汇率为 %v，期望 %v", code, got[code], want)
+		}
+	}
+}
+
+func TestECBProviderInvalid(t *testing.T) {
+	const head = `<?xml version="1.0" encoding="UTF-8"?><gesmes:Envelope xmlns:gesmes="http://www.gesmes.org/xml/2002-08-01" xmlns="http://www.ecb.int/vocabulary/2002-08-01/eurofxref"><Cube>`
+	const tail = `</Cube></gesmes:Envelope>`
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"没有汇率", ``},
+		{"货币代码重复", `<Cube time='2019-03-15'><Cube currency='USD' rate='1.1305'/><Cube currency='USD' rate='1.1306'/></Cube>`},
+		{"包含基准货币", `<Cube time='2019-03-15'><Cube currency='EUR' rate='1.1'/></Cube>`},
+		{"汇率为0", `<Cube time='2019-03-15'><Cube currency='USD' rate='0'/></Cube>`},
+		{"汇率为负数", `<Cube time='2019-03-15'><Cube currency='USD' rate='-1.1'/></Cube>`},
+		{"汇率不是数字", `<Cube time='2019-03-15'><Cube currency='USD' rate='abc'/></Cube>`},
+		{"货币代码无效", `<Cube time='2019-03-15'><Cube currency='USDX' rate='1.1'/></Cube>`},
+	}
+	for _, tt := range tests {
+		path := writeTemp(t, "eurofxref.xml", head+tt.body+tail)
+		if r, err := NewECBProvider(path).Rates(context.Background()); err == nil {
+			t.Errorf("%s: 没有返回错误，汇率为 %v", tt.name, r)
+		}
+	}
+	if _, err := NewECBProvider(writeTemp(t, "eurofxref.xml", "<Cube>")).Rates(context.Background()); err == nil {
+		t.Error("xml格式错误时没有返回错误")
+	}
+}
@@ -0,0 +1,53 @@
+package rates
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+)
+
+// 汇率来源
+type RateProvider interface {
+	// 获得汇率，key为货币代码，汇率都是相对于同一种货币
+	Rates(ctx context.Context) (map[string]*big.Rat, error)
+}
+
+// 实例化读取json文件的RateProvider，文件格式为 {"EUR": 1.0, "USD": 1.1305}
+func NewJSONProvider(path string) RateProvider {
+	return &jsonProvider{path: path}
+}
+
+// 实例化读取欧洲央行eurofxref xml文件的RateProvider，汇率相对于欧元，文件中有多天的汇率时使用最新一天
+func NewECBProvider(path string) RateProvider {
+	return &ecbProvider{path: path}
+}
+
+// 实例化读取csv文件的RateProvider，每行为 货币代码,汇率，可以有currency,rate表头，#开头的行为注释
+func NewCSVProvider(path string) RateProvider {
+	return &csvProvider{path: path}
+}
+
+// 按名称创建RateProvider：json、ecb 或 csv
+func NewProvider(kind, path string) (RateProvider, error) {
+	switch kind {
+	case "json":
+		return NewJSONProvider(path), nil
+	case "ecb":
+		return NewECBProvider(path), nil
+	case "csv":
+		return NewCSVProvider(path), nil
+	}
+	return nil, fmt.Errorf("不支持的汇率来源: %s", kind)
+}
+
+// 解析一种货币的汇率，汇率必须大于0
+func parseRate(code, v string) (*big.Rat, error) {
+	if len(code) != 3 {
+		return nil, fmt.Errorf("货币代码无效: %q", code)
+	}
+	r, ok := new(big.Rat).SetString(v)
+	if !ok || r.Sign() <= 0 {
+		return nil, fmt.Errorf("%s的汇率无效: %s", code, v)
+	}
+	return r, nil
+}
@@ -0,0 +1,34 @@
+package rates
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+)
+
+// 读取json文件的汇率来源
+type jsonProvider struct {
+	path string
+}
+
+// 获得汇率，按十进制字符串解析成有理数，避免浮点误差
+func (p *jsonProvider) Rates(ctx context.Context) (map[string]*big.Rat, error) {
+	data, err := os.ReadFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+	currencies := make(map[string]json.Number)
+	if err := json.Unmarshal(data, &currencies); err != nil {
+		return nil, err
+	}
+	out := make(map[string]*big.Rat, len(currencies))
+	for code, v := range currencies {
+		r, err := parseRate(code, v.String())
+		if err != nil {
+			return nil, err
+		}
+		out[code] = r
+	}
+	return out, nil
+}
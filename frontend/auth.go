@@ -0,0 +1,106 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// 签名登录用户cookie的密钥
+var userCookieKey []byte
+
+// 设置签名登录用户cookie的密钥，secret为空时生成随机密钥，重启后已登录的用户需要重新登录
+func initUserCookieKey(secret string) error {
+	if secret != "" {
+		userCookieKey = []byte(secret)
+		return nil
+	}
+	userCookieKey = make([]byte, 32)
+	_, err := rand.Read(userCookieKey)
+	return err
+}
+
+// 登录用户cookie的值，用户id后面加上服务端的HMAC签名，修改cookie不能冒充其他用户
+func signUserID(user string) string {
+	return user + "." + base64.RawURLEncoding.EncodeToString(userIDMAC(user))
+}
+
+// 校验登录用户cookie的签名，返回用户id
+func verifyUserID(v string) (string, bool) {
+	i := strings.LastIndexByte(v, '.')
+	if i <= 0 {
+		return "", false
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(v[i+1:])
+	if err != nil || !hmac.Equal(sig, userIDMAC(v[:i])) {
+		return "", false
+	}
+	return v[:i], true
+}
+
+func userIDMAC(user string) []byte {
+	mac := hmac.New(sha256.New, userCookieKey)
+	mac.Write([]byte(user))
+	return mac.Sum(nil)
+}
+
+// 可以登录的用户，key为用户id（E-mail地址），value为bcrypt密码哈希，为nil时不能登录
+var userPasswords map[string][]byte
+
+// 用户不存在时用来比较的哈希，不存在的用户和密码错误的耗时相同
+var unknownUserHash []byte
+
+// 登录用户文件的格式
+type usersFile struct {
+	Users []struct {
+		Email        string `json:"email"`
+		PasswordHash string `json:"password_hash"`
+	} `json:"users"`
+}
+
+// 从json文件加载可以登录的用户，密码为bcrypt哈希
+func loadUsers(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var f usersFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("解析用户文件失败: %v", err)
+	}
+	users := make(map[string][]byte, len(f.Users))
+	for _, u := range f.Users {
+		if u.Email == "" {
+			return fmt.Errorf("用户的email不能为空")
+		}
+		if _, ok := users[u.Email]; ok {
+			return fmt.Errorf("用户重复: %s", u.Email)
+		}
+		if _, err := bcrypt.Cost([]byte(u.PasswordHash)); err != nil {
+			return fmt.Errorf("用户 %s 的密码哈希无效: %v", u.Email, err)
+		}
+		users[u.Email] = []byte(u.PasswordHash)
+	}
+	if unknownUserHash, err = bcrypt.GenerateFromPassword(make([]byte, 16), bcrypt.DefaultCost); err != nil {
+		return err
+	}
+	userPasswords = users
+	return nil
+}
+
+// 校验用户的密码
+func checkPassword(user, password string) bool {
+	hash, ok := userPasswords[user]
+	if !ok {
+		bcrypt.CompareHashAndPassword(unknownUserHash, []byte(password))
+		return false
+	}
+	return bcrypt.CompareHashAndPassword(hash, []byte(password)) == nil
+}
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestUserIDCookie(t *testing.T) {
+	if err := initUserCookieKey("test-secret"); err != nil {
+		t.Fatal(err)
+	}
+	v := signUserID("a.b@example.com")
+	if user, ok := verifyUserID(v); !ok || user != "a.b@example.com" {
+		t.Fatalf("verifyUserID(%q) = %q, %v", v, user, ok)
+	}
+
+	forged := []string{
+		"",
+		"a.b@example.com",
+		"other@example.com" + v[len("a.b@example.com"):],
+		v + "x",
+		v[:len(v)-1],
+	}
+	for _, f := range forged {
+		if user, ok := verifyUserID(f); ok {
+			t.Errorf("伪造的cookie %q 校验通过，用户为 %q", f, user)
+		}
+	}
+
+	// 换了密钥后原来的cookie失效
+	if err := initUserCookieKey(""); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := verifyUserID(v); ok {
+		t.Fatal("更换密钥后原来的cookie仍然有效")
+	}
+}
+
+// 写入用户文件，密码使用最低的bcrypt成本
+func writeUsers(t *testing.T, users map[string]string) string {
+	t.Helper()
+	data := `{"users": [`
+	sep := ""
+	for user, password := range users {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data += sep + `{"email": "` + user + `", "password_hash": "` + string(hash) + `"}`
+		sep = ","
+	}
+	path := filepath.Join(t.TempDir(), "users.json")
+	if err := os.WriteFile(path, []byte(data+"]}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckPassword(t *testing.T) {
+	defer func() { userPasswords = nil }()
+	if checkPassword("a@example.com", "") {
+		t.Fatal("没有加载用户文件时登录成功")
+	}
+	if err := loadUsers(writeUsers(t, map[string]string{"a@example.com": "secret"})); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		user, password string
+		want           bool
+	}{
+		{"a@example.com", "secret", true},
+		{"a@example.com", "wrong", false},
+		{"a@example.com", "", false},
+		{"b@example.com", "secret", false},
+	}
+	for _, tt := range tests {
+		if got := checkPassword(tt.user, tt.password); got != tt.want {
+			t.Errorf("checkPassword(%q, %q) = %v，期望 %v", tt.user, tt.password, got, tt.want)
+		}
+	}
+}
+
+func TestLoadUsersInvalid(t *testing.T) {
+	dir := t.TempDir()
+	for name, data := range map[string]string{
+		"格式错误":    `{"users": [`,
+		"email为空": `{"users": [{"email": "", "password_hash": "$2a$04$abc"}]}`,
+		"哈希无效":    `{"users": [{"email": "a@example.com", "password_hash": "secret"}]}`,
+	} {
+		path := filepath.Join(dir, name+".json")
+		if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := loadUsers(path); err == nil {
+			t.Errorf("%s: 加载用户文件没有返回错误", name)
+		}
+	}
+}
+
+// 未登录时用户id为session id，客户端不能用session cookie冒充登录用户
+func TestUserIDSessionFallback(t *testing.T) {
+	if err := initUserCookieKey("test-secret"); err != nil {
+		t.Fatal(err)
+	}
+	sessionUser := func(cookies ...*http.Cookie) string {
+		r := httptest.NewRequest(http.MethodGet, "/orders", nil)
+		for _, c := range cookies {
+			r.AddCookie(c)
+		}
+		var got string
+		ensureSessionID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got = userID(r)
+		}))(httptest.NewRecorder(), r)
+		return got
+	}
+
+	if got := sessionUser(&http.Cookie{Name: cookieSessionID, Value: "victim@example.com"}); got == "victim@example.com" {
+		t.Fatal("session cookie 冒充了登录用户")
+	}
+	session := "0b5c3f2e-8f4a-4d8e-9a57-3f1c2b6d7e90"
+	if got := sessionUser(&http.Cookie{Name: cookieSessionID, Value: session}); got != session {
+		t.Fatalf("未登录时用户id为 %q，期望 %q", got, session)
+	}
+	if got := sessionUser(
+		&http.Cookie{Name: cookieSessionID, Value: session},
+		&http.Cookie{Name: cookieUserID, Value: signUserID("a@example.com")},
+	); got != "a@example.com" {
+		t.Fatalf("登录后用户id为 %q", got)
+	}
+}
@@ -6,16 +6,20 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 
-	"frontend/money"
 	pb "frontend/proto"
+	"money"
 )
 
 var log *logrus.Logger
@@ -39,53 +43,300 @@ func init() {
 	initializeLogger()
 }
 
-// 主页
+// 主页，按url中的sort和page_token排序和分页
 func (fe *FrontendServer) HomeHandler(ctx *gin.Context) {
 	r := ctx.Request
+	sortIndex, ok := productSortIndex(ctx.Query("sort"))
+	if !ok {
+		renderHTTPError(log, ctx, fmt.Errorf("无效的排序方式: %s", ctx.Query("sort")), http.StatusBadRequest)
+		return
+	}
+	pageToken := ctx.Query("page_token")
+
 	currencies, err := fe.getCurrencies(r.Context())
 	if err != nil {
 		renderHTTPError(log, ctx, errors.Wrap(err, "不能查询到货币"), http.StatusInternalServerError)
 		return
 	}
-	products, err := fe.getProducts(r.Context())
-
+	list, err := fe.listProducts(r.Context(), "", productSorts[sortIndex].Sort, pageToken)
+	if status.Code(err) == codes.InvalidArgument {
+		renderHTTPError(log, ctx, errors.Wrap(err, "无效的分页参数"), http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		renderHTTPError(log, ctx, errors.Wrap(err, "不能查询到商品"), http.StatusInternalServerError)
 		return
 	}
-	cart, err := fe.getCart(r.Context(), sessionID(r))
+	cart, err := fe.getCart(r.Context(), userID(r))
 	if err != nil {
 		renderHTTPError(log, ctx, errors.Wrap(err, "不能查询到购物车"), http.StatusInternalServerError)
 		return
 	}
+	ps, err := fe.productViews(r.Context(), list.GetProducts(), currentCurrency(r))
+	if err != nil {
+		renderHTTPError(log, ctx, errors.Wrap(err, "货币转换失败"), http.StatusInternalServerError)
+		return
+	}
+	firstURL, nextURL := pagerURLs(r, pageToken, list.GetNextPageToken())
 
-	type productView struct {
-		Item  *pb.Product
-		Price *pb.Money
+	resultMap := map[string]interface{}{
+		"session_id":    sessionID(r),
+		"request_id":    r.Context().Value(ctxKeyRequestID{}),
+		"user_currency": currentCurrency(r),
+		"locale":        currentLocale(r),
+		"show_currency": true,
+		"currencies":    currencies,
+		"products":      ps,
+		"total_count":   list.GetTotalCount(),
+		"sorts":         sortLinks(r, sortIndex, "默认"),
+		"first_url":     firstURL,
+		"next_url":      nextURL,
+		"cart_size":     cartSize(cart),
+		"ad":            fe.chooseAd(r.Context(), []string{}, log),
 	}
-	ps := make([]productView, len(products))
-	for i, p := range products {
-		price, err := fe.convertCurrency(r.Context(), p.GetPriceUsd(), currentCurrency(r))
-		if err != nil {
-			renderHTTPError(log, ctx, errors.Wrapf(err, "货币转换失败 %s", p.GetId()), http.StatusInternalServerError)
+
+	ctx.HTML(http.StatusOK, "home", resultMap)
+
+}
+
+// 分类页
+func (fe *FrontendServer) categoryHandler(ctx *gin.Context) {
+	category := ctx.Param("name")
+	if category == "" {
+		renderHTTPError(log, ctx, errors.New("分类没有指定"), http.StatusBadRequest)
+		return
+	}
+	fe.renderSearch(ctx, "", category)
+}
+
+// 搜索页
+func (fe *FrontendServer) searchHandler(ctx *gin.Context) {
+	query := strings.TrimSpace(ctx.Query("q"))
+	// 没有关键词时回到首页
+	if query == "" {
+		w := ctx.Writer
+		w.Header().Set("location", "/")
+		w.WriteHeader(http.StatusFound)
+		return
+	}
+	fe.renderSearch(ctx, query, "")
+}
+
+// 搜索结果页，用于搜索页和分类页。按url中的c筛选分类（可以有多个），min和max按用户货币筛选价格，
+// sort和page_token排序和分页，侧边栏显示每个分类的商品个数
+func (fe *FrontendServer) renderSearch(ctx *gin.Context, query, category string) {
+	r := ctx.Request
+	sortIndex, ok := productSortIndex(ctx.Query("sort"))
+	if !ok {
+		renderHTTPError(log, ctx, fmt.Errorf("无效的排序方式: %s", ctx.Query("sort")), http.StatusBadRequest)
+		return
+	}
+	req := &pb.SearchProductsRequest{
+		Query:      query,
+		Category:   category,
+		PageToken:  ctx.Query("page_token"),
+		Sort:       productSorts[sortIndex].Sort,
+		Categories: ctx.QueryArray("c"),
+	}
+	minPrice, maxPrice := strings.TrimSpace(ctx.Query("min")), strings.TrimSpace(ctx.Query("max"))
+	var err error
+	if minPrice != "" {
+		if req.MinPrice, err = money.Parse(&pb.Money{}, currentCurrency(r), minPrice); err != nil {
+			renderHTTPError(log, ctx, fmt.Errorf("无效的最低价格: %s", minPrice), http.StatusBadRequest)
+			return
+		}
+	}
+	if maxPrice != "" {
+		if req.MaxPrice, err = money.Parse(&pb.Money{}, currentCurrency(r), maxPrice); err != nil {
+			renderHTTPError(log, ctx, fmt.Errorf("无效的最高价格: %s", maxPrice), http.StatusBadRequest)
 			return
 		}
-		ps[i] = productView{p, price}
 	}
+	log.WithField("q", query).WithField("category", category).WithField("sort", ctx.Query("sort")).
+		WithField("page_token", req.PageToken).Debug("搜索商品")
+
+	currencies, err := fe.getCurrencies(r.Context())
+	if err != nil {
+		renderHTTPError(log, ctx, errors.Wrap(err, "不能查询到货币"), http.StatusInternalServerError)
+		return
+	}
+	results, err := fe.searchProducts(r.Context(), req)
+	if status.Code(err) == codes.InvalidArgument {
+		renderHTTPError(log, ctx, errors.Wrap(err, "无效的筛选条件"), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		renderHTTPError(log, ctx, errors.Wrap(err, "搜索商品失败"), http.StatusInternalServerError)
+		return
+	}
+	// 没有按价格筛选时分类统计为空，说明分类中没有商品
+	if category != "" && req.MinPrice == nil && req.MaxPrice == nil && len(results.GetCategoryFacets()) == 0 {
+		renderHTTPError(log, ctx, fmt.Errorf("分类不存在: %s", category), http.StatusNotFound)
+		return
+	}
+	cart, err := fe.getCart(r.Context(), userID(r))
+	if err != nil {
+		renderHTTPError(log, ctx, errors.Wrap(err, "不能查询到购物车"), http.StatusInternalServerError)
+		return
+	}
+	ps, err := fe.productViews(r.Context(), results.GetResults(), currentCurrency(r))
+	if err != nil {
+		renderHTTPError(log, ctx, errors.Wrap(err, "货币转换失败"), http.StatusInternalServerError)
+		return
+	}
+
+	type facetView struct {
+		Category string
+		Count    int32
+		Selected bool
+	}
+	selected := make(map[string]bool, len(req.Categories))
+	for _, c := range req.Categories {
+		selected[c] = true
+	}
+	facets := make([]facetView, 0, len(results.GetCategoryFacets()))
+	for _, f := range results.GetCategoryFacets() {
+		// 分类页中当前分类的个数等于总数，不作为筛选条件
+		if f.GetCategory() == category {
+			continue
+		}
+		facets = append(facets, facetView{f.GetCategory(), f.GetCount(), selected[f.GetCategory()]})
+	}
+	defaultSort := "默认"
+	if query != "" {
+		defaultSort = "相关度"
+	}
+	firstURL, nextURL := pagerURLs(r, req.PageToken, results.GetNextPageToken())
 
 	resultMap := map[string]interface{}{
 		"session_id":    sessionID(r),
 		"request_id":    r.Context().Value(ctxKeyRequestID{}),
 		"user_currency": currentCurrency(r),
+		"locale":        currentLocale(r),
 		"show_currency": true,
 		"currencies":    currencies,
+		"search_query":  query,
+		"category":      category,
+		"sort":          ctx.Query("sort"),
 		"products":      ps,
+		"total_count":   results.GetTotalCount(),
+		"facets":        facets,
+		"min_price":     minPrice,
+		"max_price":     maxPrice,
+		"filter_url":    r.URL.EscapedPath(),
+		"clear_url":     pageURL(r, "c", "", "min", "", "max", "", "page_token", ""),
+		"has_filters":   len(req.Categories) > 0 || minPrice != "" || maxPrice != "",
+		"sorts":         sortLinks(r, sortIndex, defaultSort),
+		"first_url":     firstURL,
+		"next_url":      nextURL,
 		"cart_size":     cartSize(cart),
-		"ad":            fe.chooseAd(r.Context(), []string{}, log),
+		"ad":            fe.chooseAd(r.Context(), categoryContext(category), log),
 	}
 
-	ctx.HTML(http.StatusOK, "home", resultMap)
+	ctx.HTML(http.StatusOK, "search", resultMap)
+}
 
+// 商品列表的排序方式，key为url中的sort参数，第一个为默认排序
+var productSorts = []struct {
+	Key   string
+	Label string
+	Sort  pb.ProductSort
+}{
+	{"", "默认", pb.ProductSort_CATALOG_ORDER},
+	{"price_asc", "价格从低到高", pb.ProductSort_PRICE_ASC},
+	{"price_desc", "价格从高到低", pb.ProductSort_PRICE_DESC},
+	{"name_asc", "名称", pb.ProductSort_NAME_ASC},
+}
+
+// url中的sort参数对应的排序方式
+func productSortIndex(key string) (int, bool) {
+	for i, s := range productSorts {
+		if s.Key == key {
+			return i, true
+		}
+	}
+	return 0, false
+}
+
+// 排序方式的链接
+type sortLink struct {
+	Label  string
+	URL    string
+	Active bool
+}
+
+// 所有排序方式的链接，换排序方式时回到第一页，defaultLabel为默认排序显示的名称
+func sortLinks(r *http.Request, active int, defaultLabel string) []sortLink {
+	links := make([]sortLink, len(productSorts))
+	for i, s := range productSorts {
+		label := s.Label
+		if i == 0 {
+			label = defaultLabel
+		}
+		links[i] = sortLink{label, pageURL(r, "sort", s.Key, "page_token", ""), i == active}
+	}
+	return links
+}
+
+// 第一页和下一页的链接，已经在第一页或没有下一页时为空
+func pagerURLs(r *http.Request, pageToken, nextPageToken string) (first, next string) {
+	if pageToken != "" {
+		first = pageURL(r, "page_token", "")
+	}
+	if nextPageToken != "" {
+		next = pageURL(r, "page_token", nextPageToken)
+	}
+	return first, next
+}
+
+// 当前页面修改url参数后的链接，params为成对的参数名和值，值为空时删除该参数
+func pageURL(r *http.Request, params ...string) string {
+	q := r.URL.Query()
+	for i := 0; i+1 < len(params); i += 2 {
+		if params[i+1] == "" {
+			q.Del(params[i])
+		} else {
+			q.Set(params[i], params[i+1])
+		}
+	}
+	if len(q) == 0 {
+		return r.URL.EscapedPath()
+	}
+	return r.URL.EscapedPath() + "?" + q.Encode()
+}
+
+// 列表页的商品和转换成用户货币的价格
+type productView struct {
+	Item  *pb.Product
+	Price *pb.Money
+}
+
+// 在一次请求中把商品价格转换成用户货币
+func (fe *FrontendServer) productViews(ctx context.Context, products []*pb.Product, currency string) ([]productView, error) {
+	if len(products) == 0 {
+		return nil, nil
+	}
+	prices := make([]*pb.Money, len(products))
+	for i, p := range products {
+		prices[i] = p.GetPriceUsd()
+	}
+	prices, err := fe.convertCurrencies(ctx, prices, currency)
+	if err != nil {
+		return nil, err
+	}
+	ps := make([]productView, len(products))
+	for i, p := range products {
+		ps[i] = productView{p, prices[i]}
+	}
+	return ps, nil
+}
+
+// 广告的上下文关键词，分类页按分类选择广告
+func categoryContext(category string) []string {
+	if category == "" {
+		return []string{}
+	}
+	return []string{category}
 }
 
 // 商品
@@ -110,7 +361,7 @@ func (fe *FrontendServer) ProductHandler(ctx *gin.Context) {
 		return
 	}
 
-	cart, err := fe.getCart(r.Context(), sessionID(r))
+	cart, err := fe.getCart(r.Context(), userID(r))
 	if err != nil {
 		renderHTTPError(log, ctx, errors.Wrap(err, "不能查询到购物车"), http.StatusInternalServerError)
 		return
@@ -122,7 +373,7 @@ func (fe *FrontendServer) ProductHandler(ctx *gin.Context) {
 		return
 	}
 
-	recommendations, err := fe.getRecommendations(r.Context(), sessionID(r), []string{id})
+	recommendations, err := fe.getRecommendations(r.Context(), userID(r), []string{id})
 	if err != nil {
 		renderHTTPError(log, ctx, errors.Wrap(err, "商品推荐失败"), http.StatusInternalServerError)
 		return
@@ -138,6 +389,7 @@ func (fe *FrontendServer) ProductHandler(ctx *gin.Context) {
 		"request_id":      r.Context().Value(ctxKeyRequestID{}),
 		"ad":              fe.chooseAd(r.Context(), p.Categories, log),
 		"user_currency":   currentCurrency(r),
+		"locale":          currentLocale(r),
 		"show_currency":   true,
 		"currencies":      currencies,
 		"product":         product,
@@ -152,7 +404,7 @@ func (fe *FrontendServer) ProductHandler(ctx *gin.Context) {
 func (fe *FrontendServer) addToCartHandler(ctx *gin.Context) {
 	r := ctx.Request
 	w := ctx.Writer
-	quantity, _ := strconv.ParseUint(r.FormValue("quantity"), 10, 32)
+	quantity, _ := strconv.ParseUint(r.FormValue("quantity"), 10, 31)
 	productID := r.FormValue("product_id")
 	if productID == "" || quantity == 0 {
 		renderHTTPError(log, ctx, errors.New("无效表单输入"), http.StatusBadRequest)
@@ -166,8 +418,47 @@ func (fe *FrontendServer) addToCartHandler(ctx *gin.Context) {
 		return
 	}
 
-	if err := fe.insertCart(r.Context(), sessionID(r), p.GetId(), int32(quantity)); err != nil {
-		renderHTTPError(log, ctx, errors.Wrap(err, "条件购物车失败"), http.StatusInternalServerError)
+	if err := fe.insertCart(r.Context(), userID(r), p.GetId(), int32(quantity)); err != nil {
+		renderCartError(log, ctx, err, "条件购物车失败")
+		return
+	}
+	w.Header().Set("location", "/cart")
+	w.WriteHeader(http.StatusFound)
+}
+
+// 修改购物车商品数量
+func (fe *FrontendServer) updateCartItemHandler(ctx *gin.Context) {
+	r := ctx.Request
+	w := ctx.Writer
+	quantity, err := strconv.ParseUint(r.FormValue("quantity"), 10, 31)
+	productID := r.FormValue("product_id")
+	if productID == "" || err != nil {
+		renderHTTPError(log, ctx, errors.New("无效表单输入"), http.StatusBadRequest)
+		return
+	}
+	log.WithField("product", productID).WithField("quantity", quantity).Debug("修改购物车商品数量")
+
+	if err := fe.updateCartItem(r.Context(), userID(r), productID, int32(quantity)); err != nil {
+		renderCartError(log, ctx, err, "修改购物车商品数量失败")
+		return
+	}
+	w.Header().Set("location", "/cart")
+	w.WriteHeader(http.StatusFound)
+}
+
+// 移除购物车商品
+func (fe *FrontendServer) removeCartItemHandler(ctx *gin.Context) {
+	r := ctx.Request
+	w := ctx.Writer
+	productID := r.FormValue("product_id")
+	if productID == "" {
+		renderHTTPError(log, ctx, errors.New("无效表单输入"), http.StatusBadRequest)
+		return
+	}
+	log.WithField("product", productID).Debug("移除购物车商品")
+
+	if err := fe.removeCartItem(r.Context(), userID(r), productID); err != nil {
+		renderHTTPError(log, ctx, errors.Wrap(err, "移除购物车商品失败"), http.StatusInternalServerError)
 		return
 	}
 	w.Header().Set("location", "/cart")
@@ -181,7 +472,7 @@ func (fe *FrontendServer) emptyCartHandler(ctx *gin.Context) {
 
 	log.Debug("清空购物车")
 
-	if err := fe.emptyCart(r.Context(), sessionID(r)); err != nil {
+	if err := fe.emptyCart(r.Context(), userID(r)); err != nil {
 		renderHTTPError(log, ctx, errors.Wrap(err, "清空购物车失败"), http.StatusInternalServerError)
 		return
 	}
@@ -189,6 +480,15 @@ func (fe *FrontendServer) emptyCartHandler(ctx *gin.Context) {
 	w.WriteHeader(http.StatusFound)
 }
 
+// 结账表单中默认的配送地址，购物车按这个地址预览运费和税费，和下单时使用的地址一致
+var defaultShippingAddress = &pb.Address{
+	StreetAddress: "和平里16号",
+	City:          "张家口",
+	State:         "河北",
+	ZipCode:       94043,
+	Country:       "中国",
+}
+
 // 浏览购物车
 func (fe *FrontendServer) viewCartHandler(ctx *gin.Context) {
 	r := ctx.Request
@@ -198,21 +498,27 @@ func (fe *FrontendServer) viewCartHandler(ctx *gin.Context) {
 		renderHTTPError(log, ctx, errors.Wrap(err, "不能查询到货币"), http.StatusInternalServerError)
 		return
 	}
-	cart, err := fe.getCart(r.Context(), sessionID(r))
-	if err != nil {
-		renderHTTPError(log, ctx, errors.Wrap(err, "不能查询到购物车"), http.StatusInternalServerError)
-		return
+	// 金额由结算服务统一计算
+	promoCode := strings.TrimSpace(ctx.Query("promo_code"))
+	preview, err := fe.previewOrder(r.Context(), userID(r), currentCurrency(r), promoCode, defaultShippingAddress)
+	// 优惠码不能使用时显示不使用优惠码的购物车，在优惠码旁边提示错误
+	var promoError string
+	if st, ok := status.FromError(err); ok && promoCode != "" && st.Code() == codes.InvalidArgument {
+		promoError = st.Message()
+		preview, err = fe.previewOrder(r.Context(), userID(r), currentCurrency(r), "", defaultShippingAddress)
 	}
-
-	recommendations, err := fe.getRecommendations(r.Context(), sessionID(r), cartIDs(cart))
 	if err != nil {
-		renderHTTPError(log, ctx, errors.Wrap(err, "获得商品推荐失败"), http.StatusInternalServerError)
+		renderCartError(log, ctx, err, "不能计算订单金额")
 		return
 	}
+	cart := make([]*pb.CartItem, len(preview.GetItems()))
+	for i, item := range preview.GetItems() {
+		cart[i] = item.GetItem()
+	}
 
-	shippingCost, err := fe.getShippingQuote(r.Context(), cart, currentCurrency(r))
+	recommendations, err := fe.getRecommendations(r.Context(), userID(r), cartIDs(cart))
 	if err != nil {
-		renderHTTPError(log, ctx, errors.Wrap(err, "配送失败"), http.StatusInternalServerError)
+		renderHTTPError(log, ctx, errors.Wrap(err, "获得商品推荐失败"), http.StatusInternalServerError)
 		return
 	}
 
@@ -221,43 +527,47 @@ func (fe *FrontendServer) viewCartHandler(ctx *gin.Context) {
 		Quantity int32
 		Price    *pb.Money
 	}
-	items := make([]cartItemView, len(cart))
-	totalPrice := &pb.Money{CurrencyCode: currentCurrency(r)}
-	for i, item := range cart {
-		p, err := fe.getProduct(r.Context(), item.GetProductId())
-		if err != nil {
-			renderHTTPError(log, ctx, errors.Wrapf(err, "不能查询到商品 #%s", item.GetProductId()), http.StatusInternalServerError)
-			return
-		}
-		price, err := fe.convertCurrency(r.Context(), p.GetPriceUsd(), currentCurrency(r))
-		if err != nil {
-			renderHTTPError(log, ctx, errors.Wrapf(err, "不能转换货币 #%s", item.GetProductId()), http.StatusInternalServerError)
-			return
-		}
-
-		multPrice := money.MultiplySlow(price, uint32(item.GetQuantity()))
+	ids := make([]string, len(preview.GetItems()))
+	for i, item := range preview.GetItems() {
+		ids[i] = item.GetItem().GetProductId()
+	}
+	products, err := fe.getProducts(r.Context(), ids)
+	if err != nil {
+		renderHTTPError(log, ctx, err, http.StatusInternalServerError)
+		return
+	}
+	items := make([]cartItemView, len(preview.GetItems()))
+	for i, item := range preview.GetItems() {
 		items[i] = cartItemView{
-			Item:     p,
-			Quantity: item.GetQuantity(),
-			Price:    multPrice,
+			Item:     products[i],
+			Quantity: item.GetItem().GetQuantity(),
+			Price:    money.Must(money.Multiply(item.GetCost(), uint32(item.GetItem().GetQuantity()))),
 		}
-		totalPrice = money.Must(money.Sum(totalPrice, multPrice))
 	}
-	totalPrice = money.Must(money.Sum(totalPrice, shippingCost))
+
 	year := time.Now().Year()
 
 	resultMap := map[string]interface{}{
 		"session_id":       sessionID(r),
 		"request_id":       r.Context().Value(ctxKeyRequestID{}),
 		"user_currency":    currentCurrency(r),
+		"locale":           currentLocale(r),
 		"currencies":       currencies,
 		"recommendations":  recommendations,
 		"cart_size":        cartSize(cart),
-		"shipping_cost":    shippingCost,
+		"shipping_cost":    preview.GetShippingCost(),
+		"discount":         preview.GetDiscount(),
+		"promotions":       preview.GetPromotions(),
+		"promo_code":       promoCode,
+		"promo_error":      promoError,
+		"tax":              preview.GetTax(),
+		"address":          defaultShippingAddress,
 		"show_currency":    true,
-		"total_cost":       totalPrice,
+		"total_cost":       preview.GetTotal(),
 		"items":            items,
 		"expiration_years": []int{year, year + 1, year + 2, year + 3, year + 4},
+		// 每次打开购物车生成新的幂等键，重复提交同一个表单只会下一次单
+		"idempotency_key": uuid.NewString(),
 	}
 
 	ctx.HTML(http.StatusOK, "cart", resultMap)
@@ -280,6 +590,8 @@ func (fe *FrontendServer) placeOrderHandler(ctx *gin.Context) {
 		ccMonth, _    = strconv.ParseInt(r.FormValue("credit_card_expiration_month"), 10, 32)
 		ccYear, _     = strconv.ParseInt(r.FormValue("credit_card_expiration_year"), 10, 32)
 		ccCVV, _      = strconv.ParseInt(r.FormValue("credit_card_cvv"), 10, 32)
+		idempotency   = r.FormValue("idempotency_key")
+		promoCode     = strings.TrimSpace(r.FormValue("promo_code"))
 	)
 
 	order, err := fe.checkoutService.PlaceOrder(r.Context(), &pb.PlaceOrderRequest{
@@ -289,7 +601,7 @@ func (fe *FrontendServer) placeOrderHandler(ctx *gin.Context) {
 			CreditCardExpirationMonth: int32(ccMonth),
 			CreditCardExpirationYear:  int32(ccYear),
 			CreditCardCvv:             int32(ccCVV)},
-		UserId:       sessionID(r),
+		UserId:       userID(r),
 		UserCurrency: currentCurrency(r),
 		Address: &pb.Address{
 			StreetAddress: streetAddress,
@@ -297,21 +609,17 @@ func (fe *FrontendServer) placeOrderHandler(ctx *gin.Context) {
 			State:         state,
 			ZipCode:       int32(zipCode),
 			Country:       country},
+		IdempotencyKey: idempotency,
+		PromoCode:      promoCode,
 	})
 	if err != nil {
-		renderHTTPError(log, ctx, errors.Wrap(err, "下订单失败"), http.StatusInternalServerError)
+		renderCartError(log, ctx, err, "下订单失败")
 		return
 	}
 	log.WithField("order", order.GetOrder().GetOrderId()).Info("下单")
 
 	order.GetOrder().GetItems()
-	recommendations, _ := fe.getRecommendations(r.Context(), sessionID(r), nil)
-
-	totalPaid := order.GetOrder().GetShippingCost()
-	for _, v := range order.GetOrder().GetItems() {
-		multPrice := money.MultiplySlow(v.GetCost(), uint32(v.GetItem().GetQuantity()))
-		totalPaid = money.Must(money.Sum(totalPaid, multPrice))
-	}
+	recommendations, _ := fe.getRecommendations(r.Context(), userID(r), nil)
 
 	currencies, err := fe.getCurrencies(r.Context())
 	if err != nil {
@@ -323,16 +631,199 @@ func (fe *FrontendServer) placeOrderHandler(ctx *gin.Context) {
 		"session_id":      sessionID(r),
 		"request_id":      r.Context().Value(ctxKeyRequestID{}),
 		"user_currency":   currentCurrency(r),
+		"locale":          currentLocale(r),
 		"show_currency":   false,
 		"currencies":      currencies,
 		"order":           order.GetOrder(),
-		"total_paid":      &totalPaid,
+		"total_paid":      order.GetOrder().GetTotal(),
 		"recommendations": recommendations,
 	}
 
 	ctx.HTML(http.StatusOK, "order", resultMap)
 }
 
+// 订单列表
+func (fe *FrontendServer) ordersHandler(ctx *gin.Context) {
+	r := ctx.Request
+	page, err := strconv.ParseInt(ctx.DefaultQuery("page", "1"), 10, 32)
+	if err != nil || page < 1 {
+		renderHTTPError(log, ctx, fmt.Errorf("无效的页码: %s", ctx.Query("page")), http.StatusBadRequest)
+		return
+	}
+	log.WithField("page", page).Debug("浏览订单")
+
+	orders, total, err := fe.listOrders(r.Context(), userID(r), int32(page))
+	if err != nil {
+		renderHTTPError(log, ctx, errors.Wrap(err, "不能查询到订单"), http.StatusInternalServerError)
+		return
+	}
+	cart, err := fe.getCart(r.Context(), userID(r))
+	if err != nil {
+		renderHTTPError(log, ctx, errors.Wrap(err, "不能查询到购物车"), http.StatusInternalServerError)
+		return
+	}
+
+	type orderView struct {
+		Order     *pb.Order
+		CreatedAt string
+		ItemCount int32
+	}
+	views := make([]orderView, len(orders))
+	for i, o := range orders {
+		var count int32
+		for _, item := range o.GetOrder().GetItems() {
+			count += item.GetItem().GetQuantity()
+		}
+		views[i] = orderView{
+			Order:     o,
+			CreatedAt: orderTime(o),
+			ItemCount: count,
+		}
+	}
+
+	resultMap := map[string]interface{}{
+		"session_id":    sessionID(r),
+		"request_id":    r.Context().Value(ctxKeyRequestID{}),
+		"user_currency": currentCurrency(r),
+		"locale":        currentLocale(r),
+		"show_currency": false,
+		"cart_size":     cartSize(cart),
+		"orders":        views,
+		"page":          page,
+		"prev_page":     page - 1,
+		"next_page":     page + 1,
+		"has_next":      page*ordersPageSize < int64(total),
+	}
+	ctx.HTML(http.StatusOK, "orders", resultMap)
+}
+
+// 订单详情
+func (fe *FrontendServer) orderHandler(ctx *gin.Context) {
+	r := ctx.Request
+	id := ctx.Param("id")
+	log.WithField("order", id).Debug("浏览订单详情")
+
+	order, err := fe.getOrder(r.Context(), id, userID(r))
+	if status.Code(err) == codes.NotFound {
+		renderHTTPError(log, ctx, fmt.Errorf("订单不存在: %s", id), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		renderHTTPError(log, ctx, errors.Wrap(err, "不能查询到订单"), http.StatusInternalServerError)
+		return
+	}
+	cart, err := fe.getCart(r.Context(), userID(r))
+	if err != nil {
+		renderHTTPError(log, ctx, errors.Wrap(err, "不能查询到购物车"), http.StatusInternalServerError)
+		return
+	}
+
+	type orderItemView struct {
+		Item     *pb.Product
+		Quantity int32
+		Price    *pb.Money
+	}
+	ids := make([]string, len(order.GetOrder().GetItems()))
+	for i, item := range order.GetOrder().GetItems() {
+		ids[i] = item.GetItem().GetProductId()
+	}
+	products, err := fe.getProducts(r.Context(), ids)
+	if err != nil {
+		renderHTTPError(log, ctx, err, http.StatusInternalServerError)
+		return
+	}
+	items := make([]orderItemView, len(order.GetOrder().GetItems()))
+	for i, item := range order.GetOrder().GetItems() {
+		items[i] = orderItemView{
+			Item:     products[i],
+			Quantity: item.GetItem().GetQuantity(),
+			Price:    money.Must(money.Multiply(item.GetCost(), uint32(item.GetItem().GetQuantity()))),
+		}
+	}
+
+	resultMap := map[string]interface{}{
+		"session_id":    sessionID(r),
+		"request_id":    r.Context().Value(ctxKeyRequestID{}),
+		"user_currency": currentCurrency(r),
+		"locale":        currentLocale(r),
+		"show_currency": false,
+		"cart_size":     cartSize(cart),
+		"order":         order.GetOrder(),
+		"created_at":    orderTime(order),
+		"total_paid":    order.GetTotalPaid(),
+		"items":         items,
+	}
+	ctx.HTML(http.StatusOK, "order_detail", resultMap)
+}
+
+// 下单时间
+func orderTime(o *pb.Order) string {
+	return time.Unix(o.GetCreatedAt(), 0).Format("2006-01-02 15:04")
+}
+
+// 登录页面
+func (fe *FrontendServer) loginPageHandler(ctx *gin.Context) {
+	fe.renderLogin(ctx, http.StatusOK, "", "")
+}
+
+// 显示登录页面，user和loginError为登录失败时填写的用户和错误
+func (fe *FrontendServer) renderLogin(ctx *gin.Context, code int, user, loginError string) {
+	r := ctx.Request
+	cart, err := fe.getCart(r.Context(), userID(r))
+	if err != nil {
+		renderHTTPError(log, ctx, errors.Wrap(err, "不能查询到购物车"), http.StatusInternalServerError)
+		return
+	}
+	resultMap := map[string]interface{}{
+		"session_id":    sessionID(r),
+		"request_id":    r.Context().Value(ctxKeyRequestID{}),
+		"user_currency": currentCurrency(r),
+		"show_currency": false,
+		"cart_size":     cartSize(cart),
+		"user_id":       user,
+		"login_error":   loginError,
+	}
+	ctx.HTML(code, "login", resultMap)
+}
+
+// 登录，校验密码后把当前session的购物车合并到登录用户的购物车。
+// 合并失败时不登录，提示用户，当前的购物车保持不变。
+// cookie中的用户id带有服务端签名，只能通过登录设置
+func (fe *FrontendServer) loginHandler(ctx *gin.Context) {
+	r := ctx.Request
+	w := ctx.Writer
+	user := strings.TrimSpace(r.FormValue("user_id"))
+	if user == "" {
+		renderHTTPError(log, ctx, errors.New("无效表单输入"), http.StatusBadRequest)
+		return
+	}
+	log.WithField("user", user).Debug("登录")
+	if !checkPassword(user, r.FormValue("password")) {
+		log.WithField("user", user).Info("登录失败")
+		fe.renderLogin(ctx, http.StatusUnauthorized, user, "用户名或密码错误")
+		return
+	}
+
+	if from := userID(r); from != user {
+		if _, err := fe.mergeCarts(r.Context(), from, user, cartMergePolicy); err != nil {
+			log.WithField("error", err).Warn("合并购物车失败")
+			code, msg := http.StatusInternalServerError, "合并购物车失败，请稍后重试"
+			if st, ok := status.FromError(err); ok && st.Code() == codes.FailedPrecondition {
+				code, msg = http.StatusConflict, "合并购物车失败: "+st.Message()
+			}
+			fe.renderLogin(ctx, code, user, msg)
+			return
+		}
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:   cookieUserID,
+		Value:  signUserID(user),
+		MaxAge: cookieMaxAge,
+	})
+	w.Header().Set("Location", "/")
+	w.WriteHeader(http.StatusFound)
+}
+
 // 退出登录
 func (fe *FrontendServer) logoutHandler(ctx *gin.Context) {
 	r := ctx.Request
@@ -404,6 +895,23 @@ func renderHTTPError(log logrus.FieldLogger, ctx *gin.Context, err error, code i
 	ctx.HTML(http.StatusOK, "error", resultMap)
 }
 
+// 购物车和结算服务返回的错误信息，校验失败时把原因展示给用户，其他错误按服务器错误处理
+func renderCartError(log logrus.FieldLogger, ctx *gin.Context, err error, msg string) {
+	st, ok := status.FromError(err)
+	if !ok {
+		renderHTTPError(log, ctx, errors.Wrap(err, msg), http.StatusInternalServerError)
+		return
+	}
+	switch st.Code() {
+	case codes.InvalidArgument:
+		renderHTTPError(log, ctx, fmt.Errorf("%s", st.Message()), http.StatusBadRequest)
+	case codes.FailedPrecondition:
+		renderHTTPError(log, ctx, fmt.Errorf("%s", st.Message()), http.StatusConflict)
+	default:
+		renderHTTPError(log, ctx, errors.Wrap(err, msg), http.StatusInternalServerError)
+	}
+}
+
 // 当前货币
 func currentCurrency(r *http.Request) string {
 	c, _ := r.Cookie(cookieCurrency)
@@ -413,6 +921,34 @@ func currentCurrency(r *http.Request) string {
 	return defaultCurrency
 }
 
+// 当前地区，按Accept-Language中权重从高到低找第一个支持的地区
+func currentLocale(r *http.Request) string {
+	type tag struct {
+		name string
+		q    float64
+	}
+	var tags []tag
+	for _, part := range strings.Split(r.Header.Get("Accept-Language"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			if f, err := strconv.ParseFloat(params[2:], 64); err == nil {
+				q = f
+			}
+		}
+		if name != "" && name != "*" && q > 0 {
+			tags = append(tags, tag{name: name, q: q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	for _, t := range tags {
+		if money.IsSupportedLocale(t.name) {
+			return t.name
+		}
+	}
+	return defaultLocale
+}
+
 // session会话
 func sessionID(r *http.Request) string {
 	v := r.Context().Value(ctxKeySessionID{})
@@ -422,6 +958,16 @@ func sessionID(r *http.Request) string {
 	return ""
 }
 
+// 用户id，登录后为登录的用户，否则为session id，购物车按用户id保存。签名不正确的cookie视为未登录
+func userID(r *http.Request) string {
+	if c, _ := r.Cookie(cookieUserID); c != nil && c.Value != "" {
+		if user, ok := verifyUserID(c.Value); ok {
+			return user
+		}
+	}
+	return sessionID(r)
+}
+
 // 购物车id
 func cartIDs(c []*pb.CartItem) []string {
 	out := make([]string, len(c))
@@ -440,28 +986,14 @@ func cartSize(c []*pb.CartItem) int {
 	return cartSize
 }
 
-// 格式化货币
-func renderMoney(money *pb.Money) string {
-	currencyLogo := renderCurrencyLogo(money.GetCurrencyCode())
-	return fmt.Sprintf("%s%d.%02d", currencyLogo, money.GetUnits(), money.GetNanos()/10000000)
+// 按地区格式化货币
+func renderMoney(locale string, m *pb.Money) string {
+	return money.Format(m, locale)
 }
 
 // 货币符号
 func renderCurrencyLogo(currencyCode string) string {
-	logos := map[string]string{
-		"USD": "$",
-		"CAD": "$",
-		"JPY": "¥",
-		"EUR": "€",
-		"TRY": "₺",
-		"GBP": "£",
-	}
-
-	logo := "$" //default
-	if val, ok := logos[currencyCode]; ok {
-		logo = val
-	}
-	return logo
+	return money.Symbol(currencyCode)
 }
 
 // 判断字符串是否在字符串切片
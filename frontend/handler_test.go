@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCurrentLocale(t *testing.T) {
+	tests := []struct {
+		name, header, want string
+	}{
+		{"没有请求头", "", defaultLocale},
+		{"只有一个地区", "de-DE", "de-DE"},
+		{"按顺序选择", "fr-FR,de-DE", "fr-FR"},
+		{"按权重选择", "fr-FR;q=0.5,de-DE;q=0.8", "de-DE"},
+		{"权重相同时按顺序", "ja;q=0.7, ko;q=0.7", "ja"},
+		{"跳过不支持的地区", "xx-YY,it-IT;q=0.9", "it-IT"},
+		{"只按语言支持", "pt-BR", "pt-BR"},
+		{"跳过通配符", "*,en-GB;q=0.1", "en-GB"},
+		{"跳过权重为0的地区", "de-DE;q=0,fr", "fr"},
+		{"权重无效时为1", "fr;q=abc,de;q=0.9", "fr"},
+		{"都不支持", "xx,yy;q=0.5", defaultLocale},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/", nil)
+			if tt.header != "" {
+				r.Header.Set("Accept-Language", tt.header)
+			}
+			if got := currentLocale(r); got != tt.want {
+				t.Fatalf("Accept-Language %q 的地区为 %q，期望 %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
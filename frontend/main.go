@@ -1,6 +1,7 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 	"strconv"
@@ -20,11 +21,21 @@ const (
 	version = "1.0.0"
 
 	defaultCurrency = "USD"
+	defaultLocale   = "zh-CN"
 	cookieMaxAge    = 60 * 60 * 48
 
 	cookiePrefix    = "shop_"
 	cookieSessionID = cookiePrefix + "session-id"
 	cookieCurrency  = cookiePrefix + "currency"
+	cookieUserID    = cookiePrefix + "user-id"
+
+	// 登录时合并购物车，同一商品数量相加
+	cartMergePolicy = pb.MergePolicy_SUM
+
+	// 订单列表每页的订单数
+	ordersPageSize = 10
+	// 首页和分类页每页的商品数
+	productsPageSize = 6
 )
 
 var (
@@ -48,7 +59,6 @@ type FrontendServer struct {
 	currencyService       pb.CurrencyServiceClient
 	productCatalogService pb.ProductCatalogServiceClient
 	recommendationService pb.RecommendationServiceClient
-	shippingService       pb.ShippingServiceClient
 }
 
 // 获得grpc连接
@@ -68,7 +78,14 @@ func GetGrpcConn(consulClient *api.Client, serviceName string, serviceTag string
 	return grpcConn
 }
 
+// 签名登录用户cookie的密钥，多个frontend实例需要使用相同的密钥
+var userCookieSecret = flag.String("user-cookie-secret", "", "签名登录用户cookie的密钥，为空时每次启动随机生成")
+
+// 可以登录的用户文件，为空时不能登录
+var usersPath = flag.String("users", "", "可以登录的用户文件（json，密码为bcrypt哈希），为空时不能登录")
+
 func main() {
+	flag.Parse()
 
 	log := logrus.New()
 	log.Level = logrus.DebugLevel
@@ -82,6 +99,22 @@ func main() {
 	}
 	log.Out = os.Stdout
 
+	err_key := initUserCookieKey(*userCookieSecret)
+	if err_key != nil {
+		fmt.Println("生成cookie密钥报错：", err_key)
+		return
+	}
+	if *userCookieSecret == "" {
+		log.Warn("没有设置-user-cookie-secret，使用随机密钥，重启后需要重新登录")
+	}
+
+	if *usersPath == "" {
+		log.Warn("没有设置-users，不能登录")
+	} else if err_users := loadUsers(*usersPath); err_users != nil {
+		fmt.Println("加载用户文件报错：", err_users)
+		return
+	}
+
 	//1.初始化consul配置
 	consulConfig := api.DefaultConfig()
 	//2.创建consul对象
@@ -98,10 +131,10 @@ func main() {
 		currencyService:       pb.NewCurrencyServiceClient(GetGrpcConn(consulClient, "currencyservice", "currencyservice")),
 		productCatalogService: pb.NewProductCatalogServiceClient(GetGrpcConn(consulClient, "productcatalogservice", "productcatalogservice")),
 		recommendationService: pb.NewRecommendationServiceClient(GetGrpcConn(consulClient, "recommendationservice", "recommendationservice")),
-		shippingService:       pb.NewShippingServiceClient(GetGrpcConn(consulClient, "shippingservice", "shippingservice")),
 	}
 
 	r := gin.Default()
+	r.Use(ginMiddleware(ensureSessionID))
 
 	r.FuncMap = template.FuncMap{
 		"renderMoney":        renderMoney,
@@ -114,6 +147,10 @@ func main() {
 
 	// 首页
 	r.GET("/", svc.HomeHandler)
+	// 分类
+	r.GET("/category/:name", svc.categoryHandler)
+	// 搜索
+	r.GET("/search", svc.searchHandler)
 	// 商品
 	r.GET("/product/:id", svc.ProductHandler)
 	// 查看购物车
@@ -122,12 +159,22 @@ func main() {
 	r.POST("/cart", svc.addToCartHandler)
 	// 清空购物车
 	r.POST("/cart/empty", svc.emptyCartHandler)
+	// 修改购物车商品数量
+	r.POST("/cart/update", svc.updateCartItemHandler)
+	// 移除购物车商品
+	r.POST("/cart/remove", svc.removeCartItemHandler)
 	// 设置货币种类
 	r.POST("/setCurrency", svc.setCurrencyHandler)
+	// 登录
+	r.GET("/login", svc.loginPageHandler)
+	r.POST("/login", svc.loginHandler)
 	// // 退出登录
 	r.GET("/logout", svc.logoutHandler)
 	// // 结账
 	r.POST("/cart/checkout", svc.placeOrderHandler)
+	// 订单
+	r.GET("/orders", svc.ordersHandler)
+	r.GET("/orders/:id", svc.orderHandler)
 
 	if err := r.Run(":8052"); err != nil {
 		log.Fatalf("gin启动失败: %v", err)
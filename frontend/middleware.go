@@ -5,6 +5,7 @@ import (
 	"net/http"
 	"time"
 
+	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/sirupsen/logrus"
 )
@@ -71,6 +72,12 @@ func ensureSessionID(next http.Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var sessionID string
 		c, err := r.Cookie(cookieSessionID)
+		// 未登录时session id用作用户id，只接受uuid，客户端不能把session id设置成登录用户的id
+		if err == nil {
+			if _, err_uuid := uuid.Parse(c.Value); err_uuid != nil {
+				err = http.ErrNoCookie
+			}
+		}
 		if err == http.ErrNoCookie {
 			u, _ := uuid.NewRandom()
 			sessionID = u.String()
@@ -89,3 +96,18 @@ func ensureSessionID(next http.Handler) http.HandlerFunc {
 		next.ServeHTTP(w, r)
 	}
 }
+
+// 把http中间件转换为gin中间件，中间件没有调用下一个handler时终止请求
+func ginMiddleware(m func(http.Handler) http.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		called := false
+		m(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			c.Request = r
+			c.Next()
+		})).ServeHTTP(c.Writer, c.Request)
+		if !called {
+			c.Abort()
+		}
+	}
+}
@@ -6,10 +6,13 @@ import (
 	"time"
 
 	"github.com/pkg/errors"
+	"golang.org/x/sync/errgroup"
 )
 
 const (
 	avoidNoopCurrencyConversionRPC = false
+	// 同时查询商品的最大请求数
+	productsConcurrency = 8
 )
 
 func (fe *FrontendServer) getCurrencies(ctx context.Context) ([]string, error) {
@@ -26,9 +29,18 @@ func (fe *FrontendServer) getCurrencies(ctx context.Context) ([]string, error) {
 	return out, nil
 }
 
-func (fe *FrontendServer) getProducts(ctx context.Context) ([]*pb.Product, error) {
-	resp, err := fe.productCatalogService.ListProducts(ctx, &pb.Empty{})
-	return resp.GetProducts(), err
+func (fe *FrontendServer) listProducts(ctx context.Context, category string, sort pb.ProductSort, pageToken string) (*pb.ListProductsResponse, error) {
+	return fe.productCatalogService.ListProducts(ctx, &pb.ListProductsRequest{
+		PageSize:  productsPageSize,
+		PageToken: pageToken,
+		Sort:      sort,
+		Category:  category,
+	})
+}
+
+func (fe *FrontendServer) searchProducts(ctx context.Context, req *pb.SearchProductsRequest) (*pb.SearchProductsResponse, error) {
+	req.PageSize = productsPageSize
+	return fe.productCatalogService.SearchProducts(ctx, req)
 }
 
 func (fe *FrontendServer) getProduct(ctx context.Context, id string) (*pb.Product, error) {
@@ -36,6 +48,26 @@ func (fe *FrontendServer) getProduct(ctx context.Context, id string) (*pb.Produc
 	return resp, err
 }
 
+// 并发查询商品，返回的商品和ids一一对应
+func (fe *FrontendServer) getProducts(ctx context.Context, ids []string) ([]*pb.Product, error) {
+	out := make([]*pb.Product, len(ids))
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(productsConcurrency)
+	for i, id := range ids {
+		i, id := i, id
+		g.Go(func() (err error) {
+			if out[i], err = fe.getProduct(gctx, id); err != nil {
+				return errors.Wrapf(err, "不能查询到商品 #%s", id)
+			}
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 func (fe *FrontendServer) getCart(ctx context.Context, userID string) ([]*pb.CartItem, error) {
 	resp, err := fe.cartService.GetCart(ctx, &pb.GetCartRequest{UserId: userID})
 	return resp.GetItems(), err
@@ -57,26 +89,83 @@ func (fe *FrontendServer) insertCart(ctx context.Context, userID, productID stri
 	return err
 }
 
+func (fe *FrontendServer) updateCartItem(ctx context.Context, userID, productID string, quantity int32) error {
+	_, err := fe.cartService.UpdateItemQuantity(ctx, &pb.UpdateItemQuantityRequest{
+		UserId: userID,
+		Item: &pb.CartItem{
+			ProductId: productID,
+			Quantity:  quantity,
+		},
+	})
+	return err
+}
+
+func (fe *FrontendServer) removeCartItem(ctx context.Context, userID, productID string) error {
+	_, err := fe.cartService.RemoveItem(ctx, &pb.RemoveItemRequest{
+		UserId:    userID,
+		ProductId: productID,
+	})
+	return err
+}
+
+func (fe *FrontendServer) mergeCarts(ctx context.Context, fromUserID, toUserID string, policy pb.MergePolicy) ([]*pb.CartItem, error) {
+	resp, err := fe.cartService.MergeCarts(ctx, &pb.MergeCartsRequest{
+		FromUserId: fromUserID,
+		ToUserId:   toUserID,
+		Policy:     policy,
+	})
+	return resp.GetItems(), err
+}
+
+func (fe *FrontendServer) previewOrder(ctx context.Context, userID, currency, promoCode string, address *pb.Address) (*pb.PreviewOrderResponse, error) {
+	return fe.checkoutService.PreviewOrder(ctx, &pb.PreviewOrderRequest{
+		UserId:       userID,
+		UserCurrency: currency,
+		Address:      address,
+		PromoCode:    promoCode,
+	})
+}
+
+func (fe *FrontendServer) getOrder(ctx context.Context, orderID, userID string) (*pb.Order, error) {
+	return fe.checkoutService.GetOrder(ctx, &pb.GetOrderRequest{
+		OrderId: orderID,
+		UserId:  userID,
+	})
+}
+
+func (fe *FrontendServer) listOrders(ctx context.Context, userID string, page int32) ([]*pb.Order, int32, error) {
+	resp, err := fe.checkoutService.ListOrders(ctx, &pb.ListOrdersRequest{
+		UserId:   userID,
+		Page:     page,
+		PageSize: ordersPageSize,
+	})
+	return resp.GetOrders(), resp.GetTotalCount(), err
+}
+
 func (fe *FrontendServer) convertCurrency(ctx context.Context, money *pb.Money, currency string) (*pb.Money, error) {
 	if avoidNoopCurrencyConversionRPC && money.GetCurrencyCode() == currency {
 		return money, nil
 	}
-	return fe.currencyService.Convert(ctx, &pb.CurrencyConversionRequest{
+	resp, err := fe.currencyService.Convert(ctx, &pb.CurrencyConversionRequest{
 		From:   money,
 		ToCode: currency,
 	})
+	return resp.GetMoney(), err
 }
 
-func (fe *FrontendServer) getShippingQuote(ctx context.Context, items []*pb.CartItem, currency string) (*pb.Money, error) {
-	quote, err := fe.shippingService.GetQuote(ctx, &pb.GetQuoteRequest{
-		Address: nil,
-		Items:   items,
+// 批量货币转换，一次请求转换多个金额，结果和money一一对应
+func (fe *FrontendServer) convertCurrencies(ctx context.Context, money []*pb.Money, currency string) ([]*pb.Money, error) {
+	resp, err := fe.currencyService.ConvertBatch(ctx, &pb.ConvertBatchRequest{
+		From:   money,
+		ToCode: currency,
 	})
 	if err != nil {
 		return nil, err
 	}
-	localized, err := fe.convertCurrency(ctx, quote.GetCostUsd(), currency)
-	return localized, errors.Wrap(err, "failed to convert currency for shipping cost")
+	if len(resp.GetMoney()) != len(money) {
+		return nil, errors.Errorf("货币转换返回%d个结果，需要%d个", len(resp.GetMoney()), len(money))
+	}
+	return resp.GetMoney(), nil
 }
 
 func (fe *FrontendServer) getRecommendations(ctx context.Context, userID string, productIDs []string) ([]*pb.Product, error) {
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "frontend/proto"
+)
+
+// 每次查询等待10ms的商品服务，记录同时进行的最大查询数
+type slowCatalog struct {
+	pb.ProductCatalogServiceClient
+	sync.Mutex
+	running, maxRunning int
+}
+
+func (c *slowCatalog) GetProduct(ctx context.Context, in *pb.GetProductRequest, opts ...grpc.CallOption) (*pb.Product, error) {
+	c.Lock()
+	c.running++
+	if c.running > c.maxRunning {
+		c.maxRunning = c.running
+	}
+	c.Unlock()
+	defer func() {
+		c.Lock()
+		c.running--
+		c.Unlock()
+	}()
+	time.Sleep(10 * time.Millisecond)
+	if in.GetId() == "missing" {
+		return nil, status.Errorf(codes.NotFound, "商品不存在")
+	}
+	return &pb.Product{Id: in.GetId()}, nil
+}
+
+func TestGetProducts(t *testing.T) {
+	catalog := &slowCatalog{}
+	fe := &FrontendServer{productCatalogService: catalog}
+	ids := make([]string, 2*productsConcurrency)
+	for i := range ids {
+		ids[i] = fmt.Sprintf("p%d", i)
+	}
+	products, err := fe.getProducts(context.Background(), ids)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, p := range products {
+		if p.GetId() != ids[i] {
+			t.Fatalf("第%d个商品为 %s，期望 %s", i, p.GetId(), ids[i])
+		}
+	}
+	if catalog.maxRunning < 2 || catalog.maxRunning > productsConcurrency {
+		t.Fatalf("最多同时查询%d个商品，期望2到%d个", catalog.maxRunning, productsConcurrency)
+	}
+
+	if _, err := fe.getProducts(context.Background(), []string{"p1", "missing"}); status.Code(errors.Cause(err)) != codes.NotFound {
+		t.Fatalf("查询不存在的商品返回 %v", err)
+	}
+}
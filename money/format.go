@@ -0,0 +1,182 @@
+package money
+
+import (
+	"math/big"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 货币的小数位数（ISO 4217），不在表中的货币为2位
+var minorUnits = map[string]int{
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+}
+
+// 货币符号，不在表中的货币显示货币代码
+var symbols = map[string]string{
+	"AUD": "A$", "BGN": "лв", "BRL": "R$", "CAD": "CA$", "CHF": "CHF", "CNY": "CN¥",
+	"CZK": "Kč", "DKK": "kr", "EUR": "€", "GBP": "£", "HKD": "HK$", "HRK": "kn",
+	"HUF": "Ft", "IDR": "Rp", "ILS": "₪", "INR": "₹", "ISK": "kr", "JPY": "¥",
+	"KRW": "₩", "MXN": "MX$", "MYR": "RM", "NOK": "kr", "NZD": "NZ$", "PHP": "₱",
+	"PLN": "zł", "RON": "lei", "RUB": "₽", "SEK": "kr", "SGD": "S$", "THB": "฿",
+	"TRY": "₺", "USD": "$", "ZAR": "R",
+}
+
+// 地区的金额格式
+type Locale struct {
+	// 小数点
+	Decimal string
+	// 千位分隔符
+	Group string
+	// 货币符号放在数字后面
+	SymbolAfter bool
+	// 货币符号和数字之间加空格
+	SymbolSpace bool
+}
+
+// 支持的地区，key为小写的语言或语言-地区，地区找不到时按语言查找
+var locales = map[string]Locale{
+	"en":    {Decimal: ".", Group: ","},
+	"zh":    {Decimal: ".", Group: ","},
+	"ja":    {Decimal: ".", Group: ","},
+	"ko":    {Decimal: ".", Group: ","},
+	"de":    {Decimal: ",", Group: ".", SymbolAfter: true, SymbolSpace: true},
+	"de-ch": {Decimal: ".", Group: "’", SymbolSpace: true},
+	"es":    {Decimal: ",", Group: ".", SymbolAfter: true, SymbolSpace: true},
+	"it":    {Decimal: ",", Group: ".", SymbolAfter: true, SymbolSpace: true},
+	"nl":    {Decimal: ",", Group: ".", SymbolSpace: true},
+	"pt":    {Decimal: ",", Group: ".", SymbolSpace: true},
+	"pt-pt": {Decimal: ",", Group: "\u00a0", SymbolAfter: true, SymbolSpace: true},
+	"fr":    {Decimal: ",", Group: "\u00a0", SymbolAfter: true, SymbolSpace: true},
+	"ru":    {Decimal: ",", Group: "\u00a0", SymbolAfter: true, SymbolSpace: true},
+	"pl":    {Decimal: ",", Group: "\u00a0", SymbolAfter: true, SymbolSpace: true},
+	"sv":    {Decimal: ",", Group: "\u00a0", SymbolAfter: true, SymbolSpace: true},
+	"tr":    {Decimal: ",", Group: "."},
+}
+
+// 默认地区
+const DefaultLocale = "en-US"
+
+// 货币的小数位数
+func MinorUnits(currencyCode string) int {
+	if n, ok := minorUnits[currencyCode]; ok {
+		return n
+	}
+	return 2
+}
+
+// 货币符号
+func Symbol(currencyCode string) string {
+	if s, ok := symbols[currencyCode]; ok {
+		return s
+	}
+	return currencyCode
+}
+
+// 是否支持该地区
+func IsSupportedLocale(tag string) bool {
+	_, ok := lookupLocale(tag)
+	return ok
+}
+
+// 查找地区格式，tag为语言-地区，如zh-CN，先按语言-地区查找，再按语言查找
+func lookupLocale(tag string) (Locale, bool) {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if l, ok := locales[tag]; ok {
+		return l, true
+	}
+	if i := strings.IndexByte(tag, '-'); i > 0 {
+		l, ok := locales[tag[:i]]
+		return l, ok
+	}
+	return Locale{}, false
+}
+
+// 按地区格式化金额，小数位数按货币确定，多余的位数四舍五入
+func Format(m Money, locale string) string {
+	l, ok := lookupLocale(locale)
+	if !ok {
+		l, _ = lookupLocale(DefaultLocale)
+	}
+	code := m.GetCurrencyCode()
+	digits := MinorUnits(code)
+
+	// 换算成最小单位
+	n := divRound(toNanos(m), minorUnitNanos(code), RoundHalfUp)
+	neg := n.Sign() < 0
+	s := n.Abs(n).String()
+	if len(s) <= digits {
+		s = strings.Repeat("0", digits-len(s)+1) + s
+	}
+	num := groupDigits(s[:len(s)-digits], l.Group)
+	if digits > 0 {
+		num += l.Decimal + s[len(s)-digits:]
+	}
+
+	sym := Symbol(code)
+	sep := ""
+	// 使用不换行空格，字母组成的符号放在前面时也要空格，如CHF 1.00
+	if last, _ := utf8.DecodeLastRuneInString(sym); l.SymbolSpace || !l.SymbolAfter && unicode.IsLetter(last) {
+		sep = "\u00a0"
+	}
+	out := sym + sep + num
+	if l.SymbolAfter {
+		out = num + sep + sym
+	}
+	if neg {
+		out = "-" + out
+	}
+	return out
+}
+
+// 整数部分每3位加分隔符
+func groupDigits(s, group string) string {
+	if len(s) <= 3 || group == "" {
+		return s
+	}
+	var b strings.Builder
+	head := len(s) % 3
+	if head > 0 {
+		b.WriteString(s[:head])
+	}
+	for i := head; i < len(s); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(group)
+		}
+		b.WriteString(s[i : i+3])
+	}
+	return b.String()
+}
+
+// 解析十进制金额，如"12.5"，最多9位小数，返回和like类型相同、货币为currencyCode的金额
+func Parse[M Money](like M, currencyCode, s string) (M, error) {
+	s = strings.TrimSpace(s)
+	digits := strings.TrimPrefix(s, "-")
+	intPart, frac := digits, ""
+	if i := strings.IndexByte(digits, '.'); i >= 0 {
+		intPart, frac = digits[:i], digits[i+1:]
+	}
+	if intPart == "" && frac == "" || len(frac) > 9 || !isDigits(intPart) || !isDigits(frac) {
+		return newMoney(like, "", 0, 0), ErrInvalidValue
+	}
+	n, ok := new(big.Int).SetString(intPart+frac+strings.Repeat("0", 9-len(frac)), 10)
+	if !ok {
+		return newMoney(like, "", 0, 0), ErrInvalidValue
+	}
+	if strings.HasPrefix(s, "-") {
+		n.Neg(n)
+	}
+	return fromNanos(like, currencyCode, n)
+}
+
+// 是否只包含0-9，空字符串也返回true
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
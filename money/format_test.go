@@ -0,0 +1,144 @@
+package money
+
+import (
+	"testing"
+
+	"money/internal/testpb"
+)
+
+func TestFormat(t *testing.T) {
+	m := func(code string, units int64, nanos int32) *testpb.Money {
+		return &testpb.Money{CurrencyCode: code, Units: units, Nanos: nanos}
+	}
+	tests := []struct {
+		name   string
+		m      *testpb.Money
+		locale string
+		want   string
+	}{
+		{"美元", m("USD", 1234, 500000000), "en-US", "$1,234.50"},
+		{"小于1", m("USD", 0, 50000000), "en-US", "$0.05"},
+		{"零", m("USD", 0, 0), "en-US", "$0.00"},
+		{"负数", m("USD", -1234567, -890000000), "en-US", "-$1,234,567.89"},
+		{"多余的小数四舍五入", m("USD", 1, 995000000), "en-US", "$2.00"},
+		{"负数四舍五入", m("USD", 0, -5000000), "en-US", "-$0.01"},
+		// 日元没有小数
+		{"日元", m("JPY", 1234567, 0), "ja-JP", "¥1,234,567"},
+		{"日元小数四舍五入", m("JPY", 99, 500000000), "ja-JP", "¥100"},
+		{"日元德语地区", m("JPY", 1000, 0), "de-DE", "1.000 ¥"},
+		// 第纳尔3位小数
+		{"第纳尔", m("KWD", 12, 345000000), "en", "KWD 12.345"},
+		// 符号位置
+		{"德语符号在后", m("EUR", 1234, 560000000), "de-DE", "1.234,56 €"},
+		{"法语空格分组", m("EUR", 1234567, 890000000), "fr-FR", "1 234 567,89 €"},
+		{"荷兰语符号在前", m("EUR", 1234, 560000000), "nl-NL", "€ 1.234,56"},
+		{"瑞士德语", m("CHF", 1234, 560000000), "de-CH", "CHF 1’234.56"},
+		{"葡萄牙和巴西不同", m("EUR", 1234, 560000000), "pt-PT", "1 234,56 €"},
+		{"巴西", m("BRL", 1234, 560000000), "pt-BR", "R$ 1.234,56"},
+		{"土耳其", m("TRY", 1234, 560000000), "tr-TR", "₺1.234,56"},
+		{"字母符号在前加空格", m("CHF", 1, 0), "en-US", "CHF 1.00"},
+		{"没有符号的货币", m("XXX", 1, 0), "en-US", "XXX 1.00"},
+		{"负数符号在后", m("EUR", -5, 0), "de", "-5,00 €"},
+		// 不支持的地区使用默认地区
+		{"不支持的地区", m("USD", 1234, 0), "xx-YY", "$1,234.00"},
+		{"空地区", m("USD", 1234, 0), "", "$1,234.00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Format(tt.m, tt.locale); got != tt.want {
+				t.Fatalf("Format(%v, %q) = %q，期望 %q", tt.m, tt.locale, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGroupDigits(t *testing.T) {
+	tests := []struct {
+		s, group, want string
+	}{
+		{"0", ",", "0"},
+		{"123", ",", "123"},
+		{"1234", ",", "1,234"},
+		{"12345", ",", "12,345"},
+		{"123456", ",", "123,456"},
+		{"1234567", ".", "1.234.567"},
+		{"1234567", " ", "1 234 567"},
+		{"1234567", "", "1234567"},
+	}
+	for _, tt := range tests {
+		if got := groupDigits(tt.s, tt.group); got != tt.want {
+			t.Errorf("groupDigits(%q, %q) = %q，期望 %q", tt.s, tt.group, got, tt.want)
+		}
+	}
+}
+
+func TestLookupLocale(t *testing.T) {
+	tests := []struct {
+		tag  string
+		want Locale
+		ok   bool
+	}{
+		{"en", locales["en"], true},
+		{"en-US", locales["en"], true},
+		{"EN_us", locales["en"], true},
+		{" de-DE ", locales["de"], true},
+		// 有语言-地区的格式时不使用语言的格式
+		{"de-CH", locales["de-ch"], true},
+		{"pt-PT", locales["pt-pt"], true},
+		{"pt-BR", locales["pt"], true},
+		{"xx", Locale{}, false},
+		{"xx-DE", Locale{}, false},
+		{"-de", Locale{}, false},
+		{"", Locale{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := lookupLocale(tt.tag)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("lookupLocale(%q) = %+v, %v，期望 %+v, %v", tt.tag, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		s     string
+		units int64
+		nanos int32
+	}{
+		{"12.5", 12, 500000000},
+		{" 12.50 ", 12, 500000000},
+		{"0", 0, 0},
+		{"-0.01", 0, -10000000},
+		{"-3.25", -3, -250000000},
+		{".5", 0, 500000000},
+		{"7.", 7, 0},
+		{"0.000000001", 0, 1},
+		{"9223372036854775807.999999999", 9223372036854775807, 999999999},
+	}
+	for _, tt := range tests {
+		got, err := Parse(&testpb.Money{}, "USD", tt.s)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", tt.s, err)
+			continue
+		}
+		if got.GetCurrencyCode() != "USD" || got.GetUnits() != tt.units || got.GetNanos() != tt.nanos {
+			t.Errorf("Parse(%q) = %v，期望 %d.%09d", tt.s, got, tt.units, tt.nanos)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, s := range []string{"", "-", ".", "abc", "1,5", "1.2.3", "+1", "1e3", "0.0000000001", "--1"} {
+		if _, err := Parse(&testpb.Money{}, "USD", s); err != ErrInvalidValue {
+			t.Errorf("Parse(%q) 返回 %v，期望 %v", s, err, ErrInvalidValue)
+		}
+	}
+}
+
+func TestParseOverflow(t *testing.T) {
+	for _, s := range []string{"9223372036854775808", "-9223372036854775809"} {
+		if _, err := Parse(&testpb.Money{}, "USD", s); err != ErrOverflow {
+			t.Errorf("Parse(%q) 返回 %v，期望 %v", s, err, ErrOverflow)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+// 各服务共用的金额计算，适用于各服务proto生成的Money类型
+package money
+
+import (
+	"errors"
+	"math/big"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	nanosMin = -999999999
+	nanosMax = +999999999
+	nanosMod = 1000000000
+)
+
+var (
+	ErrInvalidValue        = errors.New("数值无效")
+	ErrMismatchingCurrency = errors.New("货币代码不匹配")
+	ErrOverflow            = errors.New("金额超出范围")
+)
+
+// 金额，各服务proto生成的*pb.Money都满足这个接口
+type Money interface {
+	proto.Message
+	GetCurrencyCode() string
+	GetUnits() int64
+	GetNanos() int32
+}
+
+// 创建和like类型相同的金额，各服务的Money是不同的类型，通过protoreflect按字段名赋值
+func newMoney[M Money](like M, currency string, units int64, nanos int32) M {
+	m := like.ProtoReflect().Type().New()
+	fields := m.Descriptor().Fields()
+	m.Set(fields.ByName("currency_code"), protoreflect.ValueOfString(currency))
+	m.Set(fields.ByName("units"), protoreflect.ValueOfInt64(units))
+	m.Set(fields.ByName("nanos"), protoreflect.ValueOfInt32(nanos))
+	return m.Interface().(M)
+}
+
+// 是否有效
+func IsValid[M Money](m M) bool {
+	return signMatches(m) && validNanos(m.GetNanos())
+}
+
+func signMatches(m Money) bool {
+	return m.GetNanos() == 0 || m.GetUnits() == 0 || (m.GetNanos() < 0) == (m.GetUnits() < 0)
+}
+
+func validNanos(nanos int32) bool { return nanosMin <= nanos && nanos <= nanosMax }
+
+// 零
+func IsZero[M Money](m M) bool { return m.GetUnits() == 0 && m.GetNanos() == 0 }
+
+// 正的
+func IsPositive[M Money](m M) bool {
+	return IsValid(m) && (m.GetUnits() > 0 || (m.GetUnits() == 0 && m.GetNanos() > 0))
+}
+
+// 负的
+func IsNegative[M Money](m M) bool {
+	return IsValid(m) && (m.GetUnits() < 0 || (m.GetUnits() == 0 && m.GetNanos() < 0))
+}
+
+// 是否相同
+func AreSameCurrency[M Money](l, r M) bool {
+	return l.GetCurrencyCode() == r.GetCurrencyCode() && l.GetCurrencyCode() != ""
+}
+
+// 是否相等
+func AreEquals[M Money](l, r M) bool {
+	return l.GetCurrencyCode() == r.GetCurrencyCode() &&
+		l.GetUnits() == r.GetUnits() && l.GetNanos() == r.GetNanos()
+}
+
+// 变成负的
+func Negate[M Money](m M) M {
+	return newMoney(m, m.GetCurrencyCode(), -m.GetUnits(), -m.GetNanos())
+}
+
+// Must
+func Must[M Money](v M, err error) M {
+	if err != nil {
+		panic(err)
+	}
+	return v
+}
+
+// sum
+func Sum[M Money](l, r M) (M, error) {
+	if !IsValid(l) || !IsValid(r) {
+		return newMoney(l, "", 0, 0), ErrInvalidValue
+	} else if l.GetCurrencyCode() != r.GetCurrencyCode() {
+		return newMoney(l, "", 0, 0), ErrMismatchingCurrency
+	}
+	units := l.GetUnits() + r.GetUnits()
+	nanos := l.GetNanos() + r.GetNanos()
+
+	if (units == 0 && nanos == 0) || (units >= 0 && nanos >= 0) || (units < 0 && nanos <= 0) {
+		// 相同 sign <units, nanos>
+		units += int64(nanos / nanosMod)
+		nanos = nanos % nanosMod
+	} else {
+		// 不同 sign.
+		if units > 0 {
+			units--
+			nanos += nanosMod
+		} else {
+			units++
+			nanos -= nanosMod
+		}
+	}
+
+	return newMoney(l, l.GetCurrencyCode(), units, nanos), nil
+}
+
+// l - r
+func Subtract[M Money](l, r M) (M, error) {
+	return Sum(l, Negate(r))
+}
+
+// 比较大小，l小于、等于、大于r时分别返回-1、0、1
+func Compare[M Money](l, r M) (int, error) {
+	if !IsValid(l) || !IsValid(r) {
+		return 0, ErrInvalidValue
+	} else if l.GetCurrencyCode() != r.GetCurrencyCode() {
+		return 0, ErrMismatchingCurrency
+	}
+	// 有效金额的units和nanos符号一致，可以先比较units再比较nanos
+	switch {
+	case l.GetUnits() < r.GetUnits():
+		return -1, nil
+	case l.GetUnits() > r.GetUnits():
+		return 1, nil
+	case l.GetNanos() < r.GetNanos():
+		return -1, nil
+	case l.GetNanos() > r.GetNanos():
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// 乘以n，直接计算，不再循环累加
+func Multiply[M Money](m M, n uint32) (M, error) {
+	if !IsValid(m) {
+		return newMoney(m, "", 0, 0), ErrInvalidValue
+	}
+	total := toNanos(m)
+	return fromNanos(m, m.GetCurrencyCode(), total.Mul(total, big.NewInt(int64(n))))
+}
+
+// 金额转换成nanos，使用big.Int避免溢出
+func toNanos(m Money) *big.Int {
+	n := big.NewInt(m.GetUnits())
+	n.Mul(n, big.NewInt(nanosMod))
+	return n.Add(n, big.NewInt(int64(m.GetNanos())))
+}
+
+// nanos转换成金额，units超出int64时返回ErrOverflow
+func fromNanos[M Money](like M, currency string, n *big.Int) (M, error) {
+	units, nanos := new(big.Int).QuoRem(n, big.NewInt(nanosMod), new(big.Int))
+	if !units.IsInt64() {
+		return newMoney(like, "", 0, 0), ErrOverflow
+	}
+	return newMoney(like, currency, units.Int64(), int32(nanos.Int64())), nil
+}
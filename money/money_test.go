@@ -0,0 +1,205 @@
+package money
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+	"testing/quick"
+
+	"money/internal/testpb"
+)
+
+// 随机生成的有效金额，units的范围保证两个金额相加不会溢出
+type validMoney struct {
+	*testpb.Money
+}
+
+func (validMoney) Generate(r *rand.Rand, size int) reflect.Value {
+	units := r.Int63n(2e12) - 1e12
+	nanos := r.Int31n(nanosMax + 1)
+	switch {
+	case units < 0:
+		nanos = -nanos
+	case units == 0 && r.Intn(2) == 0:
+		nanos = -nanos
+	}
+	return reflect.ValueOf(validMoney{usd(units, nanos)})
+}
+
+func usd(units int64, nanos int32) *testpb.Money {
+	return &testpb.Money{CurrencyCode: "USD", Units: units, Nanos: nanos}
+}
+
+func TestIsPositiveNegative(t *testing.T) {
+	tests := []struct {
+		m                  *testpb.Money
+		positive, negative bool
+	}{
+		{usd(1, 0), true, false},
+		{usd(0, 1), true, false},
+		{usd(-1, 0), false, true},
+		{usd(0, -1), false, true},
+		{usd(0, 0), false, false},
+		// 无效的金额既不是正的也不是负的
+		{usd(0, 2e9), false, false},
+		{usd(0, -2e9), false, false},
+		{usd(1, -1), false, false},
+		{usd(-1, 1), false, false},
+	}
+	for _, tt := range tests {
+		if got := IsPositive(tt.m); got != tt.positive {
+			t.Errorf("IsPositive(%v) = %v，期望 %v", tt.m, got, tt.positive)
+		}
+		if got := IsNegative(tt.m); got != tt.negative {
+			t.Errorf("IsNegative(%v) = %v，期望 %v", tt.m, got, tt.negative)
+		}
+	}
+}
+
+func TestSumSubtractInverse(t *testing.T) {
+	f := func(a, b validMoney) bool {
+		sum, err := Sum(a.Money, b.Money)
+		if err != nil || !IsValid(sum) {
+			return false
+		}
+		diff, err := Subtract(sum, b.Money)
+		return err == nil && AreEquals(diff, a.Money)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSumCommutative(t *testing.T) {
+	f := func(a, b validMoney) bool {
+		l, err1 := Sum(a.Money, b.Money)
+		r, err2 := Sum(b.Money, a.Money)
+		return err1 == nil && err2 == nil && AreEquals(l, r)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSumMismatchingCurrency(t *testing.T) {
+	eur := &testpb.Money{CurrencyCode: "EUR", Units: 1}
+	if _, err := Sum(usd(1, 0), eur); err != ErrMismatchingCurrency {
+		t.Fatalf("不同货币相加返回 %v，期望 %v", err, ErrMismatchingCurrency)
+	}
+}
+
+func TestMultiply(t *testing.T) {
+	tests := []struct {
+		m    *testpb.Money
+		n    uint32
+		want *testpb.Money
+	}{
+		{usd(1, 500000000), 3, usd(4, 500000000)},
+		{usd(0, 999999999), 2, usd(1, 999999998)},
+		{usd(5, 250000000), 0, usd(0, 0)},
+		{usd(7, 0), 1, usd(7, 0)},
+		// 负数的units和nanos符号保持一致
+		{usd(-1, -500000000), 3, usd(-4, -500000000)},
+		{usd(0, -600000000), 2, usd(-1, -200000000)},
+		{usd(0, -1), 1000000000, usd(-1, 0)},
+		{usd(1, 0), 4294967295, usd(4294967295, 0)},
+		// 结果刚好在int64范围内
+		{usd(4611686018427387903, 0), 2, usd(9223372036854775806, 0)},
+		{usd(-4611686018427387904, 0), 2, usd(-9223372036854775808, 0)},
+		{usd(-4611686018427387904, -1), 2, usd(-9223372036854775808, -2)},
+	}
+	for _, tt := range tests {
+		got, err := Multiply(tt.m, tt.n)
+		if err != nil || !AreEquals(got, tt.want) {
+			t.Errorf("Multiply(%v, %d) = %v, %v，期望 %v", tt.m, tt.n, got, err, tt.want)
+		}
+	}
+}
+
+func TestMultiplyError(t *testing.T) {
+	tests := []struct {
+		m   *testpb.Money
+		n   uint32
+		err error
+	}{
+		{usd(4611686018427387904, 0), 2, ErrOverflow},
+		{usd(-4611686018427387905, 0), 2, ErrOverflow},
+		{usd(9223372036854775807, 999999999), 4294967295, ErrOverflow},
+		{usd(1, -1), 2, ErrInvalidValue},
+		{usd(0, 1000000000), 2, ErrInvalidValue},
+	}
+	for _, tt := range tests {
+		if _, err := Multiply(tt.m, tt.n); err != tt.err {
+			t.Errorf("Multiply(%v, %d) 返回 %v，期望 %v", tt.m, tt.n, err, tt.err)
+		}
+	}
+}
+
+// 乘以n和累加n次的结果相同
+func TestMultiplySum(t *testing.T) {
+	f := func(a validMoney, n uint8) bool {
+		product, err := Multiply(a.Money, uint32(n))
+		if err != nil {
+			return false
+		}
+		sum := usd(0, 0)
+		for i := 0; i < int(n); i++ {
+			if sum, err = Sum(sum, a.Money); err != nil {
+				return false
+			}
+		}
+		return AreEquals(product, sum)
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		l, r *testpb.Money
+		want int
+	}{
+		{usd(1, 0), usd(1, 0), 0},
+		{usd(0, 0), usd(0, 0), 0},
+		{usd(1, 0), usd(2, 0), -1},
+		{usd(2, 0), usd(1, 999999999), 1},
+		{usd(1, 1), usd(1, 0), 1},
+		// 负数
+		{usd(-1, 0), usd(1, 0), -1},
+		{usd(0, -1), usd(0, 0), -1},
+		{usd(0, -1), usd(0, 1), -1},
+		{usd(-1, -500000000), usd(-1, -400000000), -1},
+		{usd(-1, 0), usd(0, -999999999), -1},
+		{usd(-2, 0), usd(-3, 0), 1},
+		{usd(9223372036854775807, 999999999), usd(-9223372036854775808, -999999999), 1},
+	}
+	for _, tt := range tests {
+		got, err := Compare(tt.l, tt.r)
+		if err != nil || got != tt.want {
+			t.Errorf("Compare(%v, %v) = %d, %v，期望 %d", tt.l, tt.r, got, err, tt.want)
+		}
+		// 交换后结果相反
+		if got, err := Compare(tt.r, tt.l); err != nil || got != -tt.want {
+			t.Errorf("Compare(%v, %v) = %d, %v，期望 %d", tt.r, tt.l, got, err, -tt.want)
+		}
+	}
+}
+
+func TestCompareError(t *testing.T) {
+	eur := &testpb.Money{CurrencyCode: "EUR", Units: 1}
+	tests := []struct {
+		l, r *testpb.Money
+		err  error
+	}{
+		{usd(1, 0), eur, ErrMismatchingCurrency},
+		{eur, usd(1, 0), ErrMismatchingCurrency},
+		{usd(1, -1), usd(1, 0), ErrInvalidValue},
+		{usd(1, 0), usd(-1, 1), ErrInvalidValue},
+	}
+	for _, tt := range tests {
+		if _, err := Compare(tt.l, tt.r); err != tt.err {
+			t.Errorf("Compare(%v, %v) 返回 %v，期望 %v", tt.l, tt.r, err, tt.err)
+		}
+	}
+}
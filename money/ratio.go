@@ -0,0 +1,108 @@
+package money
+
+import (
+	"errors"
+	"math/big"
+)
+
+var (
+	ErrInvalidRatio = errors.New("比例无效")
+	ErrInvalidParts = errors.New("分配份数必须大于0")
+)
+
+// 舍入方式
+type RoundingMode int
+
+const (
+	// 四舍五入，0.5向远离0的方向舍入
+	RoundHalfUp RoundingMode = iota
+	// 银行家舍入，0.5舍入到偶数
+	RoundHalfEven
+)
+
+// 货币最小单位对应的nanos，例如美元的分为10000000，日元为1000000000
+func minorUnitNanos(currencyCode string) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(9-MinorUnits(currencyCode))), nil)
+}
+
+// 乘以num/den，结果按mode舍入到货币的最小单位
+func MultiplyByRatio[M Money](m M, num, den int64, mode RoundingMode) (M, error) {
+	if den == 0 {
+		return newMoney(m, "", 0, 0), ErrInvalidRatio
+	}
+	return MultiplyByRat(m, big.NewRat(num, den), mode)
+}
+
+// 乘以有理数r，精确计算后按mode舍入到货币的最小单位，负数按绝对值舍入
+func MultiplyByRat[M Money](m M, r *big.Rat, mode RoundingMode) (M, error) {
+	if !IsValid(m) {
+		return newMoney(m, "", 0, 0), ErrInvalidValue
+	}
+	if r == nil {
+		return newMoney(m, "", 0, 0), ErrInvalidRatio
+	}
+	return FromRat(m, m.GetCurrencyCode(), new(big.Rat).Mul(ToRat(m), r), mode)
+}
+
+// 金额转换成有理数，单位为元，用于精确计算多个金额的比例之和
+func ToRat(m Money) *big.Rat {
+	return new(big.Rat).SetFrac(toNanos(m), big.NewInt(nanosMod))
+}
+
+// 有理数转换成金额，r的单位为元，按mode舍入到currency的最小单位，负数按绝对值舍入
+func FromRat[M Money](like M, currency string, r *big.Rat, mode RoundingMode) (M, error) {
+	unit := minorUnitNanos(currency)
+	// r * nanosMod / unit 舍入后是最小单位的个数
+	x := new(big.Int).Mul(r.Num(), big.NewInt(nanosMod))
+	y := new(big.Int).Mul(r.Denom(), unit)
+	count := divRound(x, y, mode)
+	return fromNanos(like, currency, count.Mul(count, unit))
+}
+
+// x/y按mode舍入，y必须大于0
+func divRound(x, y *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(x, y, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	// 余数的两倍和除数比较，判断是否超过一半
+	half := new(big.Int).Abs(r)
+	half.Lsh(half, 1)
+	c := half.Cmp(y)
+	if c > 0 || c == 0 && (mode == RoundHalfUp || q.Bit(0) == 1) {
+		q.Add(q, big.NewInt(int64(r.Sign())))
+	}
+	return q
+}
+
+// 把金额平均分成n份，各份之和等于原金额。
+// 金额是货币最小单位的整数倍时按最小单位分配，例如美元按分、日元按元，否则按nanos分配，
+// 除不尽的部分从第一份开始每份多分一个单位
+func Allocate[M Money](m M, n int) ([]M, error) {
+	if !IsValid(m) {
+		return nil, ErrInvalidValue
+	}
+	if n <= 0 {
+		return nil, ErrInvalidParts
+	}
+	count := toNanos(m)
+	unit := minorUnitNanos(m.GetCurrencyCode())
+	if new(big.Int).Rem(count, unit).Sign() != 0 {
+		unit = big.NewInt(1)
+	}
+	count.Quo(count, unit)
+	q, r := new(big.Int).QuoRem(count, big.NewInt(int64(n)), new(big.Int))
+	extra := int(new(big.Int).Abs(r).Int64())
+	step := big.NewInt(int64(r.Sign()))
+
+	out := make([]M, n)
+	for i := range out {
+		part := new(big.Int).Set(q)
+		if i < extra {
+			part.Add(part, step)
+		}
+		// 每份不会超过原金额，不会溢出
+		out[i], _ = fromNanos(m, m.GetCurrencyCode(), part.Mul(part, unit))
+	}
+	return out, nil
+}
@@ -0,0 +1,124 @@
+package money
+
+import (
+	"math/big"
+	"testing"
+	"testing/quick"
+
+	"money/internal/testpb"
+)
+
+func TestMultiplyByRatioRounding(t *testing.T) {
+	tests := []struct {
+		m        *testpb.Money
+		num, den int64
+		mode     RoundingMode
+		want     *testpb.Money
+	}{
+		// 0.025 舍入到分
+		{usd(0, 25000000), 1, 1, RoundHalfUp, usd(0, 30000000)},
+		{usd(0, 25000000), 1, 1, RoundHalfEven, usd(0, 20000000)},
+		{usd(0, 35000000), 1, 1, RoundHalfEven, usd(0, 40000000)},
+		// 负数按绝对值舍入
+		{usd(0, -25000000), 1, 1, RoundHalfUp, usd(0, -30000000)},
+		{usd(0, -25000000), 1, 1, RoundHalfEven, usd(0, -20000000)},
+		// 不到一半时两种方式都舍去
+		{usd(0, 24999999), 1, 1, RoundHalfUp, usd(0, 20000000)},
+		// 9.99的15%为1.4985
+		{usd(9, 990000000), 15, 100, RoundHalfUp, usd(1, 500000000)},
+		{usd(10, 0), 1, 3, RoundHalfEven, usd(3, 330000000)},
+		{usd(10, 0), 2, 3, RoundHalfEven, usd(6, 670000000)},
+		// 日元没有小数
+		{&testpb.Money{CurrencyCode: "JPY", Units: 1001}, 1, 2, RoundHalfEven, &testpb.Money{CurrencyCode: "JPY", Units: 500}},
+		{&testpb.Money{CurrencyCode: "JPY", Units: 1003}, 1, 2, RoundHalfEven, &testpb.Money{CurrencyCode: "JPY", Units: 502}},
+		// 科威特第纳尔有3位小数
+		{&testpb.Money{CurrencyCode: "KWD", Units: 1}, 1, 3, RoundHalfUp, &testpb.Money{CurrencyCode: "KWD", Nanos: 333000000}},
+	}
+	for _, tt := range tests {
+		got, err := MultiplyByRatio(tt.m, tt.num, tt.den, tt.mode)
+		if err != nil {
+			t.Fatalf("MultiplyByRatio(%v, %d/%d): %v", tt.m, tt.num, tt.den, err)
+		}
+		if !AreEquals(got, tt.want) {
+			t.Errorf("MultiplyByRatio(%v, %d/%d, %d) = %v，期望 %v", tt.m, tt.num, tt.den, tt.mode, got, tt.want)
+		}
+	}
+	if _, err := MultiplyByRatio(usd(1, 0), 1, 0, RoundHalfUp); err != ErrInvalidRatio {
+		t.Fatalf("分母为0时返回 %v，期望 %v", err, ErrInvalidRatio)
+	}
+}
+
+// 舍入后的结果和精确值相差不超过半个最小单位，并且是最小单位的整数倍
+func TestMultiplyByRatioRoundingError(t *testing.T) {
+	cent := big.NewRat(1, 100)
+	halfCent := big.NewRat(1, 200)
+	for _, mode := range []RoundingMode{RoundHalfUp, RoundHalfEven} {
+		mode := mode
+		f := func(m validMoney, num int16, den uint8) bool {
+			d := int64(den) + 1
+			got, err := MultiplyByRatio(m.Money, int64(num), d, mode)
+			if err != nil {
+				return false
+			}
+			exact := new(big.Rat).Mul(ToRat(m.Money), big.NewRat(int64(num), d))
+			diff := new(big.Rat).Sub(ToRat(got), exact)
+			return diff.Abs(diff).Cmp(halfCent) <= 0 && new(big.Rat).Quo(ToRat(got), cent).IsInt()
+		}
+		if err := quick.Check(f, nil); err != nil {
+			t.Fatalf("mode %d: %v", mode, err)
+		}
+	}
+}
+
+func TestAllocate(t *testing.T) {
+	jpy := func(units int64) *testpb.Money { return &testpb.Money{CurrencyCode: "JPY", Units: units} }
+	tests := []struct {
+		m    *testpb.Money
+		n    int
+		want []*testpb.Money
+	}{
+		{usd(10, 0), 3, []*testpb.Money{usd(3, 340000000), usd(3, 330000000), usd(3, 330000000)}},
+		{usd(-10, 0), 3, []*testpb.Money{usd(-3, -340000000), usd(-3, -330000000), usd(-3, -330000000)}},
+		{jpy(10), 3, []*testpb.Money{jpy(4), jpy(3), jpy(3)}},
+		{jpy(2), 3, []*testpb.Money{jpy(1), jpy(1), jpy(0)}},
+		// 不是整分时按nanos分配
+		{usd(0, 5), 2, []*testpb.Money{usd(0, 3), usd(0, 2)}},
+	}
+	for _, tt := range tests {
+		got, err := Allocate(tt.m, tt.n)
+		if err != nil {
+			t.Fatalf("Allocate(%v, %d): %v", tt.m, tt.n, err)
+		}
+		for i := range tt.want {
+			if !AreEquals(got[i], tt.want[i]) {
+				t.Errorf("Allocate(%v, %d) = %v，期望 %v", tt.m, tt.n, got, tt.want)
+				break
+			}
+		}
+	}
+	if _, err := Allocate(usd(1, 0), 0); err != ErrInvalidParts {
+		t.Fatalf("分成0份时返回 %v，期望 %v", err, ErrInvalidParts)
+	}
+}
+
+// 各份之和等于原金额，各份之间最多相差一个单位
+func TestAllocateSum(t *testing.T) {
+	f := func(m validMoney, parts uint8) bool {
+		n := int(parts%20) + 1
+		out, err := Allocate(m.Money, n)
+		if err != nil || len(out) != n {
+			return false
+		}
+		sum := usd(0, 0)
+		for _, p := range out {
+			if sum, err = Sum(sum, p); err != nil {
+				return false
+			}
+		}
+		first, last := toNanos(out[0]), toNanos(out[n-1])
+		return AreEquals(sum, m.Money) && new(big.Int).Sub(first, last).CmpAbs(big.NewInt(nanosMod/100)) <= 0
+	}
+	if err := quick.Check(f, nil); err != nil {
+		t.Fatal(err)
+	}
+}
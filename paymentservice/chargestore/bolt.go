@@ -0,0 +1,90 @@
+package chargestore
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/protobuf/proto"
+
+	pb "paymentservice/proto"
+)
+
+var (
+	// 保存付款的bucket，key为transaction_id，value为8字节的过期时间加序列化后的金额
+	chargesBucket = []byte("charges")
+	// 付款按过期时间排序的索引，key为过期时间加transaction_id，用来删除过期的付款
+	expiresBucket = []byte("charge_expires")
+)
+
+// 数据保存在本地bolt数据库中的结构体，重启后仍然可以退款
+type boltChargeStore struct {
+	db *bolt.DB
+}
+
+// 保存付款，再从索引开头删除过期的付款
+func (s *boltChargeStore) SaveCharge(ctx context.Context, transactionID string, amount *pb.Money, expires time.Time) error {
+	data, err := proto.Marshal(amount)
+	if err != nil {
+		return err
+	}
+	id := []byte(transactionID)
+	return s.db.Update(func(tx *bolt.Tx) error {
+		charges := tx.Bucket(chargesBucket)
+		index := tx.Bucket(expiresBucket)
+		if err := charges.Put(id, append(timeKey(expires, nil), data...)); err != nil {
+			return err
+		}
+		if err := index.Put(timeKey(expires, id), id); err != nil {
+			return err
+		}
+		now := timeKey(time.Now(), nil)
+		c := index.Cursor()
+		for k, v := c.First(); k != nil && bytes.Compare(k, now) < 0; k, v = c.First() {
+			if err := charges.Delete(v); err != nil {
+				return err
+			}
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// 删除并返回付款
+func (s *boltChargeStore) TakeCharge(ctx context.Context, transactionID string, now time.Time) (*pb.Money, error) {
+	out := new(pb.Money)
+	id := []byte(transactionID)
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		charges := tx.Bucket(chargesBucket)
+		v := charges.Get(id)
+		if len(v) < 8 {
+			return ErrChargeNotFound
+		}
+		expires := int64(binary.BigEndian.Uint64(v))
+		if now.UnixNano() > expires {
+			return ErrChargeNotFound
+		}
+		if err := proto.Unmarshal(v[8:], out); err != nil {
+			return err
+		}
+		if err := tx.Bucket(expiresBucket).Delete(append(v[:8:8], id...)); err != nil {
+			return err
+		}
+		return charges.Delete(id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// 过期索引的key，8字节的时间加id
+func timeKey(t time.Time, id []byte) []byte {
+	key := make([]byte, 8, 8+len(id))
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return append(key, id...)
+}
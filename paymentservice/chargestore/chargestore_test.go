@@ -0,0 +1,103 @@
+package chargestore
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "paymentservice/proto"
+)
+
+// 被测试的存储，open每次返回一个空的存储
+var chargeStores = []struct {
+	name string
+	open func(t *testing.T) ChargeStore
+}{
+	{"memory", func(t *testing.T) ChargeStore { return NewMemoryChargeStore() }},
+	{"bolt", func(t *testing.T) ChargeStore {
+		s, err := NewBoltChargeStore(filepath.Join(t.TempDir(), "charges.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { s.(*boltChargeStore).db.Close() })
+		return s
+	}},
+}
+
+func TestChargeStore(t *testing.T) {
+	ctx := context.Background()
+	amount := &pb.Money{CurrencyCode: "USD", Units: 12, Nanos: 500000000}
+	for _, tt := range chargeStores {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			s := tt.open(t)
+			now := time.Now()
+			if err := s.SaveCharge(ctx, "t1", amount, now.Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			got, err := s.TakeCharge(ctx, "t1", now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(got, amount) {
+				t.Fatalf("付款金额为 %v，期望 %v", got, amount)
+			}
+			// 只能退款一次
+			if _, err := s.TakeCharge(ctx, "t1", now); err != ErrChargeNotFound {
+				t.Fatalf("第二次退款返回 %v", err)
+			}
+			if _, err := s.TakeCharge(ctx, "t9", now); err != ErrChargeNotFound {
+				t.Fatalf("不存在的付款返回 %v", err)
+			}
+
+			// 超过退款期限
+			if err := s.SaveCharge(ctx, "t2", amount, now.Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.TakeCharge(ctx, "t2", now.Add(2*time.Hour)); err != ErrChargeNotFound {
+				t.Fatalf("过期的付款返回 %v", err)
+			}
+
+			// 保存新的付款时删除已经过期的付款
+			if err := s.SaveCharge(ctx, "t3", amount, now.Add(-time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.SaveCharge(ctx, "t4", amount, now.Add(time.Hour)); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.TakeCharge(ctx, "t3", now.Add(-time.Hour)); err != ErrChargeNotFound {
+				t.Fatalf("过期的付款没有删除: %v", err)
+			}
+			if _, err := s.TakeCharge(ctx, "t4", now); err != nil {
+				t.Fatalf("未过期的付款被删除: %v", err)
+			}
+		})
+	}
+}
+
+func TestBoltChargeStoreReopen(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "charges.db")
+	s, err := NewBoltChargeStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveCharge(ctx, "t1", &pb.Money{CurrencyCode: "USD", Units: 1}, time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.(*boltChargeStore).db.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err = NewBoltChargeStore(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.(*boltChargeStore).db.Close()
+	if _, err := s.TakeCharge(ctx, "t1", time.Now()); err != nil {
+		t.Fatalf("重启后不能退款: %v", err)
+	}
+}
@@ -0,0 +1,48 @@
+package chargestore
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	pb "paymentservice/proto"
+)
+
+// 付款不存在、已经退款或者已经过了退款期限
+var ErrChargeNotFound = errors.New("付款不存在或已经退款")
+
+// 可以退款的付款的存储接口
+type ChargeStore interface {
+	// 保存付款，expires之后不能再退款，同时删除已经过期的付款
+	SaveCharge(ctx context.Context, transactionID string, amount *pb.Money, expires time.Time) error
+	// 删除付款并返回付款金额，不存在或者在now之前过期时返回ErrChargeNotFound
+	TakeCharge(ctx context.Context, transactionID string, now time.Time) (*pb.Money, error)
+}
+
+// 实例化ChargeStore，重启后付款会丢失
+func NewMemoryChargeStore() ChargeStore {
+	return &memoryChargeStore{charges: make(map[string]memoryCharge)}
+}
+
+// 实例化基于bolt文件的ChargeStore，path为数据库文件路径
+func NewBoltChargeStore(path string) (ChargeStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{chargesBucket, expiresBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltChargeStore{db: db}, nil
+}
@@ -0,0 +1,50 @@
+package chargestore
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "paymentservice/proto"
+)
+
+// 数据保存在内存中的结构体
+type memoryChargeStore struct {
+	sync.Mutex
+	// key为transaction_id
+	charges map[string]memoryCharge
+}
+
+// 一笔付款
+type memoryCharge struct {
+	amount  *pb.Money
+	expires time.Time
+}
+
+// 保存付款，顺便删除过期的付款
+func (s *memoryChargeStore) SaveCharge(ctx context.Context, transactionID string, amount *pb.Money, expires time.Time) error {
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	for id, c := range s.charges {
+		if now.After(c.expires) {
+			delete(s.charges, id)
+		}
+	}
+	s.charges[transactionID] = memoryCharge{amount: proto.Clone(amount).(*pb.Money), expires: expires}
+	return nil
+}
+
+// 删除并返回付款
+func (s *memoryChargeStore) TakeCharge(ctx context.Context, transactionID string, now time.Time) (*pb.Money, error) {
+	s.Lock()
+	defer s.Unlock()
+	c, ok := s.charges[transactionID]
+	if !ok || now.After(c.expires) {
+		return nil, ErrChargeNotFound
+	}
+	delete(s.charges, transactionID)
+	return c.amount, nil
+}
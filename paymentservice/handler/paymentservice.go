@@ -5,12 +5,14 @@ import (
 	"context"
 	"log"
 	"strconv"
+	"time"
 
 	creditcard "github.com/durango/go-credit-card"
 	"github.com/google/uuid"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 
+	"paymentservice/chargestore"
 	pb "paymentservice/proto"
 )
 
@@ -20,7 +22,12 @@ var (
 	logger = log.New(&buf, "logger: ", log.Lshortfile)
 )
 
-type PaymentService struct{}
+type PaymentService struct {
+	// 可以退款的付款，退款或过期后删除
+	Charges chargestore.ChargeStore
+	// 付款后多久内可以退款
+	RefundWindow time.Duration
+}
 
 // 结算
 func (s *PaymentService) Charge(ctx context.Context, in *pb.ChargeRequest) (out *pb.ChargeResponse, e error) {
@@ -32,11 +39,32 @@ func (s *PaymentService) Charge(ctx context.Context, in *pb.ChargeRequest) (out
 	}
 	out = new(pb.ChargeResponse)
 	if err := card.Validate(); err != nil {
-		return out, status.Errorf(codes.InvalidArgument, err.Error())
+		return out, status.Errorf(codes.InvalidArgument, "%v", err)
 	}
 
 	logger.Printf(`事务处理: %s, Amount: %s%d.%d`, in.CreditCard.CreditCardNumber, in.Amount.CurrencyCode, in.Amount.Units, in.Amount.Nanos)
 
 	out.TransactionId = uuid.NewString()
+	// 付款已经完成，保存失败时只能记录日志，这笔付款不能再退款
+	if err := s.Charges.SaveCharge(ctx, out.TransactionId, in.Amount, time.Now().Add(s.RefundWindow)); err != nil {
+		logger.Printf("保存付款失败 transaction_id=%s: %v", out.TransactionId, err)
+	}
+	return out, nil
+}
+
+// 退款
+func (s *PaymentService) Refund(ctx context.Context, in *pb.RefundRequest) (out *pb.RefundResponse, e error) {
+	amount, err := s.Charges.TakeCharge(ctx, in.TransactionId, time.Now())
+	if err == chargestore.ErrChargeNotFound {
+		return nil, status.Errorf(codes.NotFound, "付款不存在、已经退款或者超过退款期限: %s", in.TransactionId)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "查询付款失败: %v", err)
+	}
+
+	logger.Printf(`退款: %s, Amount: %s%d.%d`, in.TransactionId, amount.CurrencyCode, amount.Units, amount.Nanos)
+
+	out = new(pb.RefundResponse)
+	out.RefundId = uuid.NewString()
 	return out, nil
 }
@@ -0,0 +1,47 @@
+package handler
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"paymentservice/chargestore"
+	pb "paymentservice/proto"
+)
+
+func TestChargeRefund(t *testing.T) {
+	ctx := context.Background()
+	s := &PaymentService{Charges: chargestore.NewMemoryChargeStore(), RefundWindow: time.Hour}
+	charge, err := s.Charge(ctx, &pb.ChargeRequest{
+		Amount: &pb.Money{CurrencyCode: "USD", Units: 10},
+		CreditCard: &pb.CreditCardInfo{
+			CreditCardNumber:          "4432801561520454",
+			CreditCardCvv:             672,
+			CreditCardExpirationYear:  int32(time.Now().Year() + 1),
+			CreditCardExpirationMonth: 1,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Refund(ctx, &pb.RefundRequest{TransactionId: charge.GetTransactionId()}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Refund(ctx, &pb.RefundRequest{TransactionId: charge.GetTransactionId()}); status.Code(err) != codes.NotFound {
+		t.Fatalf("重复退款返回 %v", err)
+	}
+}
+
+func TestChargeInvalidCard(t *testing.T) {
+	s := &PaymentService{Charges: chargestore.NewMemoryChargeStore(), RefundWindow: time.Hour}
+	_, err := s.Charge(context.Background(), &pb.ChargeRequest{
+		Amount:     &pb.Money{CurrencyCode: "USD", Units: 10},
+		CreditCard: &pb.CreditCardInfo{CreditCardNumber: "1234"},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("无效的卡号返回 %v", err)
+	}
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"net"
+	"paymentservice/chargestore"
 	handler "paymentservice/handler"
 	pb "paymentservice/proto"
 	"strconv"
+	"time"
 
 	"github.com/hashicorp/consul/api"
 	"google.golang.org/grpc"
@@ -14,8 +17,35 @@ import (
 const PORT = 50014
 const ADDRESS = "127.0.0.1"
 
+// 付款存储方式：memory 内存，bolt 本地文件
+var (
+	chargeStoreType = flag.String("charge-store", "bolt", "付款存储方式: memory 或 bolt")
+	chargeDBPath    = flag.String("charge-db", "charges.db", "bolt数据库文件路径")
+	refundWindow    = flag.Duration("refund-window", 24*time.Hour, "付款后可以退款的时间，过期的付款会被删除")
+)
+
+// 根据启动参数创建付款存储
+func newChargeStore() (chargestore.ChargeStore, error) {
+	switch *chargeStoreType {
+	case "memory":
+		return chargestore.NewMemoryChargeStore(), nil
+	case "bolt":
+		return chargestore.NewBoltChargeStore(*chargeDBPath)
+	default:
+		return nil, fmt.Errorf("不支持的存储方式: %s", *chargeStoreType)
+	}
+}
+
 func main() {
+	flag.Parse()
 	ipport := ADDRESS + ":" + strconv.Itoa(PORT)
+
+	// 初始化付款存储
+	charges, err_store := newChargeStore()
+	if err_store != nil {
+		fmt.Println("初始化付款存储报错：", err_store)
+		return
+	}
 	// ----------注册到consul上-------------
 	// 初始化consul配置
 	consulConfig := api.DefaultConfig()
@@ -47,7 +77,7 @@ func main() {
 	grpcServer := grpc.NewServer()
 
 	// 注册服务
-	pb.RegisterPaymentServiceServer(grpcServer, new(handler.PaymentService))
+	pb.RegisterPaymentServiceServer(grpcServer, &handler.PaymentService{Charges: charges, RefundWindow: *refundWindow})
 
 	// 设置监听
 	listien, err := net.Listen("tcp", ipport)
@@ -0,0 +1,63 @@
+package catalogstore
+
+import (
+	"context"
+	"encoding/binary"
+
+	bolt "go.etcd.io/bbolt"
+	"google.golang.org/protobuf/proto"
+
+	pb "productcatalogservice/proto"
+)
+
+// 保存商品的bucket，key为商品的位置，value为序列化后的商品
+var productsBucket = []byte("products")
+
+// 数据保存在本地bolt数据库中的结构体
+type boltCatalogStore struct {
+	db *bolt.DB
+}
+
+// 按位置顺序读取所有商品
+func (s *boltCatalogStore) Load(ctx context.Context) ([]*pb.Product, error) {
+	var out []*pb.Product
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(productsBucket).ForEach(func(k, v []byte) error {
+			p := new(pb.Product)
+			if err := proto.Unmarshal(v, p); err != nil {
+				return err
+			}
+			out = append(out, p)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// 在一个事务中删除原来的商品并写入新的商品
+func (s *boltCatalogStore) Save(ctx context.Context, products []*pb.Product) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(productsBucket); err != nil {
+			return err
+		}
+		b, err := tx.CreateBucket(productsBucket)
+		if err != nil {
+			return err
+		}
+		for i, p := range products {
+			data, err := proto.Marshal(p)
+			if err != nil {
+				return err
+			}
+			key := make([]byte, 8)
+			binary.BigEndian.PutUint64(key, uint64(i))
+			if err := b.Put(key, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
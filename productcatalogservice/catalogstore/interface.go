@@ -0,0 +1,46 @@
+package catalogstore
+
+import (
+	"context"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	pb "productcatalogservice/proto"
+)
+
+// 商品目录存储接口
+type CatalogStore interface {
+	// 读取所有商品，保持保存时的顺序
+	Load(ctx context.Context) ([]*pb.Product, error)
+	// 用products替换所有商品，要么全部保存成功，要么原来的商品不变
+	Save(ctx context.Context, products []*pb.Product) error
+}
+
+// 可以判断数据是否被其他程序修改的存储，监听商品文件时用来跳过自己保存引起的变化
+type ModifiedChecker interface {
+	// 数据和最后一次读取或保存时不同时返回true
+	Modified() (bool, error)
+}
+
+// 实例化基于json文件的CatalogStore，文件格式和data/products.json相同
+func NewJSONCatalogStore(path string) CatalogStore {
+	return &jsonCatalogStore{path: path}
+}
+
+// 实例化基于bolt文件的CatalogStore，path为数据库文件路径
+func NewBoltCatalogStore(path string) (CatalogStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(productsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &boltCatalogStore{db: db}, nil
+}
@@ -0,0 +1,94 @@
+package catalogstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"google.golang.org/protobuf/encoding/protojson"
+
+	pb "productcatalogservice/proto"
+)
+
+// 数据保存在json文件中的结构体，可以直接编辑文件
+type jsonCatalogStore struct {
+	path string
+	mu   sync.Mutex
+	// 最后一次读取或保存的文件内容的摘要
+	sum [sha256.Size]byte
+}
+
+// 读取商品文件
+func (s *jsonCatalogStore) Load(ctx context.Context) ([]*pb.Product, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, err
+	}
+	catalog := &pb.ListProductsResponse{}
+	if err := protojson.Unmarshal(data, catalog); err != nil {
+		return nil, err
+	}
+	s.setSum(data)
+	return catalog.GetProducts(), nil
+}
+
+// 先写到同一目录下的临时文件，再重命名为商品文件，读取的一方不会读到写了一半的文件
+func (s *jsonCatalogStore) Save(ctx context.Context, products []*pb.Product) error {
+	data, err := protojson.Marshal(&pb.ListProductsResponse{Products: products})
+	if err != nil {
+		return err
+	}
+	// protojson的输出格式不固定，统一缩进成4个空格。
+	// 保存后的文件和手工编辑的文件格式不完全相同：int64的units按protojson的规则写成字符串，数组每个元素占一行，Load都可以读取
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, data, "", "    "); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), "."+filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), s.path); err != nil {
+		return err
+	}
+	s.setSum(buf.Bytes())
+	return nil
+}
+
+// 读取商品文件，和最后一次读取或保存的内容比较
+func (s *jsonCatalogStore) Modified() (bool, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return false, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return sha256.Sum256(data) != s.sum, nil
+}
+
+func (s *jsonCatalogStore) setSum(data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sum = sha256.Sum256(data)
+}
@@ -0,0 +1,72 @@
+package catalogstore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"google.golang.org/protobuf/proto"
+
+	pb "productcatalogservice/proto"
+)
+
+func TestJSONCatalogStoreSave(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "products.json")
+	s := NewJSONCatalogStore(path)
+	products := []*pb.Product{{
+		Id:         "OLJCESPC7Z",
+		Name:       "太阳镜",
+		Picture:    "/static/img/products/sunglasses.jpg",
+		PriceUsd:   &pb.Money{CurrencyCode: "USD", Units: 19, Nanos: 990000000},
+		Categories: []string{"accessories"},
+	}}
+	if err := s.Save(ctx, products); err != nil {
+		t.Fatal(err)
+	}
+	got, err := NewJSONCatalogStore(path).Load(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || !proto.Equal(got[0], products[0]) {
+		t.Fatalf("读取的商品为 %v，期望 %v", got, products)
+	}
+	// 临时文件重命名后不留在目录中
+	entries, err := os.ReadDir(filepath.Dir(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("保存后目录中有%d个文件", len(entries))
+	}
+}
+
+func TestJSONCatalogStoreModified(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "products.json")
+	s := NewJSONCatalogStore(path).(ModifiedChecker)
+	products := []*pb.Product{{Id: "P1", Name: "商品", PriceUsd: &pb.Money{CurrencyCode: "USD", Units: 1}}}
+	if err := s.(CatalogStore).Save(ctx, products); err != nil {
+		t.Fatal(err)
+	}
+	if modified, err := s.Modified(); err != nil || modified {
+		t.Fatalf("保存后 Modified 返回 %v, %v", modified, err)
+	}
+
+	// 其他程序修改文件
+	other := NewJSONCatalogStore(path)
+	products[0].Name = "新商品"
+	if err := other.Save(ctx, products); err != nil {
+		t.Fatal(err)
+	}
+	if modified, err := s.Modified(); err != nil || !modified {
+		t.Fatalf("文件被修改后 Modified 返回 %v, %v", modified, err)
+	}
+	if _, err := s.(CatalogStore).Load(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if modified, err := s.Modified(); err != nil || modified {
+		t.Fatalf("重新读取后 Modified 返回 %v, %v", modified, err)
+	}
+}
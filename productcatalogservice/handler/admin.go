@@ -0,0 +1,178 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"log"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	pb "productcatalogservice/proto"
+)
+
+// 自动生成的商品id的字符和长度，和商品文件中的id格式相同
+const (
+	productIDChars  = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	productIDLength = 10
+)
+
+// 商品管理服务结构体，修改的是Catalog的商品目录
+type ProductCatalogAdminService struct {
+	Catalog *ProductCatalogService
+}
+
+// 添加商品
+func (s *ProductCatalogAdminService) CreateProduct(ctx context.Context, in *pb.CreateProductRequest) (out *pb.Product, e error) {
+	p := cloneProduct(in.GetProduct())
+	err := s.Catalog.update(ctx, func(products []*pb.Product) ([]*pb.Product, error) {
+		if p.Id == "" {
+			id, err := newProductID(products)
+			if err != nil {
+				return nil, status.Errorf(codes.Internal, "生成商品id失败: %v", err)
+			}
+			p.Id = id
+		} else if indexOfProduct(products, p.Id) >= 0 {
+			return nil, status.Errorf(codes.AlreadyExists, "商品已存在: %s", p.Id)
+		}
+		return append(products, p), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("添加商品 %s", p.Id)
+	return p, nil
+}
+
+// 修改商品
+func (s *ProductCatalogAdminService) UpdateProduct(ctx context.Context, in *pb.UpdateProductRequest) (out *pb.Product, e error) {
+	p := cloneProduct(in.GetProduct())
+	err := s.Catalog.update(ctx, func(products []*pb.Product) ([]*pb.Product, error) {
+		i := indexOfProduct(products, p.Id)
+		if i < 0 {
+			return nil, status.Errorf(codes.NotFound, "商品不存在: %s", p.Id)
+		}
+		products[i] = p
+		return products, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("修改商品 %s", p.Id)
+	return p, nil
+}
+
+// 删除商品
+func (s *ProductCatalogAdminService) DeleteProduct(ctx context.Context, in *pb.DeleteProductRequest) (out *pb.Empty, e error) {
+	err := s.Catalog.update(ctx, func(products []*pb.Product) ([]*pb.Product, error) {
+		i := indexOfProduct(products, in.GetId())
+		if i < 0 {
+			return nil, status.Errorf(codes.NotFound, "商品不存在: %s", in.GetId())
+		}
+		return append(products[:i], products[i+1:]...), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("删除商品 %s", in.GetId())
+	return &pb.Empty{}, nil
+}
+
+// 批量导入商品，所有商品在一次保存中生效
+func (s *ProductCatalogAdminService) BulkImport(ctx context.Context, in *pb.BulkImportRequest) (out *pb.BulkImportResponse, e error) {
+	imported := make([]*pb.Product, len(in.GetProducts()))
+	ids := make(map[string]bool, len(imported))
+	for i, p := range in.GetProducts() {
+		if ids[p.GetId()] {
+			return nil, status.Errorf(codes.InvalidArgument, "商品id重复: %s", p.GetId())
+		}
+		ids[p.GetId()] = true
+		imported[i] = cloneProduct(p)
+	}
+
+	out = new(pb.BulkImportResponse)
+	err := s.Catalog.update(ctx, func(products []*pb.Product) ([]*pb.Product, error) {
+		*out = pb.BulkImportResponse{}
+		if in.GetReplace() {
+			for _, p := range products {
+				if ids[p.GetId()] {
+					out.Updated++
+				} else {
+					out.Deleted++
+				}
+			}
+			out.Created = int32(len(imported)) - out.Updated
+			return imported, nil
+		}
+		for _, p := range imported {
+			if i := indexOfProduct(products, p.GetId()); i >= 0 {
+				products[i] = p
+				out.Updated++
+			} else {
+				products = append(products, p)
+				out.Created++
+			}
+		}
+		return products, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("批量导入商品，添加%d个，修改%d个，删除%d个", out.Created, out.Updated, out.Deleted)
+	return out, nil
+}
+
+// 修改商品目录。change收到当前商品的副本，返回修改后的商品，校验通过并保存成功后才替换商品目录。
+// 快照中的商品可能正在被其他请求读取，change只能替换切片中的元素，不能修改商品本身
+func (s *ProductCatalogService) update(ctx context.Context, change func(products []*pb.Product) ([]*pb.Product, error)) error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	products := append([]*pb.Product(nil), s.catalog().products...)
+	products, err := change(products)
+	if err != nil {
+		return err
+	}
+	if err := validateProducts(products, s.pictureRoot); err != nil {
+		return status.Errorf(codes.InvalidArgument, "商品不合法: %v", err)
+	}
+	if err := s.store.Save(ctx, products); err != nil {
+		return status.Errorf(codes.Internal, "保存商品失败: %v", err)
+	}
+	s.current.Store(newCatalog(products))
+	return nil
+}
+
+// 复制请求中的商品，保存到商品目录后不受请求对象的影响
+func cloneProduct(p *pb.Product) *pb.Product {
+	if p == nil {
+		return new(pb.Product)
+	}
+	return proto.Clone(p).(*pb.Product)
+}
+
+// 商品的位置，不存在时返回-1
+func indexOfProduct(products []*pb.Product, id string) int {
+	for i, p := range products {
+		if p.GetId() == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// 生成不和已有商品重复的随机id
+func newProductID(products []*pb.Product) (string, error) {
+	for {
+		b := make([]byte, productIDLength)
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		for i := range b {
+			b[i] = productIDChars[int(b[i])%len(productIDChars)]
+		}
+		if id := string(b); indexOfProduct(products, id) < 0 {
+			return id, nil
+		}
+	}
+}
@@ -0,0 +1,202 @@
+package handler
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"productcatalogservice/catalogstore"
+	pb "productcatalogservice/proto"
+)
+
+// 商品管理服务和商品文件的路径，商品为 P0 到 P4
+func newAdminService(t *testing.T) (*ProductCatalogAdminService, string) {
+	s, path := newWatchedService(t)
+	return &ProductCatalogAdminService{Catalog: s}, path
+}
+
+// 检查当前商品目录和保存的商品文件中的商品id
+func checkCatalog(t *testing.T, admin *ProductCatalogAdminService, path string, want []string) {
+	t.Helper()
+	if got := productIDs(admin.Catalog.catalog().products); !reflect.DeepEqual(got, want) {
+		t.Fatalf("商品目录为 %v，期望 %v", got, want)
+	}
+	saved, err := catalogstore.NewJSONCatalogStore(path).Load(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := productIDs(saved); !reflect.DeepEqual(got, want) {
+		t.Fatalf("保存的商品为 %v，期望 %v", got, want)
+	}
+}
+
+var allIDs = []string{"P0", "P1", "P2", "P3", "P4"}
+
+func TestCreateProduct(t *testing.T) {
+	admin, path := newAdminService(t)
+	ctx := context.Background()
+	in := pricedProduct("P5", 3, 0, "test")
+	p, err := admin.CreateProduct(ctx, &pb.CreateProductRequest{Product: in})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 保存的是请求的副本
+	in.Name = "修改请求"
+	if got, _ := admin.Catalog.GetProduct(ctx, &pb.GetProductRequest{Id: "P5"}); got.GetName() != p.GetName() || got.GetName() == in.Name {
+		t.Fatalf("添加后的商品为 %v", got)
+	}
+
+	// id为空时生成新的id
+	generated, err := admin.CreateProduct(ctx, &pb.CreateProductRequest{Product: pricedProduct("", 1, 0)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(generated.GetId()) != productIDLength {
+		t.Fatalf("生成的商品id为 %q", generated.GetId())
+	}
+	checkCatalog(t, admin, path, append(append([]string(nil), allIDs...), "P5", generated.GetId()))
+}
+
+func TestCreateProductRejected(t *testing.T) {
+	admin, path := newAdminService(t)
+	noName := pricedProduct("P5", 1, 0)
+	noName.Name = ""
+	noPicture := pricedProduct("P5", 1, 0)
+	noPicture.Picture = ""
+	euro := pricedProduct("P5", 1, 0)
+	euro.PriceUsd.CurrencyCode = "EUR"
+	tests := []struct {
+		name    string
+		product *pb.Product
+		code    codes.Code
+	}{
+		{"id已存在", pricedProduct("P1", 1, 0), codes.AlreadyExists},
+		{"没有商品", nil, codes.InvalidArgument},
+		{"没有名称", noName, codes.InvalidArgument},
+		{"没有图片", noPicture, codes.InvalidArgument},
+		{"价格为负数", pricedProduct("P5", -1, 0), codes.InvalidArgument},
+		{"价格不是美元", euro, codes.InvalidArgument},
+		{"价格无效", pricedProduct("P5", 1, -1), codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := admin.CreateProduct(context.Background(), &pb.CreateProductRequest{Product: tt.product}); status.Code(err) != tt.code {
+				t.Fatalf("返回 %v，期望 %v", err, tt.code)
+			}
+			checkCatalog(t, admin, path, allIDs)
+		})
+	}
+}
+
+func TestUpdateProduct(t *testing.T) {
+	admin, path := newAdminService(t)
+	ctx := context.Background()
+	if _, err := admin.UpdateProduct(ctx, &pb.UpdateProductRequest{Product: pricedProduct("P2", 9, 0, "sale")}); err != nil {
+		t.Fatal(err)
+	}
+	p, _ := admin.Catalog.GetProduct(ctx, &pb.GetProductRequest{Id: "P2"})
+	if p.GetPriceUsd().GetUnits() != 9 || !reflect.DeepEqual(p.GetCategories(), []string{"sale"}) {
+		t.Fatalf("修改后的商品为 %v", p)
+	}
+	if list, _ := admin.Catalog.ListProducts(ctx, &pb.ListProductsRequest{Category: "sale"}); len(list.GetProducts()) != 1 {
+		t.Fatalf("修改后分类中有%d个商品，期望1个", len(list.GetProducts()))
+	}
+
+	if _, err := admin.UpdateProduct(ctx, &pb.UpdateProductRequest{Product: pricedProduct("P9", 1, 0)}); status.Code(err) != codes.NotFound {
+		t.Fatalf("修改不存在的商品返回 %v", err)
+	}
+	if _, err := admin.UpdateProduct(ctx, &pb.UpdateProductRequest{Product: pricedProduct("P2", -1, 0)}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("价格为负数时返回 %v", err)
+	}
+	if p, _ := admin.Catalog.GetProduct(ctx, &pb.GetProductRequest{Id: "P2"}); p.GetPriceUsd().GetUnits() != 9 {
+		t.Fatalf("修改失败后的商品为 %v", p)
+	}
+	checkCatalog(t, admin, path, allIDs)
+}
+
+func TestDeleteProduct(t *testing.T) {
+	admin, path := newAdminService(t)
+	ctx := context.Background()
+	if _, err := admin.DeleteProduct(ctx, &pb.DeleteProductRequest{Id: "P1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := admin.Catalog.GetProduct(ctx, &pb.GetProductRequest{Id: "P1"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("删除后查询商品返回 %v", err)
+	}
+	if _, err := admin.DeleteProduct(ctx, &pb.DeleteProductRequest{Id: "P1"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("删除不存在的商品返回 %v", err)
+	}
+	checkCatalog(t, admin, path, []string{"P0", "P2", "P3", "P4"})
+
+	// 不能删除最后一个商品
+	for _, id := range []string{"P0", "P2", "P3"} {
+		if _, err := admin.DeleteProduct(ctx, &pb.DeleteProductRequest{Id: id}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := admin.DeleteProduct(ctx, &pb.DeleteProductRequest{Id: "P4"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("删除最后一个商品返回 %v", err)
+	}
+	checkCatalog(t, admin, path, []string{"P4"})
+}
+
+func TestBulkImport(t *testing.T) {
+	tests := []struct {
+		name     string
+		products []*pb.Product
+		replace  bool
+		want     *pb.BulkImportResponse
+		ids      []string
+	}{
+		{
+			"合并", []*pb.Product{pricedProduct("P1", 2, 0), pricedProduct("P7", 2, 0)}, false,
+			&pb.BulkImportResponse{Created: 1, Updated: 1}, append(append([]string(nil), allIDs...), "P7"),
+		},
+		{
+			"替换", []*pb.Product{pricedProduct("P7", 2, 0), pricedProduct("P1", 2, 0)}, true,
+			&pb.BulkImportResponse{Created: 1, Updated: 1, Deleted: 4}, []string{"P7", "P1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admin, path := newAdminService(t)
+			out, err := admin.BulkImport(context.Background(), &pb.BulkImportRequest{Products: tt.products, Replace: tt.replace})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.GetCreated() != tt.want.Created || out.GetUpdated() != tt.want.Updated || out.GetDeleted() != tt.want.Deleted {
+				t.Fatalf("导入结果为 %v，期望 %v", out, tt.want)
+			}
+			checkCatalog(t, admin, path, tt.ids)
+		})
+	}
+}
+
+// 有一个商品不合法时整个导入失败，商品目录和保存的文件都不变
+func TestBulkImportRejected(t *testing.T) {
+	noName := pricedProduct("P8", 1, 0)
+	noName.Name = ""
+	tests := []struct {
+		name     string
+		products []*pb.Product
+		replace  bool
+	}{
+		{"id重复", []*pb.Product{pricedProduct("P7", 1, 0), pricedProduct("P7", 2, 0)}, false},
+		{"合并时有商品不合法", []*pb.Product{pricedProduct("P7", 1, 0), noName}, false},
+		{"替换时有商品不合法", []*pb.Product{pricedProduct("P7", 1, 0), pricedProduct("P8", -1, 0)}, true},
+		{"替换成空目录", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			admin, path := newAdminService(t)
+			_, err := admin.BulkImport(context.Background(), &pb.BulkImportRequest{Products: tt.products, Replace: tt.replace})
+			if status.Code(err) != codes.InvalidArgument {
+				t.Fatalf("返回 %v，期望 %v", err, codes.InvalidArgument)
+			}
+			checkCatalog(t, admin, path, allIDs)
+		})
+	}
+}
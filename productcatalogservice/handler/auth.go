@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// 商品管理接口的认证，请求的metadata中必须带有 authorization: Bearer <token>
+func AdminAuthInterceptor(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if err := checkAdminToken(ctx, token); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// 检查请求中的token，token为空时拒绝所有请求
+func checkAdminToken(ctx context.Context, token string) error {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return status.Errorf(codes.Unauthenticated, "缺少认证信息")
+	}
+	got := strings.TrimPrefix(values[0], "Bearer ")
+	if token == "" || got == values[0] || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		return status.Errorf(codes.Unauthenticated, "认证失败")
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAdminAuthInterceptor(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		header []string
+		code   codes.Code
+	}{
+		{"token正确", "secret", []string{"Bearer secret"}, codes.OK},
+		{"没有认证信息", "secret", nil, codes.Unauthenticated},
+		{"token错误", "secret", []string{"Bearer wrong"}, codes.Unauthenticated},
+		{"没有Bearer前缀", "secret", []string{"secret"}, codes.Unauthenticated},
+		{"token为空", "secret", []string{"Bearer "}, codes.Unauthenticated},
+		// 没有设置token时拒绝所有请求
+		{"没有设置token", "", []string{"Bearer "}, codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.header != nil {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", tt.header[0]))
+			}
+			called := false
+			handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+				called = true
+				return req, nil
+			}
+			_, err := AdminAuthInterceptor(tt.token)(ctx, "req", &grpc.UnaryServerInfo{FullMethod: "/microshopping.ProductCatalogAdminService/DeleteProduct"}, handler)
+			if status.Code(err) != tt.code || called != (tt.code == codes.OK) {
+				t.Fatalf("返回 %v，调用接口 %v，期望 %v", err, called, tt.code)
+			}
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/collate"
+	"golang.org/x/text/language"
+
+	"money"
+	"productcatalogservice/catalogstore"
+	pb "productcatalogservice/proto"
+)
+
+// 商品目录快照，创建后不再修改，可以在多个请求间共享
+type catalog struct {
+	// 文件中的顺序
+	products []*pb.Product
+	// key为商品id
+	byID map[string]*pb.Product
+	// key为分类，每个分类中的商品保持文件中的顺序
+	byCategory map[string][]*pb.Product
+	// key为排序方式，每种排序方式排好的所有商品
+	sorted map[pb.ProductSort][]*pb.Product
+	// 搜索用的倒排索引
+	index *searchIndex
+}
+
+// 创建商品目录快照，建立id和分类索引
+func newCatalog(products []*pb.Product) *catalog {
+	c := &catalog{
+		products:   products,
+		byID:       make(map[string]*pb.Product, len(products)),
+		byCategory: make(map[string][]*pb.Product),
+	}
+	for _, p := range products {
+		c.byID[p.GetId()] = p
+		for _, category := range p.GetCategories() {
+			c.byCategory[category] = append(c.byCategory[category], p)
+		}
+	}
+
+	byPrice := func(a, b *pb.Product) int {
+		n, _ := money.Compare(a.GetPriceUsd(), b.GetPriceUsd())
+		return n
+	}
+	// 中文名称按拼音排序，Collator不能并发使用，每次加载创建新的
+	col := collate.New(language.Chinese)
+	byName := func(a, b *pb.Product) int {
+		return col.CompareString(a.GetName(), b.GetName())
+	}
+	c.sorted = map[pb.ProductSort][]*pb.Product{
+		pb.ProductSort_CATALOG_ORDER: products,
+		pb.ProductSort_PRICE_ASC:     sortProducts(products, byPrice, 1),
+		pb.ProductSort_PRICE_DESC:    sortProducts(products, byPrice, -1),
+		pb.ProductSort_NAME_ASC:      sortProducts(products, byName, 1),
+		pb.ProductSort_NAME_DESC:     sortProducts(products, byName, -1),
+	}
+	c.index = newSearchIndex(products)
+	return c
+}
+
+// 排序后返回新的切片，dir为-1时降序，相同的商品保持文件中的顺序
+func sortProducts(products []*pb.Product, compare func(a, b *pb.Product) int, dir int) []*pb.Product {
+	sorted := append([]*pb.Product(nil), products...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return compare(sorted[i], sorted[j])*dir < 0
+	})
+	return sorted
+}
+
+// 按排序方式和分类筛选商品，不修改返回的切片
+func (c *catalog) list(order pb.ProductSort, category string) ([]*pb.Product, error) {
+	sorted, ok := c.sorted[order]
+	if !ok {
+		return nil, fmt.Errorf("不支持的排序方式: %v", order)
+	}
+	if category == "" {
+		return sorted, nil
+	}
+	if order == pb.ProductSort_CATALOG_ORDER {
+		return c.byCategory[category], nil
+	}
+	out := make([]*pb.Product, 0, len(c.byCategory[category]))
+	for _, p := range sorted {
+		if hasCategory(p, category) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// 搜索商品并按分类筛选，默认按相关度排序，query为空时和list相同
+func (c *catalog) search(query, category string, order pb.ProductSort) ([]*pb.Product, error) {
+	if strings.TrimSpace(query) == "" {
+		return c.list(order, category)
+	}
+	sorted, ok := c.sorted[order]
+	if !ok {
+		return nil, fmt.Errorf("不支持的排序方式: %v", order)
+	}
+	found := c.index.search(query)
+	if order != pb.ProductSort_CATALOG_ORDER {
+		matched := make(map[*pb.Product]bool, len(found))
+		for _, p := range found {
+			matched[p] = true
+		}
+		found = found[:0]
+		for _, p := range sorted {
+			if matched[p] {
+				found = append(found, p)
+			}
+		}
+	}
+	if category == "" {
+		return found, nil
+	}
+	out := found[:0]
+	for _, p := range found {
+		if hasCategory(p, category) {
+			out = append(out, p)
+		}
+	}
+	return out, nil
+}
+
+// 商品是否属于该分类
+func hasCategory(p *pb.Product, category string) bool {
+	for _, c := range p.GetCategories() {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
+
+// 从存储中读取商品，商品不合法时返回错误
+func loadCatalog(ctx context.Context, store catalogstore.CatalogStore, pictureRoot string) (*catalog, error) {
+	products, err := store.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateProducts(products, pictureRoot); err != nil {
+		return nil, err
+	}
+	return newCatalog(products), nil
+}
+
+// 校验所有商品，商品id不能重复
+func validateProducts(products []*pb.Product, pictureRoot string) error {
+	if len(products) == 0 {
+		return errors.New("没有商品")
+	}
+	ids := make(map[string]bool, len(products))
+	for _, p := range products {
+		if err := validateProduct(p, pictureRoot); err != nil {
+			return err
+		}
+		if ids[p.GetId()] {
+			return fmt.Errorf("商品id重复: %s", p.GetId())
+		}
+		ids[p.GetId()] = true
+	}
+	return nil
+}
+
+// 校验一个商品：id和名称不能为空，价格必须是有效的非负美元金额，pictureRoot不为空时图片文件必须存在
+func validateProduct(p *pb.Product, pictureRoot string) error {
+	if p.GetId() == "" {
+		return fmt.Errorf("商品id不能为空: %q", p.GetName())
+	}
+	if p.GetName() == "" {
+		return fmt.Errorf("商品 %s 的名称不能为空", p.GetId())
+	}
+	price := p.GetPriceUsd()
+	if price.GetCurrencyCode() != "USD" || !money.IsValid(price) || money.IsNegative(price) {
+		return fmt.Errorf("商品 %s 的价格无效: %v", p.GetId(), price)
+	}
+	if p.GetPicture() == "" {
+		return fmt.Errorf("商品 %s 没有图片", p.GetId())
+	}
+	if pictureRoot != "" {
+		if _, err := os.Stat(filepath.Join(pictureRoot, filepath.FromSlash(p.GetPicture()))); err != nil {
+			return fmt.Errorf("商品 %s 的图片不存在: %v", p.GetId(), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	pb "productcatalogservice/proto"
+)
+
+// 排序用的商品，名称按拼音排序为 草莓、橙子、蓝莓、苹果、葡萄、香蕉
+func listProducts() []*pb.Product {
+	products := []*pb.Product{
+		pricedProduct("P0", 3, 0, "fruit"),
+		pricedProduct("P1", 5, 0, "fruit", "berry"),
+		pricedProduct("P2", 1, 0, "fruit"),
+		pricedProduct("P3", 3, 0, "fruit"),
+		pricedProduct("P4", 8, 0, "berry"),
+		pricedProduct("P5", 2, 0, "melon"),
+	}
+	for i, name := range []string{"香蕉", "草莓", "橙子", "苹果", "蓝莓", "葡萄"} {
+		products[i].Name = name
+	}
+	return products
+}
+
+func TestCatalogList(t *testing.T) {
+	c := newCatalog(listProducts())
+	tests := []struct {
+		order    pb.ProductSort
+		category string
+		want     []string
+	}{
+		{pb.ProductSort_CATALOG_ORDER, "", []string{"P0", "P1", "P2", "P3", "P4", "P5"}},
+		// 价格相同时保持文件中的顺序
+		{pb.ProductSort_PRICE_ASC, "", []string{"P2", "P5", "P0", "P3", "P1", "P4"}},
+		{pb.ProductSort_PRICE_DESC, "", []string{"P4", "P1", "P0", "P3", "P5", "P2"}},
+		{pb.ProductSort_NAME_ASC, "", []string{"P1", "P2", "P4", "P3", "P5", "P0"}},
+		{pb.ProductSort_NAME_DESC, "", []string{"P0", "P5", "P3", "P4", "P2", "P1"}},
+		{pb.ProductSort_CATALOG_ORDER, "fruit", []string{"P0", "P1", "P2", "P3"}},
+		{pb.ProductSort_PRICE_ASC, "fruit", []string{"P2", "P0", "P3", "P1"}},
+		{pb.ProductSort_PRICE_DESC, "berry", []string{"P4", "P1"}},
+		{pb.ProductSort_NAME_ASC, "fruit", []string{"P1", "P2", "P3", "P0"}},
+		{pb.ProductSort_NAME_DESC, "melon", []string{"P5"}},
+		{pb.ProductSort_PRICE_ASC, "garden", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%v/%s", tt.order, tt.category), func(t *testing.T) {
+			got, err := c.list(tt.order, tt.category)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := productIDs(got); !reflect.DeepEqual(ids, tt.want) {
+				t.Fatalf("商品为 %v，期望 %v", ids, tt.want)
+			}
+		})
+	}
+	if _, err := c.list(pb.ProductSort(99), ""); err == nil {
+		t.Fatal("不支持的排序方式没有返回错误")
+	}
+}
+
+// n个商品
+func numberedProducts(n int) []*pb.Product {
+	products := make([]*pb.Product, n)
+	for i := range products {
+		products[i] = pricedProduct(fmt.Sprintf("P%d", i), 1, 0)
+	}
+	return products
+}
+
+// 按next_page_token依次取完所有页，返回每页的商品个数和每页返回的token
+func allPages(t *testing.T, products []*pb.Product, pageSize int32) (sizes []int, tokens []string) {
+	var got []*pb.Product
+	token := ""
+	for {
+		page, next, err := paginate(products, pageSize, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, page...)
+		sizes, tokens = append(sizes, len(page)), append(tokens, next)
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	if !reflect.DeepEqual(productIDs(got), productIDs(products)) {
+		t.Fatalf("分页取到的商品为 %v，期望 %v", productIDs(got), productIDs(products))
+	}
+	return sizes, tokens
+}
+
+func TestPaginate(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        int
+		pageSize int32
+		sizes    []int
+		tokens   []string
+	}{
+		{"最后一页不满", 10, 4, []int{4, 4, 2}, []string{"4", "8", ""}},
+		// 最后一页正好取完时下一页的token为空
+		{"正好取完", 8, 4, []int{4, 4}, []string{"4", ""}},
+		{"只有一页", 3, 10, []int{3}, []string{""}},
+		{"没有商品", 0, 10, []int{0}, []string{""}},
+		{"默认每页100个", 150, 0, []int{100, 50}, []string{"100", ""}},
+		{"每页最多100个", 150, 1000, []int{100, 50}, []string{"100", ""}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sizes, tokens := allPages(t, numberedProducts(tt.n), tt.pageSize)
+			if !reflect.DeepEqual(sizes, tt.sizes) || !reflect.DeepEqual(tokens, tt.tokens) {
+				t.Fatalf("每页 %v 个，token为 %q，期望 %v 个，%q", sizes, tokens, tt.sizes, tt.tokens)
+			}
+		})
+	}
+
+	// token指向最后一个商品之后时返回空页
+	if page, next, err := paginate(numberedProducts(5), 2, "5"); err != nil || len(page) != 0 || next != "" {
+		t.Fatalf("token为5时返回 %v, %q, %v", productIDs(page), next, err)
+	}
+}
+
+func TestPaginateInvalid(t *testing.T) {
+	tests := []struct {
+		pageSize int32
+		token    string
+	}{
+		{-1, ""},
+		{10, "abc"},
+		{10, "-1"},
+		{10, "1.5"},
+		{10, "6"},
+	}
+	for _, tt := range tests {
+		if _, _, err := paginate(numberedProducts(5), tt.pageSize, tt.token); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("page_size %d, page_token %q 返回 %v，期望 %v", tt.pageSize, tt.token, err, codes.InvalidArgument)
+		}
+	}
+}
+
+// 按分类筛选和排序后分页
+func TestListProductsCategoryPaging(t *testing.T) {
+	s := newServiceWithProducts(t, listProducts())
+	ctx := context.Background()
+	var pages [][]string
+	token := ""
+	for {
+		out, err := s.ListProducts(ctx, &pb.ListProductsRequest{
+			PageSize:  3,
+			PageToken: token,
+			Sort:      pb.ProductSort_PRICE_ASC,
+			Category:  "fruit",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out.GetTotalCount() != 4 {
+			t.Fatalf("商品总数为%d，期望4", out.GetTotalCount())
+		}
+		pages = append(pages, productIDs(out.GetProducts()))
+		if token = out.GetNextPageToken(); token == "" {
+			break
+		}
+	}
+	if want := [][]string{{"P2", "P0", "P3"}, {"P1"}}; !reflect.DeepEqual(pages, want) {
+		t.Fatalf("分页结果为 %v，期望 %v", pages, want)
+	}
+
+	if _, err := s.ListProducts(ctx, &pb.ListProductsRequest{Category: "fruit", PageToken: "5"}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("超出分类商品个数的token返回 %v", err)
+	}
+	if _, err := s.ListProducts(ctx, &pb.ListProductsRequest{Sort: pb.ProductSort(99)}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("不支持的排序方式返回 %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"sort"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"money"
+	pb "productcatalogservice/proto"
+)
+
+// 按价格筛选，minPrice和maxPrice为nil表示不限制。价格不是美元时，把商品价格转换成该货币后比较，和前端显示的价格一致
+func (s *ProductCatalogService) filterPrice(ctx context.Context, products []*pb.Product, minPrice, maxPrice *pb.Money) ([]*pb.Product, error) {
+	if minPrice == nil && maxPrice == nil {
+		return products, nil
+	}
+	currency := ""
+	for _, m := range []*pb.Money{minPrice, maxPrice} {
+		if m == nil {
+			continue
+		}
+		if m.GetCurrencyCode() == "" || !money.IsValid(m) {
+			return nil, status.Errorf(codes.InvalidArgument, "价格无效: %v", m)
+		}
+		if currency != "" && m.GetCurrencyCode() != currency {
+			return nil, status.Errorf(codes.InvalidArgument, "最低价格和最高价格的货币不同: %s, %s", currency, m.GetCurrencyCode())
+		}
+		currency = m.GetCurrencyCode()
+	}
+	if minPrice != nil && maxPrice != nil {
+		if n, _ := money.Compare(minPrice, maxPrice); n > 0 {
+			return nil, status.Errorf(codes.InvalidArgument, "最低价格不能高于最高价格")
+		}
+	}
+
+	prices, err := s.pricesIn(ctx, products, currency)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*pb.Product, 0, len(products))
+	for i, p := range products {
+		if minPrice != nil {
+			if n, _ := money.Compare(prices[i], minPrice); n < 0 {
+				continue
+			}
+		}
+		if maxPrice != nil {
+			if n, _ := money.Compare(prices[i], maxPrice); n > 0 {
+				continue
+			}
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
+
+// 金额的数值，用来合并相同的价格
+type amount struct {
+	units int64
+	nanos int32
+}
+
+// 商品价格转换成currency，和products一一对应，相同的价格只转换一次，所有价格在一次请求中转换
+func (s *ProductCatalogService) pricesIn(ctx context.Context, products []*pb.Product, currency string) ([]*pb.Money, error) {
+	prices := make([]*pb.Money, len(products))
+	for i, p := range products {
+		prices[i] = p.GetPriceUsd()
+	}
+	if currency == "USD" || len(products) == 0 {
+		return prices, nil
+	}
+	if s.CurrencyService == nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "不支持按%s价格筛选", currency)
+	}
+
+	index := make(map[amount]int)
+	var unique []*pb.Money
+	for _, m := range prices {
+		k := amount{m.GetUnits(), m.GetNanos()}
+		if _, ok := index[k]; !ok {
+			index[k] = len(unique)
+			unique = append(unique, m)
+		}
+	}
+	resp, err := s.CurrencyService.ConvertBatch(ctx, &pb.ConvertBatchRequest{From: unique, ToCode: currency})
+	if status.Code(err) == codes.InvalidArgument {
+		return nil, status.Errorf(codes.InvalidArgument, "不支持的货币: %s", currency)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "货币转换失败: %v", err)
+	}
+	if len(resp.GetMoney()) != len(unique) {
+		return nil, status.Errorf(codes.Internal, "货币转换结果个数不对: %d, %d", len(resp.GetMoney()), len(unique))
+	}
+	for i, m := range prices {
+		prices[i] = resp.GetMoney()[index[amount{m.GetUnits(), m.GetNanos()}]]
+	}
+	return prices, nil
+}
+
+// 统计每个分类的商品个数，按个数从多到少排序，个数相同时按分类名称排序
+func categoryFacets(products []*pb.Product) []*pb.CategoryFacet {
+	counts := make(map[string]int32)
+	for _, p := range products {
+		for _, c := range p.GetCategories() {
+			counts[c]++
+		}
+	}
+	facets := make([]*pb.CategoryFacet, 0, len(counts))
+	for c, n := range counts {
+		facets = append(facets, &pb.CategoryFacet{Category: c, Count: n})
+	}
+	sort.Slice(facets, func(i, j int) bool {
+		if facets[i].Count != facets[j].Count {
+			return facets[i].Count > facets[j].Count
+		}
+		return facets[i].Category < facets[j].Category
+	})
+	return facets
+}
+
+// 只保留属于categories中任一分类的商品，categories为空时不筛选
+func filterCategories(products []*pb.Product, categories []string) []*pb.Product {
+	if len(categories) == 0 {
+		return products
+	}
+	out := make([]*pb.Product, 0, len(products))
+	for _, p := range products {
+		for _, c := range categories {
+			if hasCategory(p, c) {
+				out = append(out, p)
+				break
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,219 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"money"
+	"productcatalogservice/catalogstore"
+	pb "productcatalogservice/proto"
+)
+
+// 美元价格的商品
+func pricedProduct(id string, units int64, nanos int32, categories ...string) *pb.Product {
+	return &pb.Product{
+		Id:         id,
+		Name:       "商品" + id,
+		Picture:    "/static/img/products/p.jpg",
+		PriceUsd:   &pb.Money{CurrencyCode: "USD", Units: units, Nanos: nanos},
+		Categories: categories,
+	}
+}
+
+// 筛选用的商品
+func facetProducts() []*pb.Product {
+	return []*pb.Product{
+		pricedProduct("P0", 1, 0, "clothing"),
+		pricedProduct("P1", 2, 500000000, "clothing", "kitchen"),
+		pricedProduct("P2", 5, 0, "kitchen"),
+		pricedProduct("P3", 10, 0, "home"),
+		pricedProduct("P4", 2, 500000000, "home", "kitchen"),
+	}
+}
+
+// 在临时目录中保存商品并创建商品分类服务
+func newServiceWithProducts(t *testing.T, products []*pb.Product) *ProductCatalogService {
+	store := catalogstore.NewJSONCatalogStore(filepath.Join(t.TempDir(), "products.json"))
+	if err := store.Save(context.Background(), products); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewProductCatalogService(store, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// 货币服务，1美元换2欧元，其他货币返回InvalidArgument，记录每次请求转换的金额个数
+type fakeCurrency struct {
+	pb.CurrencyServiceClient
+	batches []int
+}
+
+func (c *fakeCurrency) ConvertBatch(ctx context.Context, in *pb.ConvertBatchRequest, opts ...grpc.CallOption) (*pb.ConvertBatchResponse, error) {
+	c.batches = append(c.batches, len(in.GetFrom()))
+	if in.GetToCode() != "EUR" {
+		return nil, status.Errorf(codes.InvalidArgument, "不支持的币种: %s", in.GetToCode())
+	}
+	out := &pb.ConvertBatchResponse{RateVersion: "v1"}
+	for _, m := range in.GetFrom() {
+		eur, err := money.Multiply(&pb.Money{CurrencyCode: "EUR", Units: m.GetUnits(), Nanos: m.GetNanos()}, 2)
+		if err != nil {
+			return nil, err
+		}
+		out.Money = append(out.Money, eur)
+	}
+	return out, nil
+}
+
+func TestFilterPrice(t *testing.T) {
+	s := &ProductCatalogService{CurrencyService: &fakeCurrency{}}
+	usd := func(units int64, nanos int32) *pb.Money { return &pb.Money{CurrencyCode: "USD", Units: units, Nanos: nanos} }
+	eur := func(units int64, nanos int32) *pb.Money { return &pb.Money{CurrencyCode: "EUR", Units: units, Nanos: nanos} }
+	tests := []struct {
+		name     string
+		min, max *pb.Money
+		want     []string
+	}{
+		{"不限制", nil, nil, []string{"P0", "P1", "P2", "P3", "P4"}},
+		// 最低价格和最高价格都包含在内
+		{"包含最低价格", usd(2, 500000000), nil, []string{"P1", "P2", "P3", "P4"}},
+		{"低于最低价格", usd(2, 500000001), nil, []string{"P2", "P3"}},
+		{"包含最高价格", nil, usd(5, 0), []string{"P0", "P1", "P2", "P4"}},
+		{"高于最高价格", nil, usd(4, 999999999), []string{"P0", "P1", "P4"}},
+		{"最低价格等于最高价格", usd(2, 500000000), usd(2, 500000000), []string{"P1", "P4"}},
+		{"区间内没有商品", usd(6, 0), usd(9, 0), []string{}},
+		// 欧元价格是美元价格的2倍
+		{"欧元区间", eur(5, 0), eur(10, 0), []string{"P1", "P2", "P4"}},
+		{"欧元不包含", eur(5, 1), eur(9, 999999999), []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.filterPrice(context.Background(), facetProducts(), tt.min, tt.max)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ids := productIDs(got); !reflect.DeepEqual(ids, tt.want) {
+				t.Fatalf("按价格 %v - %v 筛选为 %v，期望 %v", tt.min, tt.max, ids, tt.want)
+			}
+		})
+	}
+}
+
+func TestFilterPriceInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		currency pb.CurrencyServiceClient
+		min, max *pb.Money
+		code     codes.Code
+	}{
+		{"最低价格高于最高价格", nil, &pb.Money{CurrencyCode: "USD", Units: 5}, &pb.Money{CurrencyCode: "USD", Units: 4, Nanos: 990000000}, codes.InvalidArgument},
+		{"货币不同", &fakeCurrency{}, &pb.Money{CurrencyCode: "USD", Units: 1}, &pb.Money{CurrencyCode: "EUR", Units: 2}, codes.InvalidArgument},
+		{"没有货币", nil, &pb.Money{Units: 1}, nil, codes.InvalidArgument},
+		{"金额无效", nil, nil, &pb.Money{CurrencyCode: "USD", Units: 1, Nanos: -1}, codes.InvalidArgument},
+		{"不支持的货币", &fakeCurrency{}, &pb.Money{CurrencyCode: "XXX", Units: 1}, nil, codes.InvalidArgument},
+		{"没有货币服务", nil, &pb.Money{CurrencyCode: "EUR", Units: 1}, nil, codes.FailedPrecondition},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &ProductCatalogService{CurrencyService: tt.currency}
+			_, err := s.filterPrice(context.Background(), facetProducts(), tt.min, tt.max)
+			if status.Code(err) != tt.code {
+				t.Fatalf("返回 %v，期望 %v", err, tt.code)
+			}
+		})
+	}
+}
+
+// 相同的价格只转换一次，所有价格在一次请求中转换，美元价格不转换
+func TestPricesInConvertsOnce(t *testing.T) {
+	currency := &fakeCurrency{}
+	s := &ProductCatalogService{CurrencyService: currency}
+	prices, err := s.pricesIn(context.Background(), facetProducts(), "EUR")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(currency.batches, []int{4}) {
+		t.Fatalf("转换请求的金额个数为 %v，期望 [4]", currency.batches)
+	}
+	want := []int64{2, 5, 10, 20, 5}
+	for i, p := range prices {
+		if p.GetCurrencyCode() != "EUR" || p.GetUnits() != want[i] || p.GetNanos() != 0 {
+			t.Fatalf("第%d个商品的欧元价格为 %v，期望 %d", i, p, want[i])
+		}
+	}
+	if _, err := s.pricesIn(context.Background(), facetProducts(), "USD"); err != nil || len(currency.batches) != 1 {
+		t.Fatalf("美元价格返回 %v，转换了%d次", err, len(currency.batches))
+	}
+}
+
+// 分类统计转换成"分类:个数"
+func facetCounts(facets []*pb.CategoryFacet) []string {
+	out := make([]string, len(facets))
+	for i, f := range facets {
+		out[i] = fmt.Sprintf("%s:%d", f.GetCategory(), f.GetCount())
+	}
+	return out
+}
+
+func TestCategoryFacets(t *testing.T) {
+	// 个数相同时按名称排序
+	want := []string{"kitchen:3", "clothing:2", "home:2"}
+	if got := facetCounts(categoryFacets(facetProducts())); !reflect.DeepEqual(got, want) {
+		t.Fatalf("分类统计为 %v，期望 %v", got, want)
+	}
+	if got := categoryFacets(nil); len(got) != 0 {
+		t.Fatalf("没有商品时分类统计为 %v", got)
+	}
+}
+
+func TestFilterCategories(t *testing.T) {
+	tests := []struct {
+		categories []string
+		want       []string
+	}{
+		{nil, []string{"P0", "P1", "P2", "P3", "P4"}},
+		{[]string{"clothing"}, []string{"P0", "P1"}},
+		// 属于任一分类即可，属于多个分类的商品只返回一次
+		{[]string{"clothing", "kitchen"}, []string{"P0", "P1", "P2", "P4"}},
+		{[]string{"garden"}, []string{}},
+	}
+	for _, tt := range tests {
+		if got := productIDs(filterCategories(facetProducts(), tt.categories)); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("按分类 %v 筛选为 %v，期望 %v", tt.categories, got, tt.want)
+		}
+	}
+}
+
+// 分类统计在按价格筛选后、按categories筛选前计算
+func TestSearchFacetsAfterFiltering(t *testing.T) {
+	s := newServiceWithProducts(t, facetProducts())
+	out, err := s.SearchProducts(context.Background(), &pb.SearchProductsRequest{
+		MaxPrice:   &pb.Money{CurrencyCode: "USD", Units: 5},
+		Categories: []string{"home"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ids := productIDs(out.GetResults()); !reflect.DeepEqual(ids, []string{"P4"}) || out.GetTotalCount() != 1 {
+		t.Fatalf("搜索结果为 %v，共%d个，期望 [P4]", ids, out.GetTotalCount())
+	}
+	want := []string{"kitchen:3", "clothing:2", "home:1"}
+	if got := facetCounts(out.GetCategoryFacets()); !reflect.DeepEqual(got, want) {
+		t.Fatalf("分类统计为 %v，期望 %v", got, want)
+	}
+
+	if _, err := s.SearchProducts(context.Background(), &pb.SearchProductsRequest{
+		MinPrice: &pb.Money{CurrencyCode: "USD", Units: 5},
+		MaxPrice: &pb.Money{CurrencyCode: "USD", Units: 1},
+	}); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("最低价格高于最高价格时返回 %v", err)
+	}
+}
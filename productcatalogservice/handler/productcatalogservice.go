@@ -1,124 +1,204 @@
 package handler
 
 import (
-	"bytes"
 	"context"
 	"log"
-	"os"
-	"os/signal"
-	"strings"
+	"path/filepath"
+	"strconv"
 	"sync"
-	"syscall"
+	"sync/atomic"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
-	"google.golang.org/protobuf/encoding/protojson"
 
+	"productcatalogservice/catalogstore"
 	pb "productcatalogservice/proto"
 )
 
-var reloadCatalog bool
+// 文件变化后等待多久再重新加载，编辑器保存文件时会连续产生多个事件
+const reloadDelay = 200 * time.Millisecond
 
-// 日志
-var (
-	buf    bytes.Buffer
-	logger = log.New(&buf, "logger: ", log.Lshortfile)
-)
+// 每页最多返回的商品数，page_size为0时也按这个数分页
+const maxPageSize = 100
+
+// 从存储加载的超时时间
+const loadTimeout = 30 * time.Second
 
-// 商品分类结构体
+// 商品分类结构体，商品目录为不可变的快照，重新加载或修改商品时原子替换
 type ProductCatalogService struct {
-	sync.Mutex
-	products []*pb.Product
+	store catalogstore.CatalogStore
+	// 图片路径相对的目录，为空表示不检查图片是否存在
+	pictureRoot string
+	current     atomic.Pointer[catalog]
+	// 串行执行重新加载和修改商品，修改总是基于最新的商品目录
+	writeMu sync.Mutex
+	// 重新加载成功和失败的次数
+	reloads, reloadFailures atomic.Uint64
+	// 按其他货币的价格筛选时用来转换价格，为nil时只能按美元价格筛选
+	CurrencyService pb.CurrencyServiceClient
 }
 
-// 商品列表
-func (s *ProductCatalogService) ListProducts(ctx context.Context, in *pb.Empty) (out *pb.ListProductsResponse, e error) {
-	out = new(pb.ListProductsResponse)
-	out.Products = s.parseCatalog()
-	return out, nil
+// 创建商品分类服务，立即从存储加载一次商品目录
+func NewProductCatalogService(store catalogstore.CatalogStore, pictureRoot string) (*ProductCatalogService, error) {
+	s := &ProductCatalogService{store: store, pictureRoot: pictureRoot}
+	ctx, cancel := context.WithTimeout(context.Background(), loadTimeout)
+	defer cancel()
+	c, err := loadCatalog(ctx, store, pictureRoot)
+	if err != nil {
+		return nil, err
+	}
+	s.current.Store(c)
+	return s, nil
 }
 
-// 获得单个商品
-func (s *ProductCatalogService) GetProduct(ctx context.Context, in *pb.GetProductRequest) (out *pb.Product, e error) {
-	var found *pb.Product
-	out = new(pb.Product)
-	products := s.parseCatalog()
-	for _, p := range products {
-		if in.Id == p.Id {
-			found = p
-		}
+// 重新加载商品目录，新的商品目录校验通过后才替换，失败时继续使用原来的商品目录
+func (s *ProductCatalogService) Reload() error {
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
+	ctx, cancel := context.WithTimeout(context.Background(), loadTimeout)
+	defer cancel()
+	c, err := loadCatalog(ctx, s.store, s.pictureRoot)
+	if err != nil {
+		failures := s.reloadFailures.Add(1)
+		log.Printf("重新加载商品失败（成功%d次，失败%d次），继续使用原来的商品: %v", s.reloads.Load(), failures, err)
+		return err
+	}
+	s.current.Store(c)
+	reloads := s.reloads.Add(1)
+	log.Printf("重新加载商品成功（成功%d次，失败%d次），共%d个商品", reloads, s.reloadFailures.Load(), len(c.products))
+	return nil
+}
+
+// 重新加载成功和失败的次数
+func (s *ProductCatalogService) ReloadStats() (reloads, failures uint64) {
+	return s.reloads.Load(), s.reloadFailures.Load()
+}
+
+// 监听json商品文件，文件变化时重新加载。监听的是文件所在的目录，文件被替换（如编辑器保存、mv）后仍然有效
+func (s *ProductCatalogService) Watch(path string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
 	}
-	if found == nil {
-		return out, status.Errorf(codes.NotFound, "no product with ID %s", in.Id)
+	if err := watcher.Add(filepath.Dir(path)); err != nil {
+		watcher.Close()
+		return err
 	}
-	out.Id = found.Id
-	out.Name = found.Name
-	out.Categories = found.Categories
-	out.Description = found.Description
-	out.Picture = found.Picture
-	out.PriceUsd = found.PriceUsd
-	return out, nil
+	name := filepath.Clean(path)
+
+	go func() {
+		defer watcher.Close()
+		var timer <-chan time.Time
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(event.Name) == name && event.Op&(fsnotify.Write|fsnotify.Create|fsnotify.Rename) != 0 {
+					timer = time.After(reloadDelay)
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("监听商品文件出错: %v", err)
+			case <-timer:
+				timer = nil
+				s.reloadIfModified()
+			}
+		}
+	}()
+	return nil
 }
 
-// 搜索商品
-func (s *ProductCatalogService) SearchProducts(ctx context.Context, in *pb.SearchProductsRequest) (out *pb.SearchProductsResponse, e error) {
-	var ps []*pb.Product
-	products := s.parseCatalog()
-	for _, p := range products {
-		if strings.Contains(strings.ToLower(p.Name), strings.ToLower(in.Query)) ||
-			strings.Contains(strings.ToLower(p.Description), strings.ToLower(in.Query)) {
-			ps = append(ps, p)
+// 商品文件被其他程序修改后才重新加载，update保存的修改已经生效，不重新加载也不计入重新加载次数
+func (s *ProductCatalogService) reloadIfModified() {
+	if m, ok := s.store.(catalogstore.ModifiedChecker); ok {
+		if modified, err := m.Modified(); err == nil && !modified {
+			return
 		}
 	}
-	out.Results = ps
-	return out, nil
+	s.Reload()
+}
+
+// 当前的商品目录
+func (s *ProductCatalogService) catalog() *catalog {
+	return s.current.Load()
 }
 
-// 读配置文件
-func (s *ProductCatalogService) readCatalogFile() (*pb.ListProductsResponse, error) {
-	s.Lock()
-	defer s.Unlock()
-	catalogJSON, err := os.ReadFile("data/products.json")
+// 商品列表，可以按分类筛选、排序和分页
+func (s *ProductCatalogService) ListProducts(ctx context.Context, in *pb.ListProductsRequest) (out *pb.ListProductsResponse, e error) {
+	products, err := s.catalog().list(in.Sort, in.Category)
 	if err != nil {
-		logger.Printf("打开商品 json 文件失败: %v", err)
-		return nil, err
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
 	}
-	catalog := &pb.ListProductsResponse{}
-	if err := protojson.Unmarshal(catalogJSON, catalog); err != nil {
-		logger.Printf("解析商品 JSON 文件失败: %v", err)
+	page, next, err := paginate(products, in.PageSize, in.PageToken)
+	if err != nil {
 		return nil, err
 	}
-	logger.Printf("解析商品 JSON 文件成功")
-	return catalog, nil
+	return &pb.ListProductsResponse{
+		Products:      page,
+		NextPageToken: next,
+		TotalCount:    int32(len(products)),
+	}, nil
 }
 
-// 解析配置文件
-func (s *ProductCatalogService) parseCatalog() []*pb.Product {
-	if reloadCatalog || len(s.products) == 0 {
-		catalog, err := s.readCatalogFile()
-		if err != nil {
-			return []*pb.Product{}
+// 分页，page_token为下一页第一个商品的位置，重新加载商品目录后位置可能对应不同的商品，分页时不保证一致
+func paginate(products []*pb.Product, pageSize int32, pageToken string) (page []*pb.Product, next string, err error) {
+	if pageSize < 0 {
+		return nil, "", status.Errorf(codes.InvalidArgument, "page_size不能为负数: %d", pageSize)
+	}
+	start := 0
+	if pageToken != "" {
+		if start, err = strconv.Atoi(pageToken); err != nil || start < 0 || start > len(products) {
+			return nil, "", status.Errorf(codes.InvalidArgument, "page_token无效: %s", pageToken)
 		}
-		s.products = catalog.Products
 	}
-	return s.products
+	size := int(pageSize)
+	if size == 0 || size > maxPageSize {
+		size = maxPageSize
+	}
+	end := start + size
+	if end > len(products) {
+		end = len(products)
+	}
+	if end < len(products) {
+		next = strconv.Itoa(end)
+	}
+	return products[start:end], next, nil
 }
 
-// 初始化
-func init() {
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGUSR1, syscall.SIGUSR2)
-	go func() {
-		for {
-			sig := <-sigs
-			logger.Printf("接收信号: %s", sig)
-			if sig == syscall.SIGUSR1 {
-				reloadCatalog = true
-				logger.Printf("可以加载商品信息")
-			} else {
-				reloadCatalog = false
-				logger.Printf("不能加载商品信息")
-			}
-		}
-	}()
+// 获得单个商品
+func (s *ProductCatalogService) GetProduct(ctx context.Context, in *pb.GetProductRequest) (out *pb.Product, e error) {
+	found, ok := s.catalog().byID[in.Id]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no product with ID %s", in.Id)
+	}
+	return found, nil
+}
+
+// 搜索商品，默认按相关度排序，可以按分类和价格筛选、分页，并返回分类的统计结果
+func (s *ProductCatalogService) SearchProducts(ctx context.Context, in *pb.SearchProductsRequest) (out *pb.SearchProductsResponse, e error) {
+	results, err := s.catalog().search(in.Query, in.Category, in.Sort)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	if results, err = s.filterPrice(ctx, results, in.MinPrice, in.MaxPrice); err != nil {
+		return nil, err
+	}
+	facets := categoryFacets(results)
+	results = filterCategories(results, in.Categories)
+	page, next, err := paginate(results, in.PageSize, in.PageToken)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.SearchProductsResponse{
+		Results:        page,
+		NextPageToken:  next,
+		TotalCount:     int32(len(results)),
+		CategoryFacets: facets,
+	}, nil
 }
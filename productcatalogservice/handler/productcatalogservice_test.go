@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"productcatalogservice/catalogstore"
+	pb "productcatalogservice/proto"
+)
+
+// 测试用的商品，描述中带有版本号，同一个快照中所有商品的版本相同
+func versionedProducts(version int) []*pb.Product {
+	out := make([]*pb.Product, 5)
+	for i := range out {
+		out[i] = &pb.Product{
+			Id:          fmt.Sprintf("P%d", i),
+			Name:        fmt.Sprintf("商品%d", i),
+			Description: fmt.Sprintf("版本%d", version),
+			Picture:     "/static/img/products/p.jpg",
+			PriceUsd:    &pb.Money{CurrencyCode: "USD", Units: int64(version)},
+			Categories:  []string{"test"},
+		}
+	}
+	return out
+}
+
+// 在临时目录中创建json商品文件和商品分类服务，不检查图片
+func newTestService(t *testing.T) *ProductCatalogService {
+	s, _ := newWatchedService(t)
+	return s
+}
+
+// 创建商品分类服务，同时返回商品文件的路径
+func newWatchedService(t *testing.T) (*ProductCatalogService, string) {
+	path := filepath.Join(t.TempDir(), "products.json")
+	store := catalogstore.NewJSONCatalogStore(path)
+	if err := store.Save(context.Background(), versionedProducts(0)); err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewProductCatalogService(store, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s, path
+}
+
+// 一次响应中的商品必须来自同一个完整的快照
+func checkSnapshot(t *testing.T, name string, products []*pb.Product) {
+	if len(products) != 5 {
+		t.Errorf("%s 返回%d个商品，期望5个", name, len(products))
+		return
+	}
+	for _, p := range products {
+		if p.GetDescription() != products[0].GetDescription() {
+			t.Errorf("%s 返回的商品来自不同的快照: %s 和 %s", name, p.GetDescription(), products[0].GetDescription())
+			return
+		}
+	}
+}
+
+// 重新加载和修改商品时并发读取，用 go test -race 运行
+func TestConcurrentReadsDuringReloadAndUpdate(t *testing.T) {
+	const (
+		writes  = 30
+		readers = 4
+	)
+	s := newTestService(t)
+	admin := &ProductCatalogAdminService{Catalog: s}
+	ctx := context.Background()
+
+	var writers, reads sync.WaitGroup
+	done := make(chan struct{})
+	writers.Add(2)
+	go func() {
+		defer writers.Done()
+		for i := 0; i < writes; i++ {
+			if err := s.Reload(); err != nil {
+				t.Errorf("Reload: %v", err)
+			}
+		}
+	}()
+	go func() {
+		defer writers.Done()
+		for i := 1; i <= writes; i++ {
+			if _, err := admin.BulkImport(ctx, &pb.BulkImportRequest{Products: versionedProducts(i), Replace: true}); err != nil {
+				t.Errorf("BulkImport: %v", err)
+			}
+		}
+	}()
+
+	for i := 0; i < readers; i++ {
+		reads.Add(1)
+		go func() {
+			defer reads.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+				if p, err := s.GetProduct(ctx, &pb.GetProductRequest{Id: "P3"}); err != nil || p.GetId() != "P3" {
+					t.Errorf("GetProduct 返回 %v, %v", p, err)
+				}
+				if list, err := s.ListProducts(ctx, &pb.ListProductsRequest{}); err != nil {
+					t.Errorf("ListProducts: %v", err)
+				} else {
+					checkSnapshot(t, "ListProducts", list.GetProducts())
+				}
+				if found, err := s.SearchProducts(ctx, &pb.SearchProductsRequest{Query: "商品"}); err != nil {
+					t.Errorf("SearchProducts: %v", err)
+				} else {
+					checkSnapshot(t, "SearchProducts", found.GetResults())
+				}
+			}
+		}()
+	}
+
+	writers.Wait()
+	close(done)
+	reads.Wait()
+
+	// 最后一次修改已经保存，重新加载后仍然是最后的版本
+	if err := s.Reload(); err != nil {
+		t.Fatal(err)
+	}
+	p, err := s.GetProduct(ctx, &pb.GetProductRequest{Id: "P0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("版本%d", writes); p.GetDescription() != want {
+		t.Fatalf("最后的商品为 %s，期望 %s", p.GetDescription(), want)
+	}
+}
+
+// 修改商品保存的文件不触发重新加载，其他程序修改文件后重新加载
+func TestWatchSkipsOwnWrites(t *testing.T) {
+	ctx := context.Background()
+	s, path := newWatchedService(t)
+	if err := s.Watch(path); err != nil {
+		t.Fatal(err)
+	}
+	admin := &ProductCatalogAdminService{Catalog: s}
+	if _, err := admin.BulkImport(ctx, &pb.BulkImportRequest{Products: versionedProducts(1), Replace: true}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * reloadDelay)
+	if reloads, failures := s.ReloadStats(); reloads != 0 || failures != 0 {
+		t.Fatalf("保存修改后重新加载了%d次，失败%d次", reloads, failures)
+	}
+
+	if err := catalogstore.NewJSONCatalogStore(path).Save(ctx, versionedProducts(2)); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if reloads, _ := s.ReloadStats(); reloads == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("商品文件被修改后没有重新加载")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	p, err := s.GetProduct(ctx, &pb.GetProductRequest{Id: "P0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.GetDescription() != "版本2" {
+		t.Fatalf("重新加载后的商品为 %s，期望 版本2", p.GetDescription())
+	}
+}
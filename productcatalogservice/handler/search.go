@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+
+	pb "productcatalogservice/proto"
+)
+
+// 字段的权重，名称中出现的关键词比描述中的更相关
+const (
+	nameBoost        = 3.0
+	categoryBoost    = 2.0
+	descriptionBoost = 1.0
+)
+
+// 倒排索引中的一项，表示词出现在某个商品中
+type posting struct {
+	// 商品在商品文件中的位置
+	product int
+	// 词出现的字段权重之和
+	weight float64
+}
+
+// 商品倒排索引，创建后不再修改
+type searchIndex struct {
+	products []*pb.Product
+	// key为词，每个词的商品按位置排序
+	postings map[string][]posting
+}
+
+// 为所有商品的名称、分类和描述建立倒排索引
+func newSearchIndex(products []*pb.Product) *searchIndex {
+	idx := &searchIndex{
+		products: products,
+		postings: make(map[string][]posting),
+	}
+	for i, p := range products {
+		weights := make(map[string]float64)
+		addTokens := func(text string, boost float64) {
+			seen := make(map[string]bool)
+			for _, t := range indexTokens(text) {
+				if !seen[t] {
+					seen[t] = true
+					weights[t] += boost
+				}
+			}
+		}
+		addTokens(p.GetName(), nameBoost)
+		addTokens(strings.Join(p.GetCategories(), " "), categoryBoost)
+		addTokens(p.GetDescription(), descriptionBoost)
+		for t, w := range weights {
+			idx.postings[t] = append(idx.postings[t], posting{product: i, weight: w})
+		}
+	}
+	return idx
+}
+
+// 搜索商品，按相关度从高到低返回，相关度相同时保持商品文件中的顺序。
+// 相关度为匹配到的每个词的字段权重乘以idf之和，出现在越少商品中的词越重要
+func (idx *searchIndex) search(query string) []*pb.Product {
+	scores := make(map[int]float64)
+	n := float64(len(idx.products))
+	seen := make(map[string]bool)
+	for _, t := range queryTokens(query) {
+		ps := idx.postings[t]
+		if len(ps) == 0 || seen[t] {
+			continue
+		}
+		seen[t] = true
+		idf := math.Log(1 + n/float64(len(ps)))
+		for _, p := range ps {
+			scores[p.product] += p.weight * idf
+		}
+	}
+
+	matched := make([]int, 0, len(scores))
+	for i := range scores {
+		matched = append(matched, i)
+	}
+	sort.Slice(matched, func(a, b int) bool {
+		if scores[matched[a]] != scores[matched[b]] {
+			return scores[matched[a]] > scores[matched[b]]
+		}
+		return matched[a] < matched[b]
+	})
+	out := make([]*pb.Product, len(matched))
+	for i, m := range matched {
+		out[i] = idx.products[m]
+	}
+	return out
+}
+
+// 是否按单个字符分词的文字，中日韩文字之间没有空格
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// 把文本切分成连续的中日韩文字和连续的字母数字，其他字符作为分隔符，字母转为小写
+func segments(text string) (words []string, cjk [][]rune) {
+	var word []rune
+	var han []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+		if len(han) > 0 {
+			cjk = append(cjk, han)
+			han = nil
+		}
+	}
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			if len(word) > 0 {
+				flush()
+			}
+			han = append(han, r)
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if len(han) > 0 {
+				flush()
+			}
+			word = append(word, r)
+		default:
+			flush()
+		}
+	}
+	flush()
+	return words, cjk
+}
+
+// 索引时的分词，中日韩文字同时索引单字和相邻两个字，单字的关键词也能搜到
+func indexTokens(text string) []string {
+	tokens, cjk := segments(text)
+	for _, run := range cjk {
+		for i := range run {
+			tokens = append(tokens, string(run[i]))
+			if i+1 < len(run) {
+				tokens = append(tokens, string(run[i:i+2]))
+			}
+		}
+	}
+	return tokens
+}
+
+// 搜索时的分词，中日韩文字按相邻两个字切分，只有一个字时按单字搜索
+func queryTokens(query string) []string {
+	tokens, cjk := segments(query)
+	for _, run := range cjk {
+		if len(run) == 1 {
+			tokens = append(tokens, string(run))
+			continue
+		}
+		for i := 0; i+1 < len(run); i++ {
+			tokens = append(tokens, string(run[i:i+2]))
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,122 @@
+package handler
+
+import (
+	"reflect"
+	"testing"
+
+	pb "productcatalogservice/proto"
+)
+
+func TestSegments(t *testing.T) {
+	tests := []struct {
+		text  string
+		words []string
+		cjk   []string
+	}{
+		{"Hello, World", []string{"hello", "world"}, nil},
+		{"Go1.20 版本", []string{"go1", "20"}, []string{"版本"}},
+		{"复古相机", nil, []string{"复古相机"}},
+		{"T恤shirt", []string{"t", "shirt"}, []string{"恤"}},
+		{"咖啡·杯 mug", []string{"mug"}, []string{"咖啡", "杯"}},
+		{"カメラ 카메라", nil, []string{"カメラ", "카메라"}},
+		{"", nil, nil},
+		{" ,.!", nil, nil},
+	}
+	for _, tt := range tests {
+		words, cjk := segments(tt.text)
+		var runs []string
+		for _, run := range cjk {
+			runs = append(runs, string(run))
+		}
+		if !reflect.DeepEqual(words, tt.words) || !reflect.DeepEqual(runs, tt.cjk) {
+			t.Errorf("segments(%q) = %q, %q，期望 %q, %q", tt.text, words, runs, tt.words, tt.cjk)
+		}
+	}
+}
+
+func TestIndexTokens(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Vintage Camera", []string{"vintage", "camera"}},
+		{"复古相机", []string{"复", "复古", "古", "古相", "相", "相机", "机"}},
+		{"相机 Camera", []string{"camera", "相", "相机", "机"}},
+		{"杯", []string{"杯"}},
+		{"", nil},
+	}
+	for _, tt := range tests {
+		if got := indexTokens(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("indexTokens(%q) = %q，期望 %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestQueryTokens(t *testing.T) {
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"CAMERA", []string{"camera"}},
+		{"复古相机", []string{"复古", "古相", "相机"}},
+		{"机", []string{"机"}},
+		{"vintage 相机", []string{"vintage", "相机"}},
+		{"相 机", []string{"相", "机"}},
+		{"", nil},
+		{"  ,. ", nil},
+	}
+	for _, tt := range tests {
+		if got := queryTokens(tt.query); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("queryTokens(%q) = %q，期望 %q", tt.query, got, tt.want)
+		}
+	}
+}
+
+// 搜索用的商品
+func searchProducts() []*pb.Product {
+	return []*pb.Product{
+		{Id: "P0", Name: "Vintage Camera", Description: "A 复古 film camera", Categories: []string{"photography"}},
+		{Id: "P1", Name: "复古打字机", Description: "An old typewriter", Categories: []string{"retro"}},
+		{Id: "P2", Name: "Mug", Description: "Coffee mug for the camera lover", Categories: []string{"kitchen"}},
+		{Id: "P3", Name: "Camera Strap", Description: "Leather strap", Categories: []string{"accessories"}},
+	}
+}
+
+// 搜索结果的商品id
+func productIDs(products []*pb.Product) []string {
+	ids := make([]string, len(products))
+	for i, p := range products {
+		ids[i] = p.GetId()
+	}
+	return ids
+}
+
+func TestSearch(t *testing.T) {
+	idx := newSearchIndex(searchProducts())
+	tests := []struct {
+		name, query string
+		want        []string
+	}{
+		// 名称和描述都匹配的排在前面，名称匹配的排在描述匹配的前面
+		{"名称比描述优先", "camera", []string{"P0", "P3", "P2"}},
+		{"不区分大小写", "CAMERA", []string{"P0", "P3", "P2"}},
+		{"中文名称比描述优先", "复古", []string{"P1", "P0"}},
+		{"中文按相邻两个字匹配", "打字机", []string{"P1"}},
+		{"中文单字", "机", []string{"P1"}},
+		{"不相邻的两个字不匹配", "复机", []string{}},
+		// vintage只在P0的名称中，复古在P1的名称中，在P0的描述中，P0两个词都匹配
+		{"中英文混合", "vintage 复古", []string{"P0", "P1"}},
+		{"中英文相连", "strap复古", []string{"P3", "P1", "P0"}},
+		{"重复的词只计算一次", "mug mug mug camera", []string{"P2", "P0", "P3"}},
+		{"没有匹配", "bicycle", []string{}},
+		{"空查询", "", []string{}},
+		{"只有分隔符", " ,. ", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := productIDs(idx.search(tt.query)); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("搜索 %q 返回 %v，期望 %v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
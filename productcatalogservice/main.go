@@ -1,8 +1,11 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"net"
+	"productcatalogservice/catalogstore"
 	handler "productcatalogservice/handler"
 	pb "productcatalogservice/proto"
 	"strconv"
@@ -11,10 +14,121 @@ import (
 	"google.golang.org/grpc"
 )
 
+// 从consul获取健康的服务并连接，没有健康的服务时返回错误
+func GetGrpcConn(consulClient *api.Client, serviceName string, serviceTag string) (*grpc.ClientConn, error) {
+	service, _, err_service := consulClient.Health().Service(serviceName, serviceTag, true, nil)
+	if err_service != nil {
+		return nil, fmt.Errorf("获取健康服务报错: %v", err_service)
+	}
+	if len(service) == 0 {
+		return nil, fmt.Errorf("没有健康的服务: %s", serviceName)
+	}
+	s := service[0].Service
+	address := s.Address + ":" + strconv.Itoa(s.Port)
+	fmt.Printf("serviceName: %v\n", serviceName)
+
+	fmt.Printf("address:%s\n", address)
+
+	//链接grpc服务
+	return grpc.Dial(address, grpc.WithInsecure())
+}
+
 const PORT = 50015
 const ADDRESS = "127.0.0.1"
 
+// 商品目录文件，修改后自动重新加载，pictureRoot为商品图片路径相对的目录，用来检查图片是否存在
+var (
+	catalogPath = flag.String("catalog", "data/products.json", "商品json文件路径")
+	pictureRoot = flag.String("picture-root", "../frontend", "商品图片路径相对的目录，为空表示不检查图片是否存在")
+)
+
+// 商品存储方式：json 商品json文件，bolt 本地数据库，bolt数据库为空时从商品json文件导入
+var (
+	catalogStoreType = flag.String("catalog-store", "json", "商品存储方式: json 或 bolt")
+	catalogDBPath    = flag.String("catalog-db", "catalog.db", "bolt数据库文件路径")
+)
+
+// 商品管理接口可以修改商品，默认不提供。开启后在单独的端口上提供，不注册到consul，请求必须带有-admin-token
+var (
+	enableAdmin = flag.Bool("admin", false, "是否提供商品管理接口")
+	adminAddr   = flag.String("admin-addr", "127.0.0.1:50017", "商品管理接口的监听地址")
+	adminToken  = flag.String("admin-token", "", "商品管理接口的认证token，请求的metadata中带有 authorization: Bearer <token>")
+)
+
+// 按其他货币的价格筛选商品时需要货币服务，关闭后只能按美元价格筛选
+var convertPrices = flag.Bool("convert-prices", true, "是否连接货币服务，按其他货币的价格筛选商品")
+
+// 根据启动参数创建商品存储
+func newCatalogStore() (catalogstore.CatalogStore, error) {
+	switch *catalogStoreType {
+	case "json":
+		return catalogstore.NewJSONCatalogStore(*catalogPath), nil
+	case "bolt":
+		store, err := catalogstore.NewBoltCatalogStore(*catalogDBPath)
+		if err != nil {
+			return nil, err
+		}
+		return store, seedCatalogStore(store)
+	default:
+		return nil, fmt.Errorf("不支持的存储方式: %s", *catalogStoreType)
+	}
+}
+
+// 存储中没有商品时，从商品json文件导入
+func seedCatalogStore(store catalogstore.CatalogStore) error {
+	ctx := context.Background()
+	products, err := store.Load(ctx)
+	if err != nil || len(products) > 0 {
+		return err
+	}
+	if products, err = catalogstore.NewJSONCatalogStore(*catalogPath).Load(ctx); err != nil {
+		return err
+	}
+	fmt.Printf("从 %s 导入%d个商品\n", *catalogPath, len(products))
+	return store.Save(ctx, products)
+}
+
+// 在单独的端口上提供商品管理接口，所有请求都需要认证
+func serveAdmin(catalogService *handler.ProductCatalogService) error {
+	if *adminToken == "" {
+		return fmt.Errorf("开启商品管理接口时必须设置-admin-token")
+	}
+	listener, err := net.Listen("tcp", *adminAddr)
+	if err != nil {
+		return err
+	}
+	adminServer := grpc.NewServer(grpc.UnaryInterceptor(handler.AdminAuthInterceptor(*adminToken)))
+	pb.RegisterProductCatalogAdminServiceServer(adminServer, &handler.ProductCatalogAdminService{Catalog: catalogService})
+	go func() {
+		if err_grpc := adminServer.Serve(listener); err_grpc != nil {
+			fmt.Println("商品管理接口报错:", err_grpc)
+		}
+	}()
+	fmt.Println("商品管理接口监听", *adminAddr)
+	return nil
+}
+
 func main() {
+	flag.Parse()
+
+	store, err_store := newCatalogStore()
+	if err_store != nil {
+		fmt.Println("创建商品存储报错：", err_store)
+		return
+	}
+	catalogService, err_catalog := handler.NewProductCatalogService(store, *pictureRoot)
+	if err_catalog != nil {
+		fmt.Println("加载商品报错：", err_catalog)
+		return
+	}
+	// 只有json文件可以手工修改，需要监听
+	if *catalogStoreType == "json" {
+		if err_watch := catalogService.Watch(*catalogPath); err_watch != nil {
+			fmt.Println("监听商品文件报错：", err_watch)
+			return
+		}
+	}
+
 	ipport := ADDRESS + ":" + strconv.Itoa(PORT)
 	// ------------注册到consul上-------------------
 	// 初始化consul配置
@@ -42,12 +156,27 @@ func main() {
 		return
 	}
 
+	// 连接不到货币服务时仍然启动，只能按美元价格筛选
+	if *convertPrices {
+		if conn, err_conn := GetGrpcConn(consulClient, "currencyservice", "currencyservice"); err_conn != nil {
+			fmt.Println("连接货币服务报错，只能按美元价格筛选：", err_conn)
+		} else {
+			catalogService.CurrencyService = pb.NewCurrencyServiceClient(conn)
+		}
+	}
+
 	//-----------------------grpc代码----------------------------------
 	// 初始化grpc对象
 	grpcServer := grpc.NewServer()
 
 	// 注册服务
-	pb.RegisterProductCatalogServiceServer(grpcServer, new(handler.ProductCatalogService))
+	pb.RegisterProductCatalogServiceServer(grpcServer, catalogService)
+	if *enableAdmin {
+		if err_admin := serveAdmin(catalogService); err_admin != nil {
+			fmt.Println("启动商品管理接口报错：", err_admin)
+			return
+		}
+	}
 
 	// 设置监听
 	listien, err := net.Listen("tcp", ipport)
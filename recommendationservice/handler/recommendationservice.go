@@ -25,12 +25,12 @@ func (s *RecommendationService) ListRecommendations(ctx context.Context, in *pb.
 	maxResponsesCount := 5
 	out = new(pb.ListRecommendationsResponse)
 	// 查询商品类别
-	catalog, err := s.ProductCatalogService.ListProducts(ctx, &pb.Empty{})
+	products, err := s.listAllProducts(ctx)
 	if err != nil {
 		return out, err
 	}
-	filteredProductsIDs := make([]string, 0, len(catalog.Products))
-	for _, p := range catalog.Products {
+	filteredProductsIDs := make([]string, 0, len(products))
+	for _, p := range products {
 		if contains(p.Id, in.ProductIds) {
 			continue
 		}
@@ -42,6 +42,23 @@ func (s *RecommendationService) ListRecommendations(ctx context.Context, in *pb.
 	return out, nil
 }
 
+// 按页查询所有商品
+func (s *RecommendationService) listAllProducts(ctx context.Context) ([]*pb.Product, error) {
+	var products []*pb.Product
+	req := &pb.ListProductsRequest{}
+	for {
+		resp, err := s.ProductCatalogService.ListProducts(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, resp.GetProducts()...)
+		if resp.GetNextPageToken() == "" {
+			return products, nil
+		}
+		req.PageToken = resp.GetNextPageToken()
+	}
+}
+
 // 判断是否包含
 func contains(target string, source []string) bool {
 	for _, s := range source {